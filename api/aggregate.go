@@ -0,0 +1,177 @@
+package api
+
+import (
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	aggregateParameter = "aggregate"
+
+	aggregateSum    = "sum"
+	aggregateMean   = "mean"
+	aggregateMin    = "min"
+	aggregateMax    = "max"
+	aggregateCount  = "count"
+	aggregateMedian = "median"
+)
+
+// supportedAggregates are the values accepted by the aggregate query parameter
+var supportedAggregates = []string{aggregateSum, aggregateMean, aggregateMin, aggregateMax, aggregateCount, aggregateMedian}
+
+// getAggregate returns the function requested by the aggregate query parameter, unless it is a version dimension, in
+// which case it selects options of that dimension. An empty function is returned if no aggregate has been requested.
+func getAggregate(urlQuery url.Values, validDimensions []string) (string, error) {
+	value, found := getReservedQueryParameter(urlQuery, validDimensions, aggregateParameter)
+	if !found {
+		return "", nil
+	}
+
+	function := strings.ToLower(strings.TrimSpace(value))
+	if !containsOption(supportedAggregates, function) {
+		return "", errs.ErrorInvalidAggregate(value, "the aggregate must be one of: ["+strings.Join(supportedAggregates, " ")+"]")
+	}
+
+	return function, nil
+}
+
+// aggregatedDimensions returns the dimensions that the observations are aggregated across, which are the dimensions
+// that select all their options or more than one option, sorted by name
+func aggregatedDimensions(queryParameters map[string][]string) []string {
+	var dimensions []string
+	for dimension, options := range queryParameters {
+		if len(options) > 1 || (len(options) == 1 && options[0] == "*") {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	sort.Strings(dimensions)
+	return dimensions
+}
+
+// aggregatedRows computes a single derived observation from the rows of observations it reads, across the dimensions
+// that vary between them. Observations that are not numbers, such as sparsity markers or observations without a value,
+// are excluded from the computation and counted. The derived row keeps the options of the other dimensions, while the
+// code of each aggregated dimension is left empty and its label describes the aggregate. The observations are only read
+// up to the limit of their stream, so nothing is computed from them if it was capped.
+type aggregatedRows struct {
+	rows        rowIterator
+	stream      *observationRows
+	function    string
+	header      []string
+	offset      int
+	dimensions  []string
+	read        bool
+	aggregation *models.Aggregation
+}
+
+// Next returns the derived row on the first call, or io.EOF once it has been returned or if there were no rows to aggregate
+func (a *aggregatedRows) Next() ([]string, error) {
+	if a.read {
+		return nil, io.EOF
+	}
+
+	var derived []string
+	var values []float64
+	excluded := 0
+
+	for {
+		row, err := a.rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if derived == nil {
+			derived = append([]string(nil), row...)
+		}
+
+		if value, isNumber := parseValue(row[0]); isNumber {
+			values = append(values, value)
+		} else {
+			excluded++
+		}
+	}
+
+	a.read = true
+	if a.stream.capped() {
+		return nil, errs.ErrorTooManyAggregatedObservations(a.stream.limit)
+	}
+
+	a.aggregation = &models.Aggregation{
+		Aggregate:            a.function,
+		Dimensions:           a.dimensions,
+		InputObservations:    len(values),
+		ExcludedObservations: excluded,
+	}
+
+	if derived == nil {
+		return nil, io.EOF
+	}
+
+	derived[0] = aggregateValues(a.function, values)
+
+	// the metadata of the observations, such as their confidence intervals, does not apply to the derived observation
+	for i := 1; i <= a.offset; i++ {
+		derived[i] = ""
+	}
+
+	for i := a.offset + 2; i < len(a.header) && i < len(derived); i += 2 {
+		if containsOption(a.dimensions, strings.ToLower(a.header[i])) {
+			derived[i-1] = ""
+			derived[i] = a.function + " (derived)"
+		}
+	}
+
+	return derived, nil
+}
+
+// aggregateValues applies the aggregate function to the numeric values of the observations. Apart from their count,
+// nothing can be computed from no values, in which case the derived observation has no value.
+func aggregateValues(function string, values []float64) string {
+	if function == aggregateCount {
+		return strconv.Itoa(len(values))
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	var result float64
+	switch function {
+	case aggregateSum, aggregateMean:
+		for _, value := range values {
+			result += value
+		}
+		if function == aggregateMean {
+			result /= float64(len(values))
+		}
+	case aggregateMin:
+		result = values[0]
+		for _, value := range values[1:] {
+			result = min(result, value)
+		}
+	case aggregateMax:
+		result = values[0]
+		for _, value := range values[1:] {
+			result = max(result, value)
+		}
+	case aggregateMedian:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		middle := len(sorted) / 2
+		result = sorted[middle]
+		if len(sorted)%2 == 0 {
+			result = (sorted[middle-1] + sorted[middle]) / 2
+		}
+	}
+
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
@@ -8,44 +8,72 @@ import (
 	"github.com/ONSdigital/dp-authorisation/auth"
 	"github.com/ONSdigital/dp-net/request"
 	"github.com/ONSdigital/dp-observation-api/config"
+	"github.com/ONSdigital/dp-observation-api/store"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 )
 
 // API provides a struct to wrap the api around
 type API struct {
-	cfg                *config.Config
-	Router             *mux.Router
-	graphDB            IGraph
-	datasetClient      IDatasetClient
-	cantabularClient   CantabularClient
-	permissions        IAuthHandler
-	enableURLRewriting bool
-	codeListAPIURL     *url.URL
-	datasetAPIURL      *url.URL
-	observationAPIURL  *url.URL
+	cfg                 *config.Config
+	Router              *mux.Router
+	observationStores   *store.Registry
+	datasetClient       IDatasetClient
+	cantabularClient    CantabularClient
+	hierarchyClient     IHierarchyClient
+	permissions         IAuthHandler
+	instancePermissions IAuthHandler
+	enableURLRewriting  bool
+	codeListAPIURL      *url.URL
+	datasetAPIURL       *url.URL
+	observationAPIURL   *url.URL
+	optionsCache        *optionsCache
 }
 
 // Setup creates the API struct and its endpoints with corresponding handlers
-func Setup(_ context.Context, r *mux.Router, cfg *config.Config, graphDB IGraph, datasetClient IDatasetClient, cantabularClient CantabularClient, permissions IAuthHandler, enableURLRewriting bool, codeListAPIURL, datasetAPIURL, observationAPIURL *url.URL) *API {
+func Setup(ctx context.Context, r *mux.Router, cfg *config.Config, observationStores *store.Registry, datasetClient IDatasetClient, cantabularClient CantabularClient, hierarchyClient IHierarchyClient, permissions, instancePermissions IAuthHandler, enableURLRewriting bool, codeListAPIURL, datasetAPIURL, observationAPIURL *url.URL) *API {
 	api := &API{
-		cfg:                cfg,
-		Router:             r,
-		graphDB:            graphDB,
-		datasetClient:      datasetClient,
-		cantabularClient:   cantabularClient,
-		permissions:        permissions,
-		enableURLRewriting: enableURLRewriting,
-		codeListAPIURL:     codeListAPIURL,
-		datasetAPIURL:      datasetAPIURL,
-		observationAPIURL:  observationAPIURL,
+		cfg:                 cfg,
+		Router:              r,
+		observationStores:   observationStores,
+		datasetClient:       datasetClient,
+		cantabularClient:    cantabularClient,
+		hierarchyClient:     hierarchyClient,
+		permissions:         permissions,
+		instancePermissions: instancePermissions,
+		enableURLRewriting:  enableURLRewriting,
+		codeListAPIURL:      codeListAPIURL,
+		datasetAPIURL:       datasetAPIURL,
+		observationAPIURL:   observationAPIURL,
+		optionsCache:        newOptionsCache(cfg.DimensionOptionsCacheTTL),
 	}
 
 	if api.cfg.EnablePrivateEndpoints {
 		read := auth.Permissions{Read: true}
 		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations", permissions.Require(read, api.getObservations)).Methods(http.MethodGet)
+		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/metadata.json", permissions.Require(read, api.getObservationsMetadata)).Methods(http.MethodGet)
+		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/query", permissions.Require(read, api.postObservationsQuery)).Methods(http.MethodPost)
 	} else {
 		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations", api.getObservations).Methods(http.MethodGet)
+		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/metadata.json", api.getObservationsMetadata).Methods(http.MethodGet)
+		r.HandleFunc("/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/query", api.postObservationsQuery).Methods(http.MethodPost)
+	}
+
+	// V4 files are imported into the observation store of the default backend by callers with permission to update
+	// instances, so the endpoint is only registered along with the other private endpoints
+	if api.cfg.EnableDevMode {
+		_, observationStore := observationStores.Default()
+		importer, isImporter := observationStore.(store.Importer)
+		switch {
+		case !api.cfg.EnablePrivateEndpoints:
+			log.Warn(ctx, "dev mode enabled, but V4 files can only be imported with private endpoints enabled", log.Data{"feature": "ENABLE_DEV_MODE"})
+		case !isImporter:
+			log.Warn(ctx, "dev mode enabled, but V4 files cannot be imported into the observation store", log.Data{"feature": "ENABLE_DEV_MODE"})
+		default:
+			log.Warn(ctx, "dev mode enabled, V4 files can be imported into the observation store", log.Data{"feature": "ENABLE_DEV_MODE"})
+			update := auth.Permissions{Update: true}
+			r.HandleFunc("/instances/{instance_id}/observations", instancePermissions.Require(update, api.putInstanceObservations(importer))).Methods(http.MethodPut)
+		}
 	}
 
 	return api
@@ -69,8 +97,8 @@ func (api *API) checkIfAuthorised(r *http.Request, logData log.Data) (authorised
 	return authorised
 }
 
-func setJSONContentType(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
+func setContentType(w http.ResponseWriter, format string) {
+	w.Header().Set("Content-Type", formatContentTypes[format])
 }
 
 // Close is called during graceful shutdown to give the API an opportunity to perform any required disposal task
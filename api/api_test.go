@@ -16,6 +16,8 @@ import (
 	"github.com/ONSdigital/dp-observation-api/api/mock"
 	errs "github.com/ONSdigital/dp-observation-api/apierrors"
 	"github.com/ONSdigital/dp-observation-api/config"
+	"github.com/ONSdigital/dp-observation-api/store"
+	storeMock "github.com/ONSdigital/dp-observation-api/store/mock"
 	"github.com/gorilla/mux"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -38,22 +40,24 @@ func TestSetup(t *testing.T) {
 	Convey("Given a public API instance", t, func() {
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
-		graphDBMock := &mock.IGraphMock{}
+		graphDBMock := &storeMock.GraphMock{}
 		dcMock := &mock.IDatasetClientMock{}
 		cMock := &mock.CantabularClientMock{}
 		pMock := &auth.NopHandler{}
 		enableURLRewriting := false
-		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, pMock, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, enableURLRewriting)
 
 		Convey("When created the following routes should have been added", func() {
 			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations", "GET"), ShouldBeTrue)
+			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/metadata.json", "GET"), ShouldBeTrue)
+			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/query", "POST"), ShouldBeTrue)
 		})
 	})
 
 	Convey("Given a private API instance", t, func() {
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
-		graphDBMock := &mock.IGraphMock{}
+		graphDBMock := &storeMock.GraphMock{}
 		dcMock := &mock.IDatasetClientMock{}
 		cMock := &mock.CantabularClientMock{}
 		pMock := &mock.IAuthHandlerMock{
@@ -64,10 +68,78 @@ func TestSetup(t *testing.T) {
 			},
 		}
 		enableURLRewriting := false
-		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, pMock, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, enableURLRewriting)
 
 		Convey("When created the following routes should have been added", func() {
 			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations", "GET"), ShouldBeTrue)
+			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/metadata.json", "GET"), ShouldBeTrue)
+			So(hasRoute(api.Router, "/datasets/{dataset_id}/editions/{edition}/versions/{version}/observations/query", "POST"), ShouldBeTrue)
+		})
+
+		Convey("When created with private endpoints enabled every route should require read permissions", func() {
+			privateCfg := *cfg
+			privateCfg.EnablePrivateEndpoints = true
+			GetAPIWithMocks(&privateCfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, enableURLRewriting)
+
+			So(pMock.RequireCalls(), ShouldHaveLength, 3)
+			for _, call := range pMock.RequireCalls() {
+				So(call.Required, ShouldResemble, auth.Permissions{Read: true})
+			}
+		})
+	})
+	Convey("Given an API instance in dev mode with private endpoints enabled", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.EnableDevMode = true
+		cfg.EnablePrivateEndpoints = true
+		dcMock := &mock.IDatasetClientMock{}
+		cMock := &mock.CantabularClientMock{}
+		pMock := &mock.IAuthHandlerMock{
+			RequireFunc: func(required auth.Permissions, handler http.HandlerFunc) http.HandlerFunc {
+				return handler
+			},
+		}
+
+		Convey("When created with an observation store that V4 files can be imported into", func() {
+			api := GetAPIWithStore(cfg, store.NewMemoryStore(), dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, false)
+
+			Convey("Then the route to import the V4 file of an instance should have been added", func() {
+				So(hasRoute(api.Router, "/instances/{instance_id}/observations", "PUT"), ShouldBeTrue)
+			})
+
+			Convey("Then the route should require update permissions", func() {
+				So(pMock.RequireCalls(), ShouldHaveLength, 4)
+				So(pMock.RequireCalls()[3].Required, ShouldResemble, auth.Permissions{Update: true})
+			})
+		})
+
+		Convey("When created with the graph database as the observation store", func() {
+			api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, false)
+
+			Convey("Then the route to import the V4 file of an instance should not have been added", func() {
+				So(hasRoute(api.Router, "/instances/{instance_id}/observations", "PUT"), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given an API instance in dev mode without private endpoints enabled", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.EnableDevMode = true
+		api := GetAPIWithStore(cfg, store.NewMemoryStore(), &mock.IDatasetClientMock{}, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+
+		Convey("Then the route to import the V4 file of an instance should not have been added", func() {
+			So(hasRoute(api.Router, "/instances/{instance_id}/observations", "PUT"), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given an API instance that is not in dev mode", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		api := GetAPIWithStore(cfg, store.NewMemoryStore(), &mock.IDatasetClientMock{}, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+
+		Convey("Then the route to import the V4 file of an instance should not have been added", func() {
+			So(hasRoute(api.Router, "/instances/{instance_id}/observations", "PUT"), ShouldBeFalse)
 		})
 	})
 }
@@ -76,12 +148,12 @@ func TestClose(t *testing.T) {
 	Convey("Given an API instance", t, func() {
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
-		graphDBMock := &mock.IGraphMock{}
+		graphDBMock := &storeMock.GraphMock{}
 		dcMock := &mock.IDatasetClientMock{}
 		cMock := &mock.CantabularClientMock{}
 		pMock := &auth.NopHandler{}
 		enableURLRewriting := false
-		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, pMock, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, enableURLRewriting)
 
 		Convey("When the api is closed any dependencies are closed also", func() {
 			err := api.Close(testContext)
@@ -98,11 +170,19 @@ func hasRoute(r *mux.Router, path, method string) bool {
 }
 
 // GetAPIWithMocks also used in other tests
-func GetAPIWithMocks(cfg *config.Config, graphDBMock api.IGraph, dcMock api.IDatasetClient, cMock api.CantabularClient, pMock api.IAuthHandler, enableURLRewriting bool) *api.API {
+func GetAPIWithMocks(cfg *config.Config, graphDBMock store.Graph, dcMock api.IDatasetClient, cMock api.CantabularClient, hMock api.IHierarchyClient, pMock api.IAuthHandler, enableURLRewriting bool) *api.API {
+	return GetAPIWithStore(cfg, store.NewGraphStore(graphDBMock), dcMock, cMock, hMock, pMock, enableURLRewriting)
+}
+
+func GetAPIWithStore(cfg *config.Config, observationStore store.ObservationStore, dcMock api.IDatasetClient, cMock api.CantabularClient, hMock api.IHierarchyClient, pMock api.IAuthHandler, enableURLRewriting bool) *api.API {
+	return GetAPIWithRegistry(cfg, store.NewSingleStoreRegistry(store.GraphBackend, observationStore), dcMock, cMock, hMock, pMock, enableURLRewriting)
+}
+
+func GetAPIWithRegistry(cfg *config.Config, observationStores *store.Registry, dcMock api.IDatasetClient, cMock api.CantabularClient, hMock api.IHierarchyClient, pMock api.IAuthHandler, enableURLRewriting bool) *api.API {
 	mu.Lock()
 	defer mu.Unlock()
 	cfg.ServiceAuthToken = testServiceAuthToken
-	return api.Setup(testContext, mux.NewRouter(), cfg, graphDBMock, dcMock, cMock, pMock, enableURLRewriting, codeListAPIURL, datasetAPIURL, observationAPIURL)
+	return api.Setup(testContext, mux.NewRouter(), cfg, observationStores, dcMock, cMock, hMock, pMock, pMock, enableURLRewriting, codeListAPIURL, datasetAPIURL, observationAPIURL)
 }
 
 func assertInternalServerErr(w *httptest.ResponseRecorder) {
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// arrowBatchSize is the number of observations of each record batch, or parquet row group
+const arrowBatchSize = 1000
+
+// dictionaryType is the type of the dimension code and label columns, which repeat few values
+var dictionaryType = &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+
+// observationRecords builds arrow record batches from the page of observations, with the same columns as the CSV
+// rendering: dictionary encoded dimension codes and labels, a numeric observation column that is null when the
+// observation is not a number, such as a sparsity marker, and string metadata columns that are null when empty
+type observationRecords struct {
+	result  *observationsResult
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+}
+
+func newObservationRecords(result *observationsResult) *observationRecords {
+	header := models.CSVHeader(result.rows.header, result.rows.dimensionOffset)
+
+	observationColumn := 0
+	for i, column := range header {
+		if column == models.CSVObservationColumn {
+			observationColumn = i
+		}
+	}
+
+	fields := make([]arrow.Field, len(header))
+	for i, column := range header {
+		switch {
+		case i < observationColumn:
+			fields[i] = arrow.Field{Name: column, Type: dictionaryType}
+		case i == observationColumn:
+			fields[i] = arrow.Field{Name: column, Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+		default:
+			fields[i] = arrow.Field{Name: column, Type: arrow.BinaryTypes.String, Nullable: true}
+		}
+	}
+
+	doc := result.doc
+	metadata := arrow.NewMetadata(
+		[]string{"dataset", "unit_of_measure", "self", "version"},
+		[]string{result.datasetDoc.Title, doc.UnitOfMeasure, doc.Links.Self.URL, doc.Links.Version.URL},
+	)
+	schema := arrow.NewSchema(fields, &metadata)
+
+	return &observationRecords{
+		result:  result,
+		schema:  schema,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}
+}
+
+// next returns a record batch of up to arrowBatchSize observations, or io.EOF when there are no more observations
+func (o *observationRecords) next() (arrow.RecordBatch, error) {
+	n := 0
+	for n < arrowBatchSize {
+		row, err := o.result.page.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for i, value := range models.CSVRow(row, o.result.rows.dimensionOffset) {
+			switch b := o.builder.Field(i).(type) {
+			case *array.BinaryDictionaryBuilder:
+				if err := b.AppendString(value); err != nil {
+					return nil, err
+				}
+			case *array.Float64Builder:
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					b.Append(v)
+				} else {
+					b.AppendNull()
+				}
+			case *array.StringBuilder:
+				if value == "" {
+					b.AppendNull()
+				} else {
+					b.Append(value)
+				}
+			}
+		}
+		n++
+	}
+
+	if n == 0 {
+		return nil, io.EOF
+	}
+
+	return o.builder.NewRecordBatch(), nil
+}
+
+func (o *observationRecords) release() {
+	o.builder.Release()
+}
+
+// writeObservationsArrow writes the page of observations as an arrow IPC stream, writing a record batch
+// as soon as it has been read. If reading the observations fails once the stream has been started, the
+// stream is left without its end-of-stream marker, and the failure is reported in the stream error trailer.
+func writeObservationsArrow(w io.Writer, result *observationsResult) error {
+	records := newObservationRecords(result)
+	defer records.release()
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(records.schema))
+
+	for {
+		record, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write(record)
+		record.Release()
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// writeObservationsParquet writes the page of observations as a parquet file, with a row group for each record
+// batch. If reading the observations fails once the file has been started, the file is left without its footer,
+// and the failure is reported in the stream error trailer.
+func writeObservationsParquet(w io.Writer, result *observationsResult) error {
+	records := newObservationRecords(result)
+	defer records.release()
+
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy), parquet.WithDictionaryDefault(true))
+	writer, err := pqarrow.NewFileWriter(records.schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write(record)
+		record.Release()
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+// optionsCache holds the options of the dimensions of versions obtained from dataset API, so that they are not requested
+// again for every query of a version until they expire. A cache without a time to live does not hold any options.
+type optionsCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]optionsCacheEntry
+}
+
+type optionsCacheEntry struct {
+	options []dataset.Option
+	expiry  time.Time
+}
+
+func newOptionsCache(ttl time.Duration) *optionsCache {
+	return &optionsCache{
+		ttl:     ttl,
+		entries: make(map[string]optionsCacheEntry),
+	}
+}
+
+// optionsCacheKey identifies a dimension of a version of a dataset in the cache
+func optionsCacheKey(event *models.FilterSubmitted, dimensionName string) string {
+	return event.DatasetID + "/" + event.Edition + "/" + event.Version + "/" + dimensionName
+}
+
+// get returns the options of the dimension of the version, if they are held and have not expired
+func (c *optionsCache) get(event *models.FilterSubmitted, dimensionName string) ([]dataset.Option, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[optionsCacheKey(event, dimensionName)]
+	if !found || time.Now().After(entry.expiry) {
+		return nil, false
+	}
+
+	return entry.options, true
+}
+
+// set holds the options of the dimension of the version until they expire, and drops the options that have expired
+func (c *optionsCache) set(event *models.FilterSubmitted, dimensionName string, options []dataset.Option) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiry) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[optionsCacheKey(event, dimensionName)] = optionsCacheEntry{
+		options: options,
+		expiry:  now.Add(c.ttl),
+	}
+}
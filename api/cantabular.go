@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/cantabular"
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	dperrors "github.com/ONSdigital/dp-api-clients-go/v2/errors"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/dp-observation-api/store"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// cantabularDatasetTypePrefix is the prefix of the types of the datasets whose observations are held by Cantabular,
+// such as cantabular_table and cantabular_flexible_table, which are the census datasets
+const cantabularDatasetTypePrefix = "cantabular"
+
+// isCantabularDataset returns whether the observations of the dataset are held by Cantabular rather than the graph database
+func isCantabularDataset(datasetDoc dataset.DatasetDetails) bool {
+	return strings.HasPrefix(datasetDoc.Type, cantabularDatasetTypePrefix)
+}
+
+// getCantabularObservationRows queries the table of observations that match the provided query parameters from Cantabular,
+// along with the dimensions that need to be identified against each observation. The version is based on a Cantabular
+// dataset, whose variables are the IDs of the version dimensions. The cells of the table are read as rows of observations,
+// so that they are paged, filtered, sorted and rendered like the observations streamed from the observation store.
+func (api *API) getCantabularObservationRows(ctx context.Context, versionDoc *dataset.Version, queryParameters map[string][]string, logData log.Data, event *models.FilterSubmitted) (*observationRows, map[string]struct{}, error) {
+	if versionDoc.IsBasedOn == nil || versionDoc.IsBasedOn.ID == "" {
+		log.Error(ctx, "get observations: missing cantabular dataset in version doc", errs.ErrMissingCantabularDataset, logData)
+		return nil, nil, errs.ErrMissingCantabularDataset
+	}
+
+	req := cantabular.StaticDatasetQueryRequest{
+		Dataset: versionDoc.IsBasedOn.ID,
+	}
+
+	var wildcardParameters []string
+	observationDimensions := make(map[string]struct{})
+	dimensionNames := make(map[string]string)
+
+	// the variables of the table are requested in the order of the version dimensions
+	for _, versionDimension := range versionDoc.Dimensions {
+		options, found := queryParameters[versionDimension.Name]
+		if !found {
+			continue
+		}
+
+		variable := versionDimension.ID
+		if variable == "" {
+			variable = versionDimension.Name
+		}
+		dimensionNames[variable] = versionDimension.Name
+		req.Variables = append(req.Variables, variable)
+
+		if options[0] == "*" {
+			wildcardParameters = append(wildcardParameters, versionDimension.Name)
+			observationDimensions[versionDimension.Name] = struct{}{}
+			continue
+		}
+
+		if len(options) > 1 {
+			observationDimensions[versionDimension.Name] = struct{}{}
+		}
+
+		req.Filters = append(req.Filters, cantabular.Filter{
+			Variable: variable,
+			Codes:    options,
+		})
+	}
+
+	if len(observationDimensions) > 0 {
+		if err := api.checkObservationCount(ctx, event, queryParameters, wildcardParameters, logData); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	logData["cantabular_query"] = req
+	log.Info(ctx, "cantabular query built to retrieve observations", logData)
+
+	query, err := api.cantabularClient.StaticDatasetQuery(ctx, req)
+	if err != nil {
+		// a table that cannot be returned for the selected options is reported as a bad request by the client
+		if dperrors.StatusCode(err) == http.StatusBadRequest {
+			return nil, nil, errs.ErrorCantabularTable(err.Error())
+		}
+		return nil, nil, err
+	}
+
+	table := &query.Dataset.Table
+	if len(table.Values) == 0 || len(table.Dimensions) == 0 {
+		return nil, nil, errs.ErrObservationsNotFound
+	}
+
+	rows := newObservationRows(newCantabularRowReader(ctx, table, dimensionNames))
+
+	return rows, observationDimensions, nil
+}
+
+// cantabularRowReader reads the cells of a Cantabular table as rows of observations without metadata: a row for each
+// cell, holding its count along with the code and label of each of its categories
+type cantabularRowReader struct {
+	table    *cantabular.Table
+	header   store.Header
+	iterator *cantabular.Iterator
+	cell     int
+}
+
+func newCantabularRowReader(ctx context.Context, table *cantabular.Table, dimensionNames map[string]string) *cantabularRowReader {
+	var header store.Header
+	for _, dimension := range table.Dimensions {
+		name, found := dimensionNames[dimension.Variable.Name]
+		if !found {
+			name = dimension.Variable.Name
+		}
+		header.Dimensions = append(header.Dimensions, store.Dimension{CodeList: name + "_code", Name: name})
+	}
+
+	return &cantabularRowReader{
+		table:    table,
+		header:   header,
+		iterator: cantabular.Dimensions(table.Dimensions).NewIterator(ctx),
+	}
+}
+
+// Header returns the header of the rows, which has a dimension for each variable of the table
+func (r *cantabularRowReader) Header() store.Header {
+	return r.header
+}
+
+// Read returns the next row of the table, or io.EOF when there are no more rows
+func (r *cantabularRowReader) Read() (*store.Row, error) {
+	if r.cell >= len(r.table.Values) || r.iterator.End() {
+		return nil, io.EOF
+	}
+
+	row := &store.Row{
+		Observation: strconv.FormatFloat(float64(r.table.Values[r.cell]), 'f', -1, 32),
+		Options:     make([]store.Option, 0, len(r.table.Dimensions)),
+	}
+	for i := range r.table.Dimensions {
+		category, err := r.iterator.CategoryAtColumn(i)
+		if err != nil {
+			return nil, err
+		}
+		row.Options = append(row.Options, store.Option{Code: category.Code, Label: category.Label})
+	}
+
+	r.cell++
+	if err := r.iterator.Next(); err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+// Close does nothing, as the whole table has been read from Cantabular
+func (r *cantabularRowReader) Close(context.Context) error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	csvwMetadataPath        = "/metadata.json"
+	csvwMetadataContentType = "application/csvm+json"
+)
+
+// writeObservationsCSV writes the page of observations as CSV, with a code and a label column for each dimension
+// followed by the observation and its metadata columns, and the derived value of the observation if one is requested,
+// writing each row as it is read. If reading the observations fails once the CSV has been started, the rows written so
+// far are flushed and the failure is reported in the stream error trailer.
+func writeObservationsCSV(w io.Writer, result *observationsResult) error {
+	csvWriter := csv.NewWriter(w)
+	dimensionOffset := result.rows.dimensionOffset
+
+	header := append(models.CSVHeader(result.rows.header, dimensionOffset), result.csvDerivedHeader()...)
+
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for {
+		row, err := result.page.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			csvWriter.Flush()
+			return err
+		}
+
+		var derivedColumns []string
+		if result.derived != nil {
+			row, derivedColumns = result.derived.splitRow(row)
+		}
+
+		csvRow := append(models.CSVRow(row, dimensionOffset), derivedColumns...)
+
+		if err := csvWriter.Write(csvRow); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// csvDerivedHeader returns the columns of the derived values that follow the observation and its metadata in the CSV
+// rendering of the observations, if derived values are requested
+func (result *observationsResult) csvDerivedHeader() []string {
+	if result.derived == nil {
+		return nil
+	}
+	return result.derived.derivation.csvHeader()
+}
+
+// csvwMetadataLink returns the value of the Link header referring to the CSV on the Web metadata
+// document that describes the CSV observations found at the provided self link
+func csvwMetadataLink(selfURL string) string {
+	observationsURL, rawQuery, _ := strings.Cut(selfURL, "?")
+	metadataURL := observationsURL + csvwMetadataPath
+	if rawQuery != "" {
+		metadataURL += "?" + rawQuery
+	}
+
+	return "<" + metadataURL + `>; rel="describedby"; type="` + csvwMetadataContentType + `"`
+}
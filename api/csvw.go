@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/dp-net/v2/links"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// getObservationsMetadata returns the CSV on the Web metadata document describing the CSV observations
+// selected by the same query parameters, without querying the observations
+func (api *API) getObservationsMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	datasetID := vars["dataset_id"]
+	edition := vars["edition"]
+	version := vars["version"]
+
+	logData := log.Data{"dataset_id": datasetID, "edition": edition, "version": version}
+
+	metadata, err := api.doGetObservationsMetadata(r, datasetID, edition, version, logData)
+	if err != nil {
+		handleObservationsErrorType(ctx, w, err, logData)
+		return
+	}
+
+	b, err := marshalJSON(metadata)
+	if err != nil {
+		handleObservationsErrorType(ctx, w, err, logData)
+		return
+	}
+
+	w.Header().Set("Content-Type", csvwMetadataContentType)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "get observations metadata endpoint: failed to write metadata", err, logData)
+		return
+	}
+
+	log.Info(ctx, "get observations metadata endpoint: successfully retrieved metadata of the observations of a version", logData)
+}
+
+// doGetObservationsMetadata describes the CSV observations selected by the request, as written by writeObservationsCSV,
+// without querying the observations. Their columns are those of the header row of the version's V4 file, or of a V4 file
+// with no metadata columns and the dimensions of the version if it is not known, along with the columns of their derived
+// values if a derivation is requested.
+func (api *API) doGetObservationsMetadata(r *http.Request, datasetID, edition, version string, logData log.Data) (*models.CSVWMetadata, error) {
+	ctx := r.Context()
+
+	query, err := api.getObservationsQuery(ctx, datasetID, edition, version, r, nil, logData)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit, err := ExtractPaginationParameters(r.URL.Query(), query.validDimensionNames, api.cfg.DefaultObservationLimit, api.cfg.MaxObservationLimit)
+	if err != nil {
+		log.Error(ctx, "get observations metadata: error extracting pagination parameters", err, logData)
+		return nil, err
+	}
+
+	// the metadata only describes the CSV format
+	rawQuery := r.URL.RawQuery
+	if value, found := getReservedQueryParameter(r.URL.Query(), query.validDimensionNames, formatParameter); !found {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += formatParameter + "=" + formatCSV
+	} else if !strings.EqualFold(value, formatCSV) {
+		err = errs.ErrorInvalidFormat(value, []string{formatCSV})
+		log.Error(ctx, "get observations metadata: metadata can only be provided for csv", err, logData)
+		return nil, err
+	}
+
+	derivation, err := getDerivation(r.URL.Query(), query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations metadata: error determining the derivation of the observations", err, logData)
+		return nil, err
+	}
+
+	var derivedHeader []string
+	if derivation != nil {
+		derivedHeader = derivation.csvHeader()
+	}
+
+	doc := models.CreateObservationsDoc(api.cfg.ObservationAPIURL, api.cfg.DatasetAPIURL, rawQuery, datasetID, edition, version, &query.versionDoc, query.datasetDoc, query.queryParameters, offset, limit)
+
+	if api.enableURLRewriting {
+		if err = api.rewriteLinks(r, doc, logData); err != nil {
+			return nil, err
+		}
+
+		codeListLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.codeListAPIURL)
+		for i := range query.versionDoc.Dimensions {
+			dimension := &query.versionDoc.Dimensions[i]
+			if dimension.URL == "" {
+				continue
+			}
+
+			if dimension.URL, err = codeListLinksBuilder.BuildLink(dimension.URL); err != nil {
+				logData["link"] = dimension.URL
+				return nil, errors.WithMessage(err, "failed to rewrite code list link")
+			}
+		}
+	}
+
+	v4Header := query.versionDoc.CSVHeader
+	if len(v4Header) == 0 {
+		v4Header = []string{"v4_0"}
+		for _, dimension := range query.versionDoc.Dimensions {
+			v4Header = append(v4Header, dimension.Name+"_code", dimension.Name)
+		}
+	}
+
+	dimensionOffset, err := GetDimensionOffsetInHeaderRow(v4Header)
+	if err != nil {
+		logData["version_header"] = v4Header
+		log.Error(ctx, "get observations metadata: unable to distinguish headers from version document", err, logData)
+		return nil, err
+	}
+
+	return models.CreateCSVWMetadata(doc.Links.Self.URL, v4Header, dimensionOffset, derivedHeader, &query.versionDoc, &query.datasetDoc), nil
+}
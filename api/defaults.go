@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/hierarchy"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// selectDefaultOptions selects the default option of each of the provided dimensions that have not been set in the query
+// parameters, and returns the dimensions that have been defaulted. An error is returned listing the dimensions that do
+// not have a default option, which need to be set in the query parameters.
+func (api *API) selectDefaultOptions(ctx context.Context, instanceID string, event *models.FilterSubmitted, queryParameters map[string][]string, missingDimensions []string, logData log.Data) ([]string, error) {
+	var defaultedDimensions, missingQueryParameters []string
+
+	for _, dimension := range missingDimensions {
+		option, err := api.getDefaultOption(ctx, instanceID, event, dimension)
+		if err != nil {
+			logData["dimension"] = dimension
+			return nil, err
+		}
+
+		if option == "" {
+			missingQueryParameters = append(missingQueryParameters, dimension)
+			continue
+		}
+
+		queryParameters[dimension] = []string{option}
+		defaultedDimensions = append(defaultedDimensions, dimension)
+	}
+
+	if len(missingQueryParameters) > 0 {
+		return nil, errs.ErrorMissingQueryParameters(missingQueryParameters)
+	}
+
+	if len(defaultedDimensions) > 0 {
+		logData["defaulted_dimensions"] = defaultedDimensions
+	}
+
+	return defaultedDimensions, nil
+}
+
+// getDefaultOption returns the option selected by a dimension that has not been set in the query parameters, which is
+// the first of:
+//   - the option configured for dimensions of that name, if the dimension has that option
+//   - the only option of the dimension
+//   - the root of the hierarchy of the dimension, which is the total of the other options, if it has data
+//
+// An empty option is returned if the dimension does not have a default option.
+func (api *API) getDefaultOption(ctx context.Context, instanceID string, event *models.FilterSubmitted, dimension string) (string, error) {
+	// the options are cached, as they are listed again to validate the selected options
+	options, err := api.getDimensionOptions(ctx, event, dimension)
+	if err != nil {
+		return "", err
+	}
+
+	if option, found := api.cfg.DefaultDimensionOptions[dimension]; found {
+		for _, o := range options {
+			if o.Option == option {
+				return option, nil
+			}
+		}
+	}
+
+	if len(options) == 1 {
+		return options[0].Option, nil
+	}
+
+	root, err := api.hierarchyClient.GetRoot(ctx, instanceID, dimension)
+	if err != nil {
+		// the dimension does not have a hierarchy
+		if hierarchyErr, ok := err.(*hierarchy.ErrInvalidHierarchyAPIResponse); ok && hierarchyErr.Code() == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if !root.HasData {
+		return "", nil
+	}
+
+	return root.Links.Code.ID, nil
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	deriveParameter = "derive"
+	baseParameter   = "base"
+
+	deriveChange    = "change"
+	derivePctChange = "pct_change"
+	deriveIndex     = "index"
+
+	// timeDimension is the dimension whose periods the derived values are calculated between
+	timeDimension = "time"
+)
+
+// supportedDerivations are the values accepted by the derive query parameter
+var supportedDerivations = []string{deriveChange, derivePctChange, deriveIndex}
+
+// derivation is a calculation of a value from each observation and the observation of another period of its series:
+// the previous period for a change, or the base period for an index
+type derivation struct {
+	function string
+	base     string
+}
+
+// getDerivation returns the derivation requested by the derive and base query parameters, unless they are version
+// dimensions, or nil if no derivation has been requested. A base period must be provided for an index, and only for it.
+func getDerivation(urlQuery url.Values, validDimensions []string) (*derivation, error) {
+	value, found := getReservedQueryParameter(urlQuery, validDimensions, deriveParameter)
+	base, baseFound := getReservedQueryParameter(urlQuery, validDimensions, baseParameter)
+	base = strings.TrimSpace(base)
+
+	if !found {
+		if baseFound {
+			return nil, errs.ErrorInvalidDerivation(baseParameter+"="+base, "a base period can only be provided with derive=index")
+		}
+		return nil, nil
+	}
+
+	function := strings.ToLower(strings.TrimSpace(value))
+	if !containsOption(supportedDerivations, function) {
+		return nil, errs.ErrorInvalidDerivation(value, "the derivation must be one of: ["+strings.Join(supportedDerivations, " ")+"]")
+	}
+
+	switch {
+	case function == deriveIndex && base == "":
+		return nil, errs.ErrorInvalidDerivation(value, "a base period must be provided with the base query parameter")
+	case function != deriveIndex && baseFound:
+		return nil, errs.ErrorInvalidDerivation(baseParameter+"="+base, "a base period can only be provided with derive=index")
+	}
+
+	return &derivation{function: function, base: base}, nil
+}
+
+// csvHeader returns the columns of the derived value of each observation, and of the reason it could not be calculated
+func (d *derivation) csvHeader() []string {
+	return []string{d.function, d.function + "_status"}
+}
+
+// getPeriodRanks returns the position of each option of the time dimension in the order of the dimension, which is
+// chronological when its options are time codes, checking that the base period of an index is one of them
+func (api *API) getPeriodRanks(ctx context.Context, event *models.FilterSubmitted, d *derivation) (map[string]int, error) {
+	options, err := api.getOrderedDimensionOptions(ctx, event, timeDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int, len(options))
+	for rank, option := range options {
+		ranks[option.Option] = rank
+	}
+
+	if _, found := ranks[d.base]; d.function == deriveIndex && !found {
+		return nil, errs.ErrorInvalidDerivation(baseParameter+"="+d.base, "the base period is not an option of the "+timeDimension+" dimension")
+	}
+
+	return ranks, nil
+}
+
+// derivedRows iterates over rows of observations in the order they are read, calculating the derived value of each
+// observation from the observation of another period of the same series, which is the set of observations sharing the
+// options of every dimension other than time. Every row has to be read before the first one is returned, so the rows
+// are read on the first call to Next. The derived value and its status are appended to each row as columns, in the
+// order of csvHeader, so that they follow the row however the rows are filtered, sorted and paged afterwards. Nothing is
+// calculated if the stream of observations was capped, as the series would be missing observations that were not read.
+type derivedRows struct {
+	rows        rowIterator
+	stream      *observationRows
+	derivation  *derivation
+	header      []string
+	offset      int
+	periodRanks map[string]int
+	buffered    [][]string
+	read        bool
+}
+
+// derivedColumnCount is the number of columns appended to each row by derivedRows
+const derivedColumnCount = 2
+
+// Next returns the next row, with its derived value and status appended, or io.EOF when there are no more rows
+func (d *derivedRows) Next() ([]string, error) {
+	if !d.read {
+		if err := d.derive(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.buffered) == 0 {
+		return nil, io.EOF
+	}
+
+	row := d.buffered[0]
+	d.buffered = d.buffered[1:]
+	return row, nil
+}
+
+// derive reads every row, and calculates the derived value of each of them
+func (d *derivedRows) derive() error {
+	for {
+		row, err := d.rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		d.buffered = append(d.buffered, row)
+	}
+	d.read = true
+
+	if d.stream.capped() {
+		d.buffered = nil
+		return errs.ErrorTooManyDerivedObservations(d.stream.limit)
+	}
+
+	timeColumn := -1
+	var seriesColumns []int
+	for i := d.offset + 2; i < len(d.header); i += 2 {
+		if strings.ToLower(d.header[i]) == timeDimension {
+			timeColumn = i - 1
+		} else {
+			seriesColumns = append(seriesColumns, i-1)
+		}
+	}
+
+	seriesKey := func(row []string) string {
+		codes := make([]string, len(seriesColumns))
+		for i, column := range seriesColumns {
+			codes[i] = row[column]
+		}
+		return strings.Join(codes, "\x00")
+	}
+
+	periods := make(map[string]map[int][]string)
+	for _, row := range d.buffered {
+		key := seriesKey(row)
+		if periods[key] == nil {
+			periods[key] = make(map[int][]string)
+		}
+		periods[key][d.rank(row, timeColumn)] = row
+	}
+
+	// the derived columns are appended to copies of the rows, as the rows are the references of the other rows of their
+	// series until every derived value has been calculated
+	derived := make([][]string, len(d.buffered))
+	for i, row := range d.buffered {
+		series := periods[seriesKey(row)]
+
+		reference, found := []string(nil), false
+		if rank := d.rank(row, timeColumn); d.derivation.function == deriveIndex {
+			reference, found = series[d.periodRanks[d.derivation.base]]
+		} else if rank > 0 {
+			reference, found = series[rank-1]
+		}
+
+		derivedRow := make([]string, len(row), len(row)+derivedColumnCount)
+		copy(derivedRow, row)
+		derived[i] = append(derivedRow, csvColumns(d.calculate(row, reference, found))...)
+	}
+	d.buffered = derived
+
+	return nil
+}
+
+// rank returns the position of the period of the row in the order of the periods, or -1 if the period is not listed, in
+// which case it has no previous period
+func (d *derivedRows) rank(row []string, timeColumn int) int {
+	if timeColumn < 0 {
+		return -1
+	}
+	if rank, found := d.periodRanks[row[timeColumn]]; found {
+		return rank
+	}
+	return -1
+}
+
+// calculate returns the derived value of an observation from the observation of the previous or base period of its
+// series, along with why no value could be calculated when the observation of either period is missing, suppressed or
+// otherwise not a number, or when a percentage change or an index would divide by zero
+func (d *derivedRows) calculate(row, reference []string, found bool) *models.DerivedValue {
+	referenceName := "predecessor"
+	if d.derivation.function == deriveIndex {
+		referenceName = "base"
+	}
+
+	value, isNumber := parseValue(row[0])
+	if !isNumber {
+		return &models.DerivedValue{Status: "no_value"}
+	}
+
+	if !found {
+		return &models.DerivedValue{Status: "missing_" + referenceName}
+	}
+
+	referenceValue, isNumber := parseValue(reference[0])
+	if !isNumber {
+		return &models.DerivedValue{Status: "suppressed_" + referenceName}
+	}
+
+	var derived float64
+	switch d.derivation.function {
+	case deriveChange:
+		derived = value - referenceValue
+	case derivePctChange, deriveIndex:
+		if referenceValue == 0 {
+			return &models.DerivedValue{Status: "zero_" + referenceName}
+		}
+
+		if d.derivation.function == derivePctChange {
+			derived = (value - referenceValue) / referenceValue * 100
+		} else {
+			derived = value / referenceValue * 100
+		}
+	}
+
+	return &models.DerivedValue{Value: &derived}
+}
+
+// splitRow splits a row returned by Next into the columns of the observation and the columns of its derived value
+func (d *derivedRows) splitRow(row []string) (observationRow, derivedColumns []string) {
+	return row[:len(row)-derivedColumnCount], row[len(row)-derivedColumnCount:]
+}
+
+// csvColumns returns the derived value, and the reason it could not be calculated, in the order of csvHeader. The value
+// is formatted with the fewest digits that parse back to it exactly.
+func csvColumns(derived *models.DerivedValue) []string {
+	if derived.Value == nil {
+		return []string{"", derived.Status}
+	}
+	return []string{strconv.FormatFloat(*derived.Value, 'f', -1, 64), derived.Status}
+}
+
+// derivedValue returns the derived value held by the derived columns of a row, in the order of csvHeader
+func derivedValue(derivedColumns []string) *models.DerivedValue {
+	derived := &models.DerivedValue{Status: derivedColumns[1]}
+	if value, err := strconv.ParseFloat(derivedColumns[0], 64); err == nil {
+		derived.Value = &value
+	}
+	return derived
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+)
+
+const (
+	formatParameter = "format"
+
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatJSONStat = "jsonstat"
+	formatSDMXJSON = "sdmx-json"
+	formatSDMXML   = "sdmx-ml"
+	formatXLSX     = "xlsx"
+	formatArrow    = "arrow"
+	formatParquet  = "parquet"
+)
+
+// supportedFormats are the values accepted by the format query parameter
+var supportedFormats = []string{formatJSON, formatCSV, formatJSONStat, formatSDMXJSON, formatSDMXML, formatXLSX, formatArrow, formatParquet}
+
+// formatMediaTypes maps the media types that can be requested in the Accept header to their format
+var formatMediaTypes = map[string]string{
+	"application/json":      formatJSON,
+	"application/*":         formatJSON,
+	"*/*":                   formatJSON,
+	"text/csv":              formatCSV,
+	"text/*":                formatCSV,
+	"application/json+stat": formatJSONStat,
+
+	"application/vnd.sdmx.data+json":       formatSDMXJSON,
+	"application/vnd.sdmx.genericdata+xml": formatSDMXML,
+
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": formatXLSX,
+
+	"application/vnd.apache.arrow.stream": formatArrow,
+	"application/vnd.apache.parquet":      formatParquet,
+}
+
+// formatContentTypes maps each format to the content type of its responses
+var formatContentTypes = map[string]string{
+	formatJSON:     "application/json",
+	formatCSV:      "text/csv; charset=utf-8",
+	formatJSONStat: "application/json+stat",
+	formatSDMXJSON: "application/vnd.sdmx.data+json; version=1.0.0",
+	formatSDMXML:   "application/vnd.sdmx.genericdata+xml; version=2.1",
+	formatXLSX:     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	formatArrow:    "application/vnd.apache.arrow.stream",
+	formatParquet:  "application/vnd.apache.parquet",
+}
+
+// observationsRenderer renders a page of observations once all of them have been read
+type observationsRenderer func(result *observationsResult) ([]byte, error)
+
+// bufferedRenderers maps the formats that cannot be written as the observations are read to their renderer.
+// Any other format is streamed.
+var bufferedRenderers = map[string]observationsRenderer{
+	formatJSONStat: renderJSONStat,
+	formatSDMXJSON: renderSDMXJSON,
+	formatSDMXML:   renderSDMXML,
+	formatXLSX:     renderXLSX,
+}
+
+// getFormat determines the format of the response from the format query parameter, unless it is a version dimension,
+// or otherwise from the media types accepted by the request. JSON is returned if no supported format has been requested.
+func getFormat(r *http.Request, validDimensions []string) (string, error) {
+	if value, found := getReservedQueryParameter(r.URL.Query(), validDimensions, formatParameter); found {
+		format := strings.ToLower(value)
+		if !containsOption(supportedFormats, format) {
+			return "", errs.ErrorInvalidFormat(value, supportedFormats)
+		}
+		return format, nil
+	}
+
+	for _, mediaType := range acceptedMediaTypes(r.Header.Get("Accept")) {
+		if format, found := formatMediaTypes[mediaType]; found {
+			return format, nil
+		}
+	}
+
+	return formatJSON, nil
+}
+
+// acceptedMediaTypes parses the provided Accept header and returns its media types by order of preference
+func acceptedMediaTypes(accept string) []string {
+	type acceptedMediaType struct {
+		mediaType string
+		quality   float64
+	}
+
+	var accepted []acceptedMediaType
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, found := params["q"]; found {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality <= 0 {
+				continue
+			}
+		}
+
+		accepted = append(accepted, acceptedMediaType{mediaType: mediaType, quality: quality})
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+
+	mediaTypes := make([]string, len(accepted))
+	for i := range accepted {
+		mediaTypes[i] = accepted[i].mediaType
+	}
+	return mediaTypes
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/hierarchy"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+)
+
+const (
+	childrenSelector    = "children"
+	descendantsSelector = "descendants"
+	levelSelector       = "level"
+)
+
+// hierarchySelector matches the options that select codes from the hierarchy of a dimension, rather than a single code:
+// children(code) selects the codes directly below a code, descendants(code) every code below it, and level(n) the codes
+// n levels below the root of the hierarchy, the root itself being level 0
+var hierarchySelector = regexp.MustCompile(`^(` + childrenSelector + `|` + descendantsSelector + `|` + levelSelector + `)\((.*)\)$`)
+
+// getHierarchyOptions returns the codes of the dimension hierarchy selected by a hierarchy selector, in hierarchy
+// order. Only the codes that have data are selected, and a selector that selects none of them is rejected.
+func (api *API) getHierarchyOptions(ctx context.Context, instanceID, dimension, option string, match []string) ([]string, error) {
+	codes, err := api.getSelectedCodes(ctx, instanceID, dimension, option, match[1], strings.TrimSpace(match[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(codes) == 0 {
+		return nil, errs.ErrorInvalidSelector(option, "it does not select any options with observations")
+	}
+
+	return codes, nil
+}
+
+// getSelectedCodes returns the codes of the dimension hierarchy selected by a selector with the provided argument
+func (api *API) getSelectedCodes(ctx context.Context, instanceID, dimension, option, selector, argument string) ([]string, error) {
+	if selector == levelSelector {
+		level, err := strconv.Atoi(argument)
+		if err != nil || level < 0 {
+			return nil, errs.ErrorInvalidSelector(option, "the level must be a positive integer")
+		}
+
+		root, err := api.hierarchyClient.GetRoot(ctx, instanceID, dimension)
+		if err != nil {
+			return nil, hierarchyError(err, option, "the dimension does not have a hierarchy")
+		}
+
+		if level == 0 {
+			if !root.HasData {
+				return nil, nil
+			}
+			return []string{root.Links.Code.ID}, nil
+		}
+
+		return api.getHierarchyCodes(ctx, instanceID, dimension, root, level, false)
+	}
+
+	if argument == "" {
+		return nil, errs.ErrorInvalidSelector(option, "a code must be provided")
+	}
+
+	node, err := api.hierarchyClient.GetChild(ctx, instanceID, dimension, argument)
+	if err != nil {
+		return nil, hierarchyError(err, option, "the code is not in the hierarchy of the dimension")
+	}
+
+	if selector == childrenSelector {
+		return api.getHierarchyCodes(ctx, instanceID, dimension, node, 1, false)
+	}
+
+	return api.getHierarchyCodes(ctx, instanceID, dimension, node, -1, true)
+}
+
+// getHierarchyCodes walks down the hierarchy from the provided node, level by level, and returns the codes that have data
+// at the provided depth below it, or at every level down to that depth if allLevels is set. A negative depth walks down
+// to the bottom of the hierarchy.
+func (api *API) getHierarchyCodes(ctx context.Context, instanceID, dimension string, node hierarchy.Model, depth int, allLevels bool) ([]string, error) {
+	var codes []string
+
+	children := node.Children
+	for level := 1; len(children) > 0; level++ {
+		if allLevels || level == depth {
+			for _, child := range children {
+				if child.HasData {
+					codes = append(codes, child.Links.Code.ID)
+				}
+			}
+		}
+
+		if level == depth {
+			break
+		}
+
+		var nextLevel []hierarchy.Child
+		for _, child := range children {
+			if child.NumberofChildren == 0 {
+				continue
+			}
+
+			model, err := api.hierarchyClient.GetChild(ctx, instanceID, dimension, child.Links.Code.ID)
+			if err != nil {
+				return nil, err
+			}
+			nextLevel = append(nextLevel, model.Children...)
+		}
+		children = nextLevel
+	}
+
+	return codes, nil
+}
+
+// hierarchyError returns an invalid selector error with the provided reason when the hierarchy API cannot find the
+// requested hierarchy node, otherwise the error is returned as it is
+func hierarchyError(err error, option, notFoundReason string) error {
+	if hierarchyErr, ok := err.(*hierarchy.ErrInvalidHierarchyAPIResponse); ok && hierarchyErr.Code() == http.StatusNotFound {
+		return errs.ErrorInvalidSelector(option, notFoundReason)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/store"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// putInstanceObservations imports the V4 file in the body of the request into the observation store, replacing any
+// observations it held for the instance, so that the observations of a version of the instance can then be requested.
+// It is only available in dev mode, to load real data into a local store without a graph database and import pipeline.
+// Files larger than the configured maximum import size are rejected.
+func (api *API) putInstanceObservations(importer store.Importer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		instanceID := mux.Vars(r)["instance_id"]
+		logData := log.Data{"instance_id": instanceID}
+
+		body := http.MaxBytesReader(w, r.Body, api.cfg.MaxImportSize)
+		if err := importer.Import(ctx, instanceID, body); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			var parseErr *csv.ParseError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				err = errs.ErrV4FileTooLarge
+			case errors.Is(err, store.ErrInvalidV4Header) || errors.Is(err, store.ErrInvalidV4Row) || errors.As(err, &parseErr):
+				err = errs.ErrorInvalidV4File(err.Error())
+			}
+			handleObservationsErrorType(ctx, w, err, logData)
+			return
+		}
+
+		log.Info(ctx, "put instance observations endpoint: imported V4 file into observation store", logData)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -4,25 +4,18 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/ONSdigital/dp-api-clients-go/v2/cantabular"
 	dataset "github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/v2/hierarchy"
 	"github.com/ONSdigital/dp-authorisation/auth"
-	"github.com/ONSdigital/dp-graph/v2/graph/driver"
-	"github.com/ONSdigital/dp-graph/v2/observation"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 )
 
-//go:generate moq -out mock/graph.go -pkg mock . IGraph
 //go:generate moq -out mock/dataset.go -pkg mock . IDatasetClient
+//go:generate moq -out mock/hierarchy.go -pkg mock . IHierarchyClient
 //go:generate moq -out mock/authorisation.go -pkg mock . IAuthHandler
 //go:generate moq -out mock/cantabular.go -pkg mock . CantabularClient
 
-// IGraph defines the required methods from GraphDB required by Observation API
-type IGraph interface {
-	driver.Driver
-	StreamCSVRows(ctx context.Context, instanceID, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error)
-	ErrorChan() chan error
-}
-
 // IDatasetClient represents the required methods from the Dataset Client required by Observation API
 type IDatasetClient interface {
 	GetVersion(ctx context.Context, userAuthToken, serviceAuthToken, downloadServiceAuthToken, collectionID, datasetID, edition, version string) (m dataset.Version, err error)
@@ -31,11 +24,20 @@ type IDatasetClient interface {
 	GetOptions(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension string, q *dataset.QueryParams) (m dataset.Options, err error)
 }
 
+// IHierarchyClient represents the required methods from the Hierarchy Client required by Observation API
+type IHierarchyClient interface {
+	GetRoot(ctx context.Context, instanceID, name string) (hierarchy.Model, error)
+	GetChild(ctx context.Context, instanceID, name, code string) (hierarchy.Model, error)
+	Checker(ctx context.Context, check *healthcheck.CheckState) error
+}
+
 // IAuthHandler represents the required methods from authorisation library by Observation API
 type IAuthHandler interface {
 	Require(required auth.Permissions, handler http.HandlerFunc) http.HandlerFunc
 }
 
+// CantabularClient represents the required methods from the Cantabular Client required by Observation API
 type CantabularClient interface {
 	Checker(context.Context, *healthcheck.CheckState) error
+	StaticDatasetQuery(ctx context.Context, req cantabular.StaticDatasetQueryRequest) (*cantabular.StaticDatasetQuery, error)
 }
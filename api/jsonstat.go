@@ -0,0 +1,16 @@
+package api
+
+import (
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+// renderJSONStat renders the page of observations as a JSON-stat 2.0 dataset
+func renderJSONStat(result *observationsResult) ([]byte, error) {
+	observations, err := result.collectObservations()
+	if err != nil {
+		return nil, err
+	}
+
+	jsonStat := models.CreateJSONStatDataset(result.doc, result.versionDoc, result.datasetDoc.Title, observations)
+	return marshalJSON(jsonStat)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+// labelPrefix marks an option that selects the option of the dimension with that label, rather than with that code
+const labelPrefix = "label:"
+
+// parseLabelSelector parses an option selecting an option by its label: label:text, or label:"text" for labels containing commas
+func parseLabelSelector(option string) (string, bool) {
+	if !strings.HasPrefix(option, labelPrefix) {
+		return "", false
+	}
+
+	label := strings.TrimSpace(strings.TrimPrefix(option, labelPrefix))
+	if len(label) > 1 && strings.HasPrefix(label, `"`) && strings.HasSuffix(label, `"`) {
+		label = label[1 : len(label)-1]
+	}
+
+	return strings.TrimSpace(label), true
+}
+
+// getLabelOptions returns the code of the option of the dimension whose label matches the label selector, ignoring case.
+// A label that matches the labels of several options is ambiguous, and the error lists their codes and labels.
+func (api *API) getLabelOptions(ctx context.Context, event *models.FilterSubmitted, dimension, option, label string) ([]string, error) {
+	dimensionOptions, err := api.getDimensionOptions(ctx, event, dimension)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes, candidates []string
+	for _, dimensionOption := range dimensionOptions {
+		if strings.EqualFold(strings.TrimSpace(dimensionOption.Label), label) {
+			codes = append(codes, dimensionOption.Option)
+			candidates = append(candidates, describeOption(dimensionOption))
+		}
+	}
+
+	switch len(codes) {
+	case 0:
+		return nil, errs.ErrorUnknownLabel(option)
+	case 1:
+		return codes, nil
+	default:
+		return nil, errs.ErrorAmbiguousLabel(option, candidates)
+	}
+}
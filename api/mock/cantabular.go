@@ -5,6 +5,7 @@ package mock
 
 import (
 	"context"
+	"github.com/ONSdigital/dp-api-clients-go/v2/cantabular"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-observation-api/api"
 	"sync"
@@ -23,6 +24,9 @@ var _ api.CantabularClient = &CantabularClientMock{}
 // 			CheckerFunc: func(contextMoqParam context.Context, checkState *healthcheck.CheckState) error {
 // 				panic("mock out the Checker method")
 // 			},
+// 			StaticDatasetQueryFunc: func(ctx context.Context, req cantabular.StaticDatasetQueryRequest) (*cantabular.StaticDatasetQuery, error) {
+// 				panic("mock out the StaticDatasetQuery method")
+// 			},
 // 		}
 //
 // 		// use mockedCantabularClient in code that requires api.CantabularClient
@@ -33,6 +37,9 @@ type CantabularClientMock struct {
 	// CheckerFunc mocks the Checker method.
 	CheckerFunc func(contextMoqParam context.Context, checkState *healthcheck.CheckState) error
 
+	// StaticDatasetQueryFunc mocks the StaticDatasetQuery method.
+	StaticDatasetQueryFunc func(ctx context.Context, req cantabular.StaticDatasetQueryRequest) (*cantabular.StaticDatasetQuery, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Checker holds details about calls to the Checker method.
@@ -42,8 +49,16 @@ type CantabularClientMock struct {
 			// CheckState is the checkState argument value.
 			CheckState *healthcheck.CheckState
 		}
+		// StaticDatasetQuery holds details about calls to the StaticDatasetQuery method.
+		StaticDatasetQuery []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req cantabular.StaticDatasetQueryRequest
+		}
 	}
-	lockChecker sync.RWMutex
+	lockChecker            sync.RWMutex
+	lockStaticDatasetQuery sync.RWMutex
 }
 
 // Checker calls CheckerFunc.
@@ -80,3 +95,38 @@ func (mock *CantabularClientMock) CheckerCalls() []struct {
 	mock.lockChecker.RUnlock()
 	return calls
 }
+
+// StaticDatasetQuery calls StaticDatasetQueryFunc.
+func (mock *CantabularClientMock) StaticDatasetQuery(ctx context.Context, req cantabular.StaticDatasetQueryRequest) (*cantabular.StaticDatasetQuery, error) {
+	if mock.StaticDatasetQueryFunc == nil {
+		panic("CantabularClientMock.StaticDatasetQueryFunc: method is nil but CantabularClient.StaticDatasetQuery was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req cantabular.StaticDatasetQueryRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockStaticDatasetQuery.Lock()
+	mock.calls.StaticDatasetQuery = append(mock.calls.StaticDatasetQuery, callInfo)
+	mock.lockStaticDatasetQuery.Unlock()
+	return mock.StaticDatasetQueryFunc(ctx, req)
+}
+
+// StaticDatasetQueryCalls gets all the calls that were made to StaticDatasetQuery.
+// Check the length with:
+//     len(mockedCantabularClient.StaticDatasetQueryCalls())
+func (mock *CantabularClientMock) StaticDatasetQueryCalls() []struct {
+	Ctx context.Context
+	Req cantabular.StaticDatasetQueryRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req cantabular.StaticDatasetQueryRequest
+	}
+	mock.lockStaticDatasetQuery.RLock()
+	calls = mock.calls.StaticDatasetQuery
+	mock.lockStaticDatasetQuery.RUnlock()
+	return calls
+}
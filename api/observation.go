@@ -2,10 +2,8 @@ package api
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -21,13 +19,18 @@ import (
 	"github.com/ONSdigital/dp-graph/v2/observation"
 	errs "github.com/ONSdigital/dp-observation-api/apierrors"
 	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/dp-observation-api/store"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 )
 
 const (
-	defaultOffset = 0
+	offsetParameter = "offset"
+	limitParameter  = "limit"
+
+	// dimensionOptionsBatchSize is the number of dimension options requested from dataset API at a time
+	dimensionOptionsBatchSize = 1000
 )
 
 var (
@@ -38,12 +41,31 @@ var (
 		errs.ErrObservationsNotFound: true,
 	}
 
-	observationBadRequest = map[error]bool{
-		errs.ErrTooManyWildcards: true,
+	// reservedQueryParameters control the query rather than selecting dimension options.
+	// A version dimension with the same name as a reserved query parameter takes precedence over it.
+	reservedQueryParameters = map[string]bool{
+		offsetParameter: true,
+		limitParameter:  true,
+		formatParameter: true,
+		sortParameter:   true,
+
+		aggregateParameter: true,
+		deriveParameter:    true,
+		baseParameter:      true,
+
+		valueNotNullParameter: true,
+		topParameter:          true,
+		bottomParameter:       true,
 	}
 )
 
 func (api *API) getObservations(w http.ResponseWriter, r *http.Request) {
+	api.serveObservations(w, r, nil)
+}
+
+// serveObservations responds with the observations selected by the request, whose dimension options are selected by the
+// provided selections if there are any, or by its query parameters otherwise
+func (api *API) serveObservations(w http.ResponseWriter, r *http.Request, selections map[string][]string) {
 	ctx := r.Context()
 	vars := mux.Vars(r)
 	datasetID := vars["dataset_id"]
@@ -53,70 +75,325 @@ func (api *API) getObservations(w http.ResponseWriter, r *http.Request) {
 	// TODO call audit (attempt) once it has its own library
 	logData := log.Data{"dataset_id": datasetID, "edition": edition, "version": version}
 
-	observationsDoc, err := api.doGetObservations(ctx, datasetID, edition, version, r, logData)
+	result, err := api.doGetObservations(ctx, datasetID, edition, version, r, selections, logData)
 	if err != nil {
 		// TODO call audit (unsuccessful) once it has its own library
 		handleObservationsErrorType(ctx, w, err, logData)
 		return
 	}
+	defer result.close(ctx)
+
+	// read the first observation before writing the response, so that a query
+	// that fails straight away can still be reported with the right status
+	if err = result.page.peek(); err != nil {
+		handleObservationsErrorType(ctx, w, err, logData)
+		return
+	}
 
 	if api.enableURLRewriting {
-		var rewriteErr error
+		if err = api.rewriteLinks(r, result.doc, logData); err != nil {
+			handleObservationsErrorType(ctx, w, err, logData)
+			return
+		}
+	}
 
-		codeListLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.codeListAPIURL)
-		datasetLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.datasetAPIURL)
-		observationLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.observationAPIURL)
+	// TODO call audit (successful) once it has its own library
 
-		observationsDoc.Links.DatasetMetadata.URL, rewriteErr = datasetLinksBuilder.BuildLink(observationsDoc.Links.DatasetMetadata.URL)
-		if rewriteErr != nil {
-			logData["link"] = observationsDoc.Links.DatasetMetadata.URL
-			handleObservationsErrorType(ctx, w, errors.WithMessage(rewriteErr, "failed to rewrite dataset metadata link"), logData)
+	if render, found := bufferedRenderers[result.format]; found {
+		b, err := render(result)
+		if err != nil {
+			handleObservationsErrorType(ctx, w, err, logData)
 			return
 		}
 
-		observationsDoc.Links.Self.URL, rewriteErr = observationLinksBuilder.BuildLink(observationsDoc.Links.Self.URL)
-		if rewriteErr != nil {
-			logData["link"] = observationsDoc.Links.Self.URL
-			handleObservationsErrorType(ctx, w, errors.WithMessage(rewriteErr, "failed to rewrite self link"), logData)
+		setContentType(w, result.format)
+		if _, err = w.Write(b); err != nil {
+			log.Error(ctx, "get observations endpoint: failed to write observations", err, logData)
 			return
 		}
 
-		observationsDoc.Links.Version.URL, rewriteErr = datasetLinksBuilder.BuildLink(observationsDoc.Links.Version.URL)
+		log.Info(ctx, "get observations endpoint: successfully retrieved observations relative to a selected set of dimension options for a version", logData)
+		return
+	}
+
+	setContentType(w, result.format)
+
+	// the status of a streamed response is written before the observations are read, so a failure to read them is
+	// reported in a trailer, which is all a client of a CSV, Arrow or Parquet response is told of it
+	w.Header().Set("Trailer", streamErrorTrailer)
+
+	switch result.format {
+	case formatCSV:
+		w.Header().Set("Link", csvwMetadataLink(result.doc.Links.Self.URL))
+		err = writeObservationsCSV(w, result)
+	case formatArrow:
+		err = writeObservationsArrow(w, result)
+	case formatParquet:
+		err = writeObservationsParquet(w, result)
+	default:
+		err = writeObservationsDoc(w, result)
+	}
+
+	if err != nil {
+		w.Header().Set(streamErrorTrailer, errs.ErrInternalServer.Error())
+		log.Error(ctx, "get observations endpoint: failed to stream observations", err, logData)
+		return
+	}
+
+	log.Info(ctx, "get observations endpoint: successfully retrieved observations relative to a selected set of dimension options for a version", logData)
+}
+
+// rewriteLinks rewrites the links of the observations document relative to the headers of the request
+func (api *API) rewriteLinks(r *http.Request, observationsDoc *models.ObservationsDoc, logData log.Data) error {
+	var rewriteErr error
+
+	codeListLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.codeListAPIURL)
+	datasetLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.datasetAPIURL)
+	observationLinksBuilder := links.FromHeadersOrDefault(&r.Header, api.observationAPIURL)
+
+	observationsDoc.Links.DatasetMetadata.URL, rewriteErr = datasetLinksBuilder.BuildLink(observationsDoc.Links.DatasetMetadata.URL)
+	if rewriteErr != nil {
+		logData["link"] = observationsDoc.Links.DatasetMetadata.URL
+		return errors.WithMessage(rewriteErr, "failed to rewrite dataset metadata link")
+	}
+
+	observationsDoc.Links.Self.URL, rewriteErr = observationLinksBuilder.BuildLink(observationsDoc.Links.Self.URL)
+	if rewriteErr != nil {
+		logData["link"] = observationsDoc.Links.Self.URL
+		return errors.WithMessage(rewriteErr, "failed to rewrite self link")
+	}
+
+	for _, pageLink := range observationsDoc.Links.PageLinks() {
+		pageLink.URL, rewriteErr = observationLinksBuilder.BuildLink(pageLink.URL)
 		if rewriteErr != nil {
-			logData["link"] = observationsDoc.Links.Version.URL
-			handleObservationsErrorType(ctx, w, errors.WithMessage(rewriteErr, "failed to rewrite version link"), logData)
-			return
+			logData["link"] = pageLink.URL
+			return errors.WithMessage(rewriteErr, "failed to rewrite pagination link")
 		}
+	}
 
-		for i := range observationsDoc.Dimensions {
-			observationsDoc.Dimensions[i].LinkObject.URL, rewriteErr = codeListLinksBuilder.BuildLink(observationsDoc.Dimensions[i].LinkObject.URL)
+	observationsDoc.Links.Version.URL, rewriteErr = datasetLinksBuilder.BuildLink(observationsDoc.Links.Version.URL)
+	if rewriteErr != nil {
+		logData["link"] = observationsDoc.Links.Version.URL
+		return errors.WithMessage(rewriteErr, "failed to rewrite version link")
+	}
+
+	for i := range observationsDoc.Dimensions {
+		for _, linkObject := range observationsDoc.Dimensions[i].Links() {
+			linkObject.URL, rewriteErr = codeListLinksBuilder.BuildLink(linkObject.URL)
 			if rewriteErr != nil {
-				logData["link"] = observationsDoc.Dimensions[i].LinkObject.URL
-				handleObservationsErrorType(ctx, w, errors.WithMessage(rewriteErr, "failed to rewrite dimension link"), logData)
-				return
+				logData["link"] = linkObject.URL
+				return errors.WithMessage(rewriteErr, "failed to rewrite dimension link")
 			}
 		}
 	}
 
-	// TODO call audit (successful) once it has its own library
+	return nil
+}
+
+func (api *API) doGetObservations(ctx context.Context, datasetID, edition, version string, r *http.Request, selections map[string][]string, logData log.Data) (*observationsResult, error) {
+	query, err := api.getObservationsQuery(ctx, datasetID, edition, version, r, selections, logData)
+	if err != nil {
+		return nil, err
+	}
 
-	setJSONContentType(w)
+	return api.queryObservations(ctx, datasetID, edition, version, r, query, logData)
+}
 
-	// The ampersand "&" is escaped to "\u0026" to keep some browsers from
-	// misinterpreting JSON output as HTML. This escaping can be disabled using
-	// an Encoder that had SetEscapeHTML(false) called on it.
-	enc := json.NewEncoder(w)
-	enc.SetEscapeHTML(false)
+// queryObservations retrieves the observations selected by the query of the request, in the order, format and page of
+// the request, along with the values derived from them, filtered by their value or aggregated as requested
+func (api *API) queryObservations(ctx context.Context, datasetID, edition, version string, r *http.Request, query *observationsQuery, logData log.Data) (*observationsResult, error) {
+	offset, limit, err := ExtractPaginationParameters(r.URL.Query(), query.validDimensionNames, api.cfg.DefaultObservationLimit, api.cfg.MaxObservationLimit)
+	if err != nil {
+		log.Error(ctx, "get observations: error extracting pagination parameters", err, logData)
+		return nil, err
+	}
+	logData["offset"] = offset
+	logData["limit"] = limit
 
-	if err = enc.Encode(observationsDoc); err != nil {
-		handleObservationsErrorType(ctx, w, errors.WithMessage(err, "failed to marshal metadata resource into bytes"), logData)
-		return
+	format, err := getFormat(r, query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations: error determining the format of the response", err, logData)
+		return nil, err
 	}
+	logData["format"] = format
 
-	log.Info(ctx, "get observations endpoint: successfully retrieved observations relative to a selected set of dimension options for a version", logData)
+	filter, err := getValueFilter(r.URL.Query(), query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations: error extracting the value filter", err, logData)
+		return nil, err
+	}
+
+	aggregate, err := getAggregate(r.URL.Query(), query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations: error determining the aggregate of the observations", err, logData)
+		return nil, err
+	}
+
+	// a cube cannot hold an observation derived across its dimensions
+	if aggregate != "" && (format == formatJSONStat || format == formatSDMXJSON || format == formatSDMXML) {
+		err = errs.ErrorInvalidAggregate(aggregate, "the observations cannot be aggregated in the "+format+" format")
+		log.Error(ctx, "get observations: aggregate cannot be returned in the requested format", err, logData)
+		return nil, err
+	}
+
+	derivation, err := getDerivation(r.URL.Query(), query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations: error determining the derivation of the observations", err, logData)
+		return nil, err
+	}
+
+	if derivation != nil {
+		switch {
+		case aggregate != "":
+			err = errs.ErrorInvalidDerivation(derivation.function, "derived values cannot be calculated for an aggregated observation")
+		case format != formatJSON && format != formatCSV && format != formatXLSX:
+			err = errs.ErrorInvalidDerivation(derivation.function, "derived values cannot be calculated in the "+format+" format")
+		}
+		if err != nil {
+			log.Error(ctx, "get observations: error determining the derivation of the observations", err, logData)
+			return nil, err
+		}
+	}
+
+	order, err := getSort(r.URL.Query(), query.validDimensionNames)
+	if err != nil {
+		log.Error(ctx, "get observations: error determining the order of the observations", err, logData)
+		return nil, err
+	}
+	if order != "" {
+		logData["sort"] = order
+	}
+
+	if err = api.expandSelectors(ctx, query.versionDoc.ID, query.event, query.queryParameters, logData); err != nil {
+		return nil, err
+	}
+
+	var aggregateDimensions []string
+	if aggregate != "" {
+		aggregateDimensions = aggregatedDimensions(query.queryParameters)
+		if len(aggregateDimensions) == 0 {
+			err = errs.ErrorInvalidAggregate(aggregate, "the observations can only be aggregated across a wildcard or a dimension with more than one option")
+			log.Error(ctx, "get observations: error determining the aggregate of the observations", err, logData)
+			return nil, err
+		}
+		logData["aggregate"] = aggregate
+		logData["aggregate_dimensions"] = aggregateDimensions
+	}
+
+	var periodRanks map[string]int
+	if derivation != nil {
+		if options := query.queryParameters[timeDimension]; len(options) != 1 || options[0] != "*" {
+			err = errs.ErrorInvalidDerivation(derivation.function, "derived values can only be calculated when the "+timeDimension+" dimension is a wildcard")
+			log.Error(ctx, "get observations: error determining the derivation of the observations", err, logData)
+			return nil, err
+		}
+
+		if periodRanks, err = api.getPeriodRanks(ctx, query.event, derivation); err != nil {
+			log.Error(ctx, "get observations: error determining the derivation of the observations", err, logData)
+			return nil, err
+		}
+		logData["derive"] = derivation.function
+	}
+
+	// retrieve observations, from Cantabular or from the observation store of the backend of the dataset
+	backend := api.getObservationBackend(datasetID, query.datasetDoc)
+	logData["observation_backend"] = backend
+
+	var rows *observationRows
+	var observationDimensions map[string]struct{}
+	if backend == store.CantabularBackend {
+		rows, observationDimensions, err = api.getCantabularObservationRows(ctx, &query.versionDoc, query.queryParameters, logData, query.event)
+	} else {
+		observationStore, found := api.observationStores.Store(backend)
+		if !found {
+			log.Error(ctx, "get observations: no observation store for the backend of the dataset", store.ErrUnknownBackend, logData)
+			return nil, errs.ErrInternalServer
+		}
+		rows, observationDimensions, err = api.getObservationRows(ctx, observationStore, &query.versionDoc, query.queryParameters, logData, query.event)
+	}
+	if err != nil {
+		log.Error(ctx, "get observations: unable to retrieve observations", err, logData)
+		return nil, err
+	}
+
+	var selectedRows rowIterator = rows
+
+	// the derived values are calculated from every observation, before any of them are filtered out by their value
+	var derived *derivedRows
+	if derivation != nil {
+		derived = &derivedRows{
+			rows:        rows,
+			stream:      rows,
+			derivation:  derivation,
+			header:      rows.header,
+			offset:      rows.dimensionOffset,
+			periodRanks: periodRanks,
+		}
+		selectedRows = derived
+	}
+
+	var filtered *filteredRows
+	if filter != nil {
+		filtered = &filteredRows{rows: selectedRows, stream: rows, filter: filter}
+		selectedRows = filtered
+	}
+
+	var aggregated *aggregatedRows
+	if aggregate != "" {
+		aggregated = &aggregatedRows{
+			rows:       selectedRows,
+			stream:     rows,
+			function:   aggregate,
+			header:     rows.header,
+			offset:     rows.dimensionOffset,
+			dimensions: aggregateDimensions,
+		}
+		selectedRows = aggregated
+
+		// the derived observation does not have an option of the dimensions it was aggregated across
+		observationDimensions = map[string]struct{}{}
+	}
+
+	if order != "" {
+		selectedRows, err = api.sortObservationRows(ctx, query.event, &query.versionDoc, rows.header, rows.dimensionOffset, selectedRows, observationDimensions, order)
+		if err != nil {
+			rows.reader.Close(ctx)
+			log.Error(ctx, "get observations: unable to sort observations", err, logData)
+			return nil, err
+		}
+	}
+
+	doc := models.CreateObservationsDoc(api.cfg.ObservationAPIURL, api.cfg.DatasetAPIURL, r.URL.RawQuery, datasetID, edition, version, &query.versionDoc, query.datasetDoc, query.queryParameters, offset, limit)
+	doc.SetDefaultOptions(query.defaultedDimensions)
+
+	return &observationsResult{
+		doc:                   doc,
+		versionDoc:            &query.versionDoc,
+		datasetDoc:            &query.datasetDoc,
+		rows:                  rows,
+		page:                  newObservationPage(selectedRows, offset, limit),
+		filtered:              filtered,
+		aggregated:            aggregated,
+		derived:               derived,
+		observationDimensions: observationDimensions,
+		format:                format,
+	}, nil
 }
 
-func (api *API) doGetObservations(ctx context.Context, datasetID, edition, version string, r *http.Request, logData log.Data) (*models.ObservationsDoc, error) {
+// observationsQuery holds the dataset and version of an observations request, along with the dimension options it selects
+// and the dimensions that select their default option because they are not in the request
+type observationsQuery struct {
+	event               *models.FilterSubmitted
+	datasetDoc          dataset.DatasetDetails
+	versionDoc          dataset.Version
+	validDimensionNames []string
+	queryParameters     map[string][]string
+	defaultedDimensions []string
+}
+
+// getObservationsQuery retrieves the dataset and version of an observations request, and extracts the dimension options
+// selected by the provided selections if there are any, or by its query parameters otherwise
+func (api *API) getObservationsQuery(ctx context.Context, datasetID, edition, version string, r *http.Request, selections map[string][]string, logData log.Data) (*observationsQuery, error) {
 	var authorised bool
 	if api.cfg.EnablePrivateEndpoints {
 		authorised = api.checkIfAuthorised(r, logData)
@@ -152,27 +429,46 @@ func (api *API) doGetObservations(ctx context.Context, datasetID, edition, versi
 	logData["version_dimensions"] = validDimensionNames
 
 	// check query parameters match the version dimensions
-	queryParameters, err := ExtractQueryParameters(r.URL.Query(), validDimensionNames)
+	var queryParameters map[string][]string
+	var missingDimensions []string
+	if selections != nil {
+		queryParameters, missingDimensions, err = selectQueryParameters(selections, validDimensionNames)
+	} else {
+		queryParameters, missingDimensions, err = extractQueryParameters(r.URL.Query(), validDimensionNames)
+	}
 	if err != nil {
 		log.Error(ctx, "get observations: error extracting query parameters", err, logData)
 		return nil, err
 	}
-	logData["query_parameters"] = queryParameters
 
-	event := models.FilterSubmitted{
+	event := &models.FilterSubmitted{
 		DatasetID: datasetID,
 		Edition:   edition,
 		Version:   version,
 	}
 
-	// retrieve observations
-	observations, err := api.getObservationList(ctx, &versionDoc, queryParameters, api.cfg.DefaultObservationLimit, logData, &event)
+	// the dimensions that have not been set select their default option, if they have one
+	defaultedDimensions, err := api.selectDefaultOptions(ctx, versionDoc.ID, event, queryParameters, missingDimensions, logData)
 	if err != nil {
-		log.Error(ctx, "get observations: unable to retrieve observations", err, logData)
+		log.Error(ctx, "get observations: error selecting the default options of missing dimensions", err, logData)
+		return nil, err
+	}
+	logData["query_parameters"] = queryParameters
+
+	// default options are options of their dimension, so only the options of the request need to be validated
+	if err = api.validateOptions(ctx, event, queryParameters, defaultedDimensions, logData); err != nil {
+		log.Error(ctx, "get observations: invalid options in query parameters", err, logData)
 		return nil, err
 	}
 
-	return models.CreateObservationsDoc(api.cfg.ObservationAPIURL, api.cfg.DatasetAPIURL, r.URL.RawQuery, datasetID, edition, version, &versionDoc, datasetDoc, observations, queryParameters, defaultOffset, api.cfg.DefaultObservationLimit), nil
+	return &observationsQuery{
+		event:               event,
+		datasetDoc:          datasetDoc,
+		versionDoc:          versionDoc,
+		validDimensionNames: validDimensionNames,
+		queryParameters:     queryParameters,
+		defaultedDimensions: defaultedDimensions,
+	}, nil
 }
 
 // GetDimensionOffsetInHeaderRow splits the first item of the provided headers by '_', and returns the second item as integer
@@ -267,10 +563,26 @@ func GetListOfValidDimensionNames(dimensions []dataset.VersionDimension) []strin
 	return dimensionNames
 }
 
-// ExtractQueryParameters creates a map of query parameters (options) by dimension from the provided urlQuery if they exist in the validDimensions list
-func ExtractQueryParameters(urlQuery url.Values, validDimensions []string) (map[string]string, error) {
-	queryParameters := make(map[string]string)
-	var incorrectQueryParameters, missingQueryParameters, multivaluedQueryParameters []string
+// ExtractQueryParameters creates a map of query parameters (options) by dimension from the provided urlQuery if they exist in the validDimensions list.
+// Several options can be selected for a dimension by repeating the query parameter or by providing a comma separated list of options.
+func ExtractQueryParameters(urlQuery url.Values, validDimensions []string) (map[string][]string, error) {
+	queryParameters, missingQueryParameters, err := extractQueryParameters(urlQuery, validDimensions)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missingQueryParameters) > 0 {
+		return nil, errs.ErrorMissingQueryParameters(missingQueryParameters)
+	}
+
+	return queryParameters, nil
+}
+
+// extractQueryParameters creates a map of query parameters (options) by dimension from the provided urlQuery, like ExtractQueryParameters,
+// along with the list of valid dimensions that have not been set in the urlQuery
+func extractQueryParameters(urlQuery url.Values, validDimensions []string) (queryParameters map[string][]string, missingQueryParameters []string, err error) {
+	queryParameters = make(map[string][]string)
+	var incorrectQueryParameters []string
 
 	// Map for efficiency
 	validDimensionsMap := make(map[string]struct{})
@@ -280,43 +592,181 @@ func ExtractQueryParameters(urlQuery url.Values, validDimensions []string) (map[
 
 	// Determine if any request query parameters are invalid dimensions
 	// and map the valid dimensions with their equivalent values in map
-	for rawDimension, option := range urlQuery {
+	for rawDimension, values := range urlQuery {
 		// Ignore case sensitivity
 		dimension := strings.ToLower(rawDimension)
 
-		queryParamExists := false
-
-		if _, dimFound := validDimensionsMap[dimension]; dimFound {
-			queryParamExists = true
-			queryParameters[dimension] = option[0]
-			if len(option) != 1 {
-				multivaluedQueryParameters = append(multivaluedQueryParameters, rawDimension)
+		if _, dimFound := validDimensionsMap[dimension]; !dimFound {
+			if !reservedQueryParameters[rawDimension] && !isValuePredicateParameter(rawDimension, validDimensions) {
+				incorrectQueryParameters = append(incorrectQueryParameters, rawDimension)
 			}
+			continue
 		}
-		if !queryParamExists {
+
+		queryParameters[dimension] = appendOptions(queryParameters[dimension], values)
+	}
+
+	if len(incorrectQueryParameters) > 0 {
+		return nil, nil, errs.ErrorIncorrectQueryParameters(incorrectQueryParameters)
+	}
+
+	missingQueryParameters, err = checkQueryParameters(queryParameters, validDimensions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return queryParameters, missingQueryParameters, nil
+}
+
+// selectQueryParameters maps the options selected by the body of a query by dimension, like extractQueryParameters.
+// Each option of the body is a separate value, so options are not split on commas, and every selection must be a
+// valid dimension, as the body cannot hold any reserved parameter.
+func selectQueryParameters(selections map[string][]string, validDimensions []string) (queryParameters map[string][]string, missingQueryParameters []string, err error) {
+	queryParameters = make(map[string][]string)
+	var incorrectQueryParameters []string
+
+	for rawDimension, options := range selections {
+		dimension := strings.ToLower(rawDimension)
+		if !containsOption(validDimensions, dimension) {
 			incorrectQueryParameters = append(incorrectQueryParameters, rawDimension)
+			continue
 		}
+
+		queryParameters[dimension] = appendSelectedOptions(queryParameters[dimension], options)
 	}
 
 	if len(incorrectQueryParameters) > 0 {
-		return nil, errs.ErrorIncorrectQueryParameters(incorrectQueryParameters)
+		sort.Strings(incorrectQueryParameters)
+		return nil, nil, errs.ErrorIncorrectQueryParameters(incorrectQueryParameters)
+	}
+
+	missingQueryParameters, err = checkQueryParameters(queryParameters, validDimensions)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(multivaluedQueryParameters) > 0 {
-		return nil, errs.ErrorMultivaluedQueryParameters(multivaluedQueryParameters)
+	return queryParameters, missingQueryParameters, nil
+}
+
+// checkQueryParameters checks that no wildcard is combined with other options, returning the list of valid dimensions
+// that have not been selected
+func checkQueryParameters(queryParameters map[string][]string, validDimensions []string) (missingQueryParameters []string, err error) {
+	var wildcardQueryParameters []string
+
+	// A wildcard already selects every option, so it cannot be combined with other options
+	for dimension, options := range queryParameters {
+		if len(options) > 1 && containsOption(options, "*") {
+			wildcardQueryParameters = append(wildcardQueryParameters, dimension)
+		}
+	}
+
+	if len(wildcardQueryParameters) > 0 {
+		return nil, errs.ErrorWildcardWithOptions(wildcardQueryParameters)
 	}
 
 	// Determine if any dimensions have not been set in request query parameters
-	if len(queryParameters) != len(validDimensions) {
-		for _, validDimension := range validDimensions {
-			if queryParameters[validDimension] == "" {
-				missingQueryParameters = append(missingQueryParameters, validDimension)
+	for _, validDimension := range validDimensions {
+		if len(queryParameters[validDimension]) == 0 {
+			missingQueryParameters = append(missingQueryParameters, validDimension)
+		}
+	}
+
+	return missingQueryParameters, nil
+}
+
+// ExtractPaginationParameters obtains the offset and limit from the provided urlQuery, defaulting them when they are not provided.
+// The limit cannot be greater than maxLimit.
+func ExtractPaginationParameters(urlQuery url.Values, validDimensions []string, defaultLimit, maxLimit int) (offset, limit int, err error) {
+	offset, limit = 0, defaultLimit
+
+	if value, found := getReservedQueryParameter(urlQuery, validDimensions, offsetParameter); found {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errs.ErrorInvalidOffset(value)
+		}
+	}
+
+	if value, found := getReservedQueryParameter(urlQuery, validDimensions, limitParameter); found {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, errs.ErrorInvalidLimit(value, maxLimit)
+		}
+	}
+
+	return offset, limit, nil
+}
+
+// getReservedQueryParameter returns the value of a reserved query parameter, if it has been provided and it is not a version dimension
+func getReservedQueryParameter(urlQuery url.Values, validDimensions []string, name string) (string, bool) {
+	if containsOption(validDimensions, name) {
+		return "", false
+	}
+
+	values, found := urlQuery[name]
+	if !found || len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// appendOptions splits the provided comma separated values and appends each option that has not already been selected
+func appendOptions(options, values []string) []string {
+	for _, value := range values {
+		options = appendSelectedOptions(options, splitOptions(value))
+	}
+	return options
+}
+
+// appendSelectedOptions appends each of the provided options that has not already been selected, ignoring blank options
+func appendSelectedOptions(options, selected []string) []string {
+	for _, option := range selected {
+		option = strings.TrimSpace(option)
+		if option == "" || containsOption(options, option) {
+			continue
+		}
+		options = append(options, option)
+	}
+	return options
+}
+
+// splitOptions splits a comma separated list of options. Commas between double quotes do not separate options,
+// so that labels containing commas can be selected, e.g. label:"Bristol, City of", and so can options containing
+// commas, e.g. "Bristol, City of", whose quotes are removed
+func splitOptions(value string) []string {
+	var options []string
+	quoted := false
+	start := 0
+	for i, r := range value {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				options = append(options, unquoteOption(value[start:i]))
+				start = i + 1
 			}
 		}
-		return nil, errs.ErrorMissingQueryParameters(missingQueryParameters)
 	}
+	return append(options, unquoteOption(value[start:]))
+}
 
-	return queryParameters, nil
+// unquoteOption removes the double quotes around an option, if it is entirely between double quotes
+func unquoteOption(option string) string {
+	trimmed := strings.TrimSpace(option)
+	if len(trimmed) >= 2 && strings.HasPrefix(trimmed, `"`) && strings.HasSuffix(trimmed, `"`) {
+		return trimmed[1 : len(trimmed)-1]
+	}
+	return option
+}
+
+func containsOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
 }
 
 // getUserAuthToken obtains the user auth token from the context, expected under FlorenceIdentityKey
@@ -367,15 +817,7 @@ var SortFilter = func(ctx context.Context, api *API, event *models.FilterSubmitt
 
 			defer wg.Done()
 
-			// passing a 'Limit' of 0 makes GetOptions skip getting the documents
-			// and to return only what we are interested in: TotalCount
-			options, err := api.datasetClient.GetOptions(ctx,
-				"", // userAuthToken,
-				api.cfg.ServiceAuthToken,
-				"", // collectionID
-				event.DatasetID, event.Edition, event.Version, dimension.Name,
-				&dataset.QueryParams{Offset: 0, Limit: 0})
-
+			dimensionSize, err := api.getDimensionSize(ctx, event, dimension.Name)
 			if err != nil {
 				if atomic.AddInt32(&getErrorCount, 1) <= 2 {
 					// only show a few of possibly hundreds of errors, as once someone
@@ -384,7 +826,7 @@ var SortFilter = func(ctx context.Context, api *API, event *models.FilterSubmitt
 					log.Info(ctx, "SortFilter: GetOptions failed for dataset and dimension", logData)
 				}
 			} else {
-				d := dim{dimensionSize: options.TotalCount, index: i}
+				d := dim{dimensionSize: dimensionSize, index: i}
 				dimSizesMutex.Lock()
 				dimSizes = append(dimSizes, d)
 				dimSizesMutex.Unlock()
@@ -429,32 +871,142 @@ var SortFilter = func(ctx context.Context, api *API, event *models.FilterSubmitt
 	}
 }
 
-func (api *API) getObservationList(ctx context.Context, versionDoc *dataset.Version, queryParameters map[string]string, limit int, logData log.Data, event *models.FilterSubmitted) ([]models.Observation, error) {
+// getDimensionSize obtains the total number of options for the provided dimension of the version from dataset API
+func (api *API) getDimensionSize(ctx context.Context, event *models.FilterSubmitted, dimensionName string) (int, error) {
+	// passing a 'Limit' of 0 makes GetOptions skip getting the documents
+	// and to return only what we are interested in: TotalCount
+	options, err := api.datasetClient.GetOptions(ctx,
+		"", // userAuthToken,
+		api.cfg.ServiceAuthToken,
+		"", // collectionID
+		event.DatasetID, event.Edition, event.Version, dimensionName,
+		&dataset.QueryParams{Offset: 0, Limit: 0})
+	if err != nil {
+		return 0, err
+	}
+
+	return options.TotalCount, nil
+}
+
+// getDimensionOptions obtains every option of the provided dimension of the version from dataset API, in the order in which
+// dataset API lists them, requesting them a batch at a time. The options are cached, and must not be modified.
+func (api *API) getDimensionOptions(ctx context.Context, event *models.FilterSubmitted, dimensionName string) ([]dataset.Option, error) {
+	if options, found := api.optionsCache.get(event, dimensionName); found {
+		return options, nil
+	}
+
+	var options []dataset.Option
+
+	for {
+		batch, err := api.datasetClient.GetOptions(ctx,
+			"", // userAuthToken,
+			api.cfg.ServiceAuthToken,
+			"", // collectionID
+			event.DatasetID, event.Edition, event.Version, dimensionName,
+			&dataset.QueryParams{Offset: len(options), Limit: dimensionOptionsBatchSize})
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, batch.Items...)
+
+		if len(batch.Items) == 0 || len(options) >= batch.TotalCount {
+			api.optionsCache.set(event, dimensionName, options)
+			return options, nil
+		}
+	}
+}
+
+// checkObservationCount estimates the maximum number of observations that the provided query can return by
+// multiplying the number of selected options of each dimension, using the total number of options of the
+// dimension for wildcards, and returns an error if the estimate exceeds the configured maximum
+func (api *API) checkObservationCount(ctx context.Context, event *models.FilterSubmitted, queryParameters map[string][]string, wildcardParameters []string, logData log.Data) error {
+	maxCount := api.cfg.MaxObservationCellCount
+	estimate := 1
+
+	for _, options := range queryParameters {
+		if options[0] != "*" {
+			estimate *= len(options)
+		}
+	}
+
+	for _, dimension := range wildcardParameters {
+		dimensionSize, err := api.getDimensionSize(ctx, event, dimension)
+		if err != nil {
+			logData["dimension"] = dimension
+			log.Error(ctx, "get observations: failed to get the number of options of a wildcard dimension", err, logData)
+			return err
+		}
+
+		// stop as soon as the estimate is too large, which also avoids overflowing it
+		if dimensionSize > 0 && estimate > maxCount/dimensionSize {
+			return errs.ErrorTooManyObservations(maxCount)
+		}
+		estimate *= dimensionSize
+	}
+
+	logData["estimated_observation_count"] = estimate
+
+	if estimate > maxCount {
+		return errs.ErrorTooManyObservations(maxCount)
+	}
+
+	return nil
+}
+
+// getObservationBackend returns the backend that the observations of the dataset are routed to by its ID or type, or
+// Cantabular for census datasets that are not routed, or the default backend otherwise
+func (api *API) getObservationBackend(datasetID string, datasetDoc dataset.DatasetDetails) string {
+	if backend, found := api.observationStores.Route(datasetID, datasetDoc.Type); found {
+		return backend
+	}
+	if isCantabularDataset(datasetDoc) {
+		return store.CantabularBackend
+	}
+	backend, _ := api.observationStores.Default()
+	return backend
+}
+
+// getObservationRows streams the rows of observations that match the provided query parameters from the observation store,
+// along with the dimensions that need to be identified against each observation
+func (api *API) getObservationRows(ctx context.Context, observationStore store.ObservationStore, versionDoc *dataset.Version, queryParameters map[string][]string, logData log.Data, event *models.FilterSubmitted) (*observationRows, map[string]struct{}, error) {
 	// Build query (observation.Filter type)
 	var dimensionFilters = make([]*observation.Dimension, 0, len(queryParameters))
 
-	// Unable to have more than one wildcard parameter per query
-	var wildcardParameter string
+	var wildcardParameters []string
 
-	// Build dimension filter object to create queryObject for neo4j
-	for dimension, option := range queryParameters {
-		if option == "*" {
-			if wildcardParameter != "" {
-				return nil, errs.ErrTooManyWildcards
-			}
+	// Dimensions that can take more than one option in the results, which need
+	// to be identified against each observation
+	observationDimensions := make(map[string]struct{})
 
-			wildcardParameter = dimension
+	// Build dimension filter object to create queryObject for neo4j
+	for dimension, options := range queryParameters {
+		if options[0] == "*" {
+			wildcardParameters = append(wildcardParameters, dimension)
+			observationDimensions[dimension] = struct{}{}
 			continue
 		}
 
+		if len(options) > 1 {
+			observationDimensions[dimension] = struct{}{}
+		}
+
 		dimensionFilter := &observation.Dimension{
 			Name:    dimension,
-			Options: []string{option},
+			Options: options,
 		}
 
 		dimensionFilters = append(dimensionFilters, dimensionFilter)
 	}
 
+	// Any wildcard or dimension with more than one option can select more
+	// observations than the maximum, once combined with the other dimensions
+	if len(observationDimensions) > 0 {
+		if err := api.checkObservationCount(ctx, event, queryParameters, wildcardParameters, logData); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	queryObject := observation.DimensionFilters{
 		Dimensions: dimensionFilters,
 	}
@@ -465,62 +1017,29 @@ func (api *API) getObservationList(ctx context.Context, versionDoc *dataset.Vers
 
 	log.Info(ctx, "query object built to retrieve observations from db", logData)
 
-	csvRowReader, err := api.graphDB.StreamCSVRows(ctx, versionDoc.ID, "", &queryObject, &limit)
+	// All the matching observations are streamed (up to the maximum number of observations that a query can select), so
+	// that the total can be counted, but only the requested page is kept. One more observation than the maximum is
+	// streamed to tell whether there are more, in which case the total is capped.
+	scanLimit := api.cfg.MaxObservationCellCount + 1
+	rowReader, err := observationStore.StreamObservations(ctx, versionDoc.ID, &queryObject, &scanLimit)
 	if err != nil {
-		return nil, err
-	}
-	defer csvRowReader.Close(ctx)
-
-	headerRow, err := csvRowReader.Read()
-	if err != nil {
-		return nil, err
-	}
-
-	headerRowReader := csv.NewReader(strings.NewReader(headerRow))
-	headerRowArray, err := headerRowReader.Read()
-	if err != nil {
-		return nil, err
-	}
-
-	dimensionOffset, err := GetDimensionOffsetInHeaderRow(headerRowArray)
-	if err != nil {
-		log.Error(ctx, "get observations: unable to distinguish headers from version document", err, logData)
-		return nil, err
-	}
-
-	var observationRow string
-	var observations []models.Observation
-	// Iterate over observation row reader
-	for observationRow, err = csvRowReader.Read(); err != io.EOF; observationRow, err = csvRowReader.Read() {
-		if err != nil {
-			if strings.Contains(err.Error(), "the filter options created no results") {
-				return nil, errs.ErrObservationsNotFound
-			}
-			return nil, err
-		}
-
-		observationRowReader := csv.NewReader(strings.NewReader(observationRow))
-		observationRowArray, err := observationRowReader.Read()
-		if err != nil {
-			return nil, err
+		if err == store.ErrNotFound {
+			return nil, nil, errs.ErrObservationsNotFound
 		}
-
-		observations = append(observations, createObservation(
-			versionDoc,
-			observationRowArray,
-			headerRowArray,
-			dimensionOffset, wildcardParameter))
+		return nil, nil, err
 	}
 
+	rows := newObservationRows(rowReader)
+	rows.limit = api.cfg.MaxObservationCellCount
+
 	// neo4j will always return the same list of observations in the same
-	// order as it is deterministic for static data, but this does not
-	// necessarily mean we won't want to return observations in a particular
-	// order (which may be costly on the services performance)
+	// order as it is deterministic for static data, which is the order kept
+	// unless another order is requested with the sort query parameter
 
-	return observations, nil
+	return rows, observationDimensions, nil
 }
 
-func createObservation(versionDoc *dataset.Version, observationRowArray, headerRowArray []string, dimensionOffset int, wildcardParameter string) models.Observation {
+func createObservation(versionDoc *dataset.Version, observationRowArray, headerRowArray []string, dimensionOffset int, observationDimensions map[string]struct{}) models.Observation {
 	observation := models.Observation{
 		Observation: observationRowArray[0],
 	}
@@ -542,20 +1061,22 @@ func createObservation(versionDoc *dataset.Version, observationRowArray, headerR
 		versionDocDimensions[dim.Name] = *dim
 	}
 
-	if wildcardParameter != "" {
+	if len(observationDimensions) > 0 {
 		dimensions := make(map[string]*models.DimensionObject)
 
 		for i := dimensionOffset + 2; i < len(observationRowArray); i += 2 {
-			if strings.EqualFold(headerRowArray[i], wildcardParameter) {
-				versionDimension, found := versionDocDimensions[wildcardParameter]
-				if found {
-					dimensions[headerRowArray[i]] = &models.DimensionObject{
-						ID:    observationRowArray[i-1],
-						HRef:  versionDimension.URL + "/codes/" + observationRowArray[i-1],
-						Label: observationRowArray[i],
-					}
+			dimensionName := strings.ToLower(headerRowArray[i])
+			if _, ok := observationDimensions[dimensionName]; !ok {
+				continue
+			}
+
+			versionDimension, found := versionDocDimensions[dimensionName]
+			if found {
+				dimensions[headerRowArray[i]] = &models.DimensionObject{
+					ID:    observationRowArray[i-1],
+					HRef:  versionDimension.URL + "/codes/" + observationRowArray[i-1],
+					Label: observationRowArray[i],
 				}
-				break
 			}
 		}
 		observation.Dimensions = dimensions
@@ -565,16 +1086,26 @@ func createObservation(versionDoc *dataset.Version, observationRowArray, headerR
 
 func handleObservationsErrorType(ctx context.Context, w http.ResponseWriter, err error, data log.Data) {
 	_, isObservationErr := err.(errs.ObservationQueryError)
+	invalidOptionsErr, isInvalidOptionsErr := err.(errs.InvalidOptionsError)
 	var status int
 	resErrMsg := err.Error()
 
 	switch {
+	case isInvalidOptionsErr:
+		// the invalid options are reported as JSON, so that clients can tell which options to correct without parsing a message
+		if data == nil {
+			data = log.Data{}
+		}
+		data["responseStatus"] = http.StatusBadRequest
+		log.Error(ctx, "get observation endpoint: request unsuccessful", err, data)
+		writeErrorJSON(ctx, w, invalidOptionsErr, http.StatusBadRequest)
+		return
 	case isObservationErr:
 		status = http.StatusBadRequest
 	case observationNotFound[err]:
 		status = http.StatusNotFound
-	case observationBadRequest[err]:
-		status = http.StatusBadRequest
+	case err == errs.ErrV4FileTooLarge:
+		status = http.StatusRequestEntityTooLarge
 	default:
 		resErrMsg = errs.ErrInternalServer.Error()
 		status = http.StatusInternalServerError
@@ -588,3 +1119,20 @@ func handleObservationsErrorType(ctx context.Context, w http.ResponseWriter, err
 	log.Error(ctx, "get observation endpoint: request unsuccessful", err, data)
 	http.Error(w, resErrMsg, status)
 }
+
+// writeErrorJSON writes the error as the JSON body of a response with the provided status
+func writeErrorJSON(ctx context.Context, w http.ResponseWriter, body interface{}, status int) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		log.Error(ctx, "failed to marshal error response", err)
+		http.Error(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "failed to write error response", err)
+	}
+}
@@ -1,19 +1,26 @@
 package api_test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
+	dphttp "github.com/ONSdigital/dp-net/http"
 	"github.com/ONSdigital/dp-net/request"
 	"github.com/pkg/errors"
 
+	"github.com/ONSdigital/dp-api-clients-go/v2/cantabular"
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/v2/hierarchy"
 	"github.com/ONSdigital/dp-authorisation/auth"
 	"github.com/ONSdigital/dp-graph/v2/observation"
 	"github.com/ONSdigital/dp-graph/v2/observation/observationtest"
@@ -22,7 +29,14 @@ import (
 	errs "github.com/ONSdigital/dp-observation-api/apierrors"
 	"github.com/ONSdigital/dp-observation-api/config"
 	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/dp-observation-api/store"
+	storeMock "github.com/ONSdigital/dp-observation-api/store/mock"
 	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -31,334 +45,2669 @@ var (
 	dimension2                   = dataset.VersionDimension{Name: "geography"}
 	dimension3                   = dataset.VersionDimension{Name: "time"}
 	dimension4                   = dataset.VersionDimension{Name: "age"}
+	dataMarkingUsageNotes        = &[]dataset.UsageNote{{Title: "data_marking", Note: "this marks the observation with a special character"}}
 	observationAPIMockURL        = "http://localhost:8082"
 	datasetAPIMockURL            = "http://localhost:8080"
 	foodObservationResponse      = "146.3,p,2,Month,Aug-16,K02000001,,cpi1dim1G10100,01.1 Food"
 	aggregateObservationResponse = "v4_2,data_marking,confidence_interval,time,time,geography_code,geography,aggregate_code,aggregate"
+	codeListDimensions           = []dataset.VersionDimension{
+		{Name: "aggregate", URL: "http://localhost:8081/code-lists/cpih1dim1aggid"},
+		{Name: "geography", URL: "http://localhost:8081/code-lists/uk-only"},
+		{Name: "time", URL: "http://localhost:8081/code-lists/time"},
+	}
 )
 
 func TestGetObservationsReturnsOK(t *testing.T) {
 	Convey("Given a request to get a single observation for a version of a dataset returns 200 OK response", t, func() {
-		dimensions := []dataset.VersionDimension{
-			{
-				Name: "aggregate",
-				URL:  "http://localhost:8081/code-lists/cpih1dim1aggid",
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+		)
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		cMock := &mock.CantabularClientMock{}
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: codeListDimensions,
+			Links: dataset.Links{
+				Dataset: dataset.Link{ID: "cpih012"},
+				Edition: dataset.Link{ID: "2017"},
+				Version: dataset.Link{
+					URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
+					ID:  "1",
+				},
 			},
-			{
-				Name: "geography",
-				URL:  "http://localhost:8081/code-lists/uk-only",
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{UsageNotes: dataMarkingUsageNotes})
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		Convey("When request contains query parameters where the dimension name is in lower casing", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithSingleObservation"))
+
+			validateGetDataset(dcMock, "cpih012")
+			validateGetVersion(dcMock, "cpih012", "2017", "1")
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When request contains query parameters where the dimension name is in upper casing", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&AggregaTe=cpi1dim1S40403&GEOGRAPHY=K02000001", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedSecondDocWithSingleObservation"))
+
+			validateGetDataset(dcMock, "cpih012")
+			validateGetVersion(dcMock, "cpih012", "2017", "1")
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		})
+
+		expectedCSV := "time_code,time,geography_code,geography,aggregate_code,aggregate,observation,data_marking,confidence_interval\n" +
+			"Month,Aug-16,K02000001,,cpi1dim1G10100,01.1 Food,146.3,p,2\n"
+
+		Convey("When request contains the format query parameter set to csv", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001&format=csv", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+			So(w.Header().Get("Link"), ShouldEqual, `<http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations/metadata.json?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001&format=csv>; rel="describedby"; type="application/csvm+json"`)
+			So(w.Body.String(), ShouldEqual, expectedCSV)
+			So(w.Result().Trailer.Get("X-Stream-Error"), ShouldBeEmpty)
+
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When request accepts text/csv in preference to json", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
+			r.Header.Set("Accept", "application/json;q=0.5, text/csv")
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+			So(w.Body.String(), ShouldEqual, expectedCSV)
+		})
+
+		Convey("When request accepts any media type", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
+			r.Header.Set("Accept", "*/*")
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithSingleObservation"))
+		})
+
+		Convey("When request contains an unsupported format query parameter", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001&format=pdf", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(w.Body.String(), ShouldContainSubstring, "invalid format query parameter")
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 0)
+		})
+	})
+
+	Convey("A successful request to get multiple observations via a wildcard for a version of a dataset returns 200 OK response", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"112.1,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+		)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: codeListDimensions,
+			Links: dataset.Links{
+				Dataset: dataset.Link{ID: "cpih012"},
+				Edition: dataset.Link{ID: "2017"},
+				Version: dataset.Link{
+					URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
+					ID:  "1",
+				},
 			},
-			{
-				Name: "time",
-				URL:  "http://localhost:8081/code-lists/time",
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{UsageNotes: dataMarkingUsageNotes})
+
+		cMock := &mock.CantabularClientMock{}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		ap.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithMultipleObservations"))
+
+		validateGetDataset(dcMock, "cpih012")
+		validateGetVersion(dcMock, "cpih012", "2017", "1")
+		So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+		So(len(mockRowReader.ReadCalls()), ShouldEqual, 4)
+	})
+
+	Convey("Given a request to get multiple observations via a wildcard in another format returns 200 OK response", t, func() {
+		dimensions := append([]dataset.VersionDimension(nil), codeListDimensions...)
+		dimensions[0].Label = "Aggregate"
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"x,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+		)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: dimensions,
+			Links: dataset.Links{
+				Dataset: dataset.Link{ID: "cpih012"},
+				Edition: dataset.Link{ID: "2017"},
+				Version: dataset.Link{
+					URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
+					ID:  "1",
+				},
 			},
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{
+			Title:         "Consumer Prices Index including owner occupiers’ housing costs (CPIH)",
+			UnitOfMeasure: "Index: 2015=100",
+			UsageNotes:    dataMarkingUsageNotes,
+		})
+
+		cMock := &mock.CantabularClientMock{}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		Convey("When request accepts JSON-stat", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
+			r.Header.Set("Accept", "application/json+stat")
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json+stat")
+
+			var jsonStat models.JSONStatDataset
+			So(json.Unmarshal(w.Body.Bytes(), &jsonStat), ShouldBeNil)
+			So(jsonStat.Version, ShouldEqual, "2.0")
+			So(jsonStat.Class, ShouldEqual, "dataset")
+			So(jsonStat.Label, ShouldEqual, "Consumer Prices Index including owner occupiers’ housing costs (CPIH)")
+			So(jsonStat.Note, ShouldResemble, []string{"data_marking: this marks the observation with a special character"})
+			So(jsonStat.ID, ShouldResemble, []string{"aggregate", "geography", "time"})
+			So(jsonStat.Size, ShouldResemble, []int{2, 1, 1})
+			So(jsonStat.Role, ShouldResemble, map[string][]string{"geo": {"geography"}, "time": {"time"}})
+			So(jsonStat.Dimension["aggregate"], ShouldResemble, &models.JSONStatDimension{
+				Label: "Aggregate",
+				Href:  "http://localhost:8081/code-lists/cpih1dim1aggid",
+				Category: &models.JSONStatCategory{
+					Index: []string{"cpi1dim1G10100", "cpi1dim1G10101"},
+					Label: map[string]string{"cpi1dim1G10100": "01.1 Food", "cpi1dim1G10101": "01.2 Waste"},
+				},
+			})
+			So(jsonStat.Dimension["time"].Category.Index, ShouldResemble, []string{"16-Aug"})
+
+			value := 146.3
+			So(jsonStat.Value, ShouldResemble, []*float64{&value, nil})
+			So(jsonStat.Status, ShouldResemble, map[string]string{"0": "p", "1": "x"})
+			So(jsonStat.Extension.UnitOfMeasure, ShouldEqual, "Index: 2015=100")
+			So(jsonStat.Extension.Count, ShouldEqual, 2)
+			So(jsonStat.Extension.TotalObservations, ShouldEqual, 2)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 4)
+		})
+
+		Convey("When request contains the format query parameter set to sdmx-json", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&format=sdmx-json", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.sdmx.data+json; version=1.0.0")
+
+			var message models.SDMXJSONMessage
+			So(json.Unmarshal(w.Body.Bytes(), &message), ShouldBeNil)
+			So(message.Header.ID, ShouldEqual, "cpih012-2017-1")
+			So(message.Header.Sender.ID, ShouldEqual, "ONS")
+			So(message.Structure.Name, ShouldEqual, "Consumer Prices Index including owner occupiers’ housing costs (CPIH)")
+
+			seriesDimensions := message.Structure.Dimensions.Series
+			So(seriesDimensions, ShouldHaveLength, 2)
+			So(seriesDimensions[0].ID, ShouldEqual, "aggregate")
+			So(seriesDimensions[0].Name, ShouldEqual, "Aggregate")
+			So(seriesDimensions[0].Values, ShouldResemble, []*models.SDMXJSONComponentValue{
+				{ID: "cpi1dim1G10100", Name: "01.1 Food"},
+				{ID: "cpi1dim1G10101", Name: "01.2 Waste"},
+			})
+			So(seriesDimensions[1].ID, ShouldEqual, "geography")
+			So(message.Structure.Dimensions.Observation, ShouldHaveLength, 1)
+			So(message.Structure.Dimensions.Observation[0].ID, ShouldEqual, "time")
+			So(message.Structure.Dimensions.Observation[0].Role, ShouldEqual, "time")
+
+			attributes := message.Structure.Attributes.Observation
+			So(attributes, ShouldHaveLength, 2)
+			So(attributes[0].ID, ShouldEqual, "confidence_interval")
+			So(attributes[1].ID, ShouldEqual, "data_marking")
+			So(attributes[1].Values, ShouldResemble, []*models.SDMXJSONComponentValue{{ID: "p", Name: "p"}})
+
+			So(message.DataSets, ShouldHaveLength, 1)
+			So(message.DataSets[0].Series["0:0"].Observations["0"], ShouldResemble, []interface{}{146.3, float64(0), float64(0)})
+			So(message.DataSets[0].Series["1:0"].Observations["0"], ShouldResemble, []interface{}{nil, nil, nil})
+		})
+
+		Convey("When request accepts SDMX-ML generic data", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
+			r.Header.Set("Accept", "application/vnd.sdmx.genericdata+xml;version=2.1")
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.sdmx.genericdata+xml; version=2.1")
+
+			body := w.Body.String()
+			So(body, ShouldStartWith, `<?xml version="1.0" encoding="UTF-8"?>`)
+			So(body, ShouldContainSubstring, `<message:GenericData xmlns:message="http://www.sdmx.org/resources/sdmxml/schemas/v2_1/message"`)
+			So(body, ShouldContainSubstring, `<message:Structure structureID="cpih012" dimensionAtObservation="time"><common:Structure><Ref agencyID="ONS" id="cpih012" version="1"></Ref></common:Structure></message:Structure>`)
+			So(body, ShouldContainSubstring, `<generic:Series><generic:SeriesKey><generic:Value id="aggregate" value="cpi1dim1G10100"></generic:Value><generic:Value id="geography" value="K02000001"></generic:Value></generic:SeriesKey>`+
+				`<generic:Obs><generic:ObsDimension value="16-Aug"></generic:ObsDimension><generic:ObsValue value="146.3"></generic:ObsValue>`+
+				`<generic:Attributes><generic:Value id="confidence_interval" value="2"></generic:Value><generic:Value id="data_marking" value="p"></generic:Value></generic:Attributes></generic:Obs></generic:Series>`)
+			So(body, ShouldContainSubstring, `<generic:Obs><generic:ObsDimension value="16-Aug"></generic:ObsDimension><generic:ObsValue value="x"></generic:ObsValue></generic:Obs>`)
+		})
+
+		Convey("When request contains the format query parameter set to xlsx", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&format=xlsx", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+
+			workbook, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			So(err, ShouldBeNil)
+
+			parts := make(map[string]string)
+			for _, f := range workbook.File {
+				rc, err := f.Open()
+				So(err, ShouldBeNil)
+				b, err := io.ReadAll(rc)
+				So(err, ShouldBeNil)
+				rc.Close()
+				parts[f.Name] = string(b)
+			}
+
+			So(parts, ShouldContainKey, "[Content_Types].xml")
+			So(parts, ShouldContainKey, "_rels/.rels")
+			So(parts["xl/workbook.xml"], ShouldContainSubstring, `<sheet name="Data" sheetId="1" r:id="rId1"/><sheet name="Notes" sheetId="2" r:id="rId2"/>`)
+
+			data := parts["xl/worksheets/sheet1.xml"]
+			So(data, ShouldContainSubstring, `<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">time_code</t></is></c>`)
+			So(data, ShouldContainSubstring, `<c r="E2" t="inlineStr"><is><t xml:space="preserve">cpi1dim1G10100</t></is></c><c r="F2" t="inlineStr"><is><t xml:space="preserve">01.1 Food</t></is></c><c r="G2"><v>146.3</v></c>`)
+			So(data, ShouldContainSubstring, `<c r="G3" t="inlineStr"><is><t xml:space="preserve">x</t></is></c>`)
+
+			notes := parts["xl/worksheets/sheet2.xml"]
+			So(notes, ShouldContainSubstring, `<t xml:space="preserve">Index: 2015=100</t>`)
+			So(notes, ShouldContainSubstring, `<t xml:space="preserve">http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&amp;aggregate=*&amp;geography=K02000001&amp;format=xlsx</t>`)
+			So(notes, ShouldContainSubstring, `<t xml:space="preserve">this marks the observation with a special character</t>`)
+		})
+
+		Convey("When request accepts an arrow stream", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
+			r.Header.Set("Accept", "application/vnd.apache.arrow.stream")
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.apache.arrow.stream")
+
+			reader, err := ipc.NewReader(bytes.NewReader(w.Body.Bytes()))
+			So(err, ShouldBeNil)
+			defer reader.Release()
+
+			schema := reader.Schema()
+			So(schema.NumFields(), ShouldEqual, 9)
+			So(schema.Field(4).Name, ShouldEqual, "aggregate_code")
+			So(schema.Field(4).Type, ShouldResemble, &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String})
+			So(schema.Field(6).Name, ShouldEqual, "observation")
+			So(schema.Field(6).Type, ShouldEqual, arrow.PrimitiveTypes.Float64)
+			So(schema.Field(7).Type, ShouldEqual, arrow.BinaryTypes.String)
+			unitOfMeasure, _ := schema.Metadata().GetValue("unit_of_measure")
+			So(unitOfMeasure, ShouldEqual, "Index: 2015=100")
+
+			So(reader.Next(), ShouldBeTrue)
+			record := reader.RecordBatch()
+			So(record.NumRows(), ShouldEqual, 2)
+
+			codes := record.Column(4).(*array.Dictionary)
+			So(codes.Dictionary().(*array.String).Value(codes.GetValueIndex(1)), ShouldEqual, "cpi1dim1G10101")
+
+			observations := record.Column(6).(*array.Float64)
+			So(observations.Value(0), ShouldEqual, 146.3)
+			So(observations.IsNull(1), ShouldBeTrue)
+			So(record.Column(7).(*array.String).Value(0), ShouldEqual, "p")
+			So(record.Column(7).IsNull(1), ShouldBeTrue)
+
+			So(reader.Next(), ShouldBeFalse)
+			So(reader.Err(), ShouldBeNil)
+		})
+
+		Convey("When request contains the format query parameter set to parquet", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&format=parquet", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.apache.parquet")
+
+			table, err := pqarrow.ReadTable(context.Background(), bytes.NewReader(w.Body.Bytes()), nil, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+			So(err, ShouldBeNil)
+			defer table.Release()
+
+			So(table.NumRows(), ShouldEqual, 2)
+			So(table.NumCols(), ShouldEqual, 9)
+			So(table.Schema().Field(6).Name, ShouldEqual, "observation")
+
+			observations := table.Column(6).Data().Chunk(0).(*array.Float64)
+			So(observations.Value(0), ShouldEqual, 146.3)
+			So(observations.IsNull(1), ShouldBeTrue)
+		})
+	})
+
+	Convey("A successful request to get multiple observations via several options for a dimension returns 200 OK response", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1G10100&aggregate=cpi1dim1G10101&geography=K02000001", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"112.1,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+		)
+
+		dcMock := newDatasetClientMock(codeListDimensions...)
+
+		cMock := &mock.CantabularClientMock{}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		ap.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithMultipleOptions"))
+
+		So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+		filters := graphDBMock.StreamCSVRowsCalls()[0].Filters
+		So(filters.Dimensions, ShouldContain, &observation.Dimension{Name: "aggregate", Options: []string{"cpi1dim1G10100", "cpi1dim1G10101"}})
+	})
+
+	Convey("A successful request to get multiple observations via several wildcards for a version of a dataset returns 200 OK response", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=*&aggregate=*&geography=K02000001", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"112.1,,,Month,Sep-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+		)
+
+		dcMock := newDatasetClientMock(codeListDimensions...)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if q.Limit > 0 {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+			return dataset.Options{TotalCount: 100}, nil
 		}
-		usagesNotes := &[]dataset.UsageNote{{Title: "data_marking", Note: "this marks the observation with a special character"}}
 
-		count := 0
-		mockRowReader := &observationtest.StreamRowReaderMock{
-			ReadFunc: func() (string, error) {
-				count++
-				if count == 1 {
-					return aggregateObservationResponse, nil
-				} else if count == 2 {
-					return foodObservationResponse, nil
-				}
-				return "", io.EOF
-			},
-			CloseFunc: func(context.Context) error {
-				return nil
+		cMock := &mock.CantabularClientMock{}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		ap.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+
+		var observationsDoc models.ObservationsDoc
+		So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+		So(observationsDoc.Observations, ShouldHaveLength, 2)
+		So(observationsDoc.Observations[1].Dimensions["aggregate"], ShouldResemble, &models.DimensionObject{
+			HRef:  "http://localhost:8081/code-lists/cpih1dim1aggid/codes/cpi1dim1G10101",
+			ID:    "cpi1dim1G10101",
+			Label: "01.2 Waste",
+		})
+		So(observationsDoc.Observations[1].Dimensions["time"], ShouldResemble, &models.DimensionObject{
+			HRef:  "http://localhost:8081/code-lists/time/codes/Month",
+			ID:    "Month",
+			Label: "Sep-16",
+		})
+		So(observationsDoc.Observations[1].Dimensions, ShouldNotContainKey, "geography")
+		So(observationsDoc.Dimensions, ShouldHaveLength, 1)
+
+		// the geography option is validated, and the sizes of both wildcard dimensions are checked
+		So(len(dcMock.GetOptionsCalls()), ShouldEqual, 3)
+		So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+		So(graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions, ShouldHaveLength, 1)
+	})
+
+	Convey("A successful request to get a page of observations via offset and limit returns 200 OK response", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&offset=1&limit=1", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"112.1,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+			"98.7,,,Month,Aug-16,K02000001,,cpi1dim1G10102,01.3 Drink",
+		)
+
+		dcMock := newDatasetClientMock(codeListDimensions...)
+
+		cMock := &mock.CantabularClientMock{}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		ap.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+
+		var observationsDoc models.ObservationsDoc
+		So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+		So(observationsDoc.Observations, ShouldHaveLength, 1)
+		So(observationsDoc.Observations[0].Observation, ShouldEqual, "112.1")
+		So(observationsDoc.Count, ShouldEqual, 1)
+		So(observationsDoc.Offset, ShouldEqual, 1)
+		So(observationsDoc.Limit, ShouldEqual, 1)
+		So(observationsDoc.TotalObservations, ShouldEqual, 3)
+		So(observationsDoc.TotalCapped, ShouldBeFalse)
+
+		pageURL := "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001"
+		So(observationsDoc.Links.First.URL, ShouldEqual, pageURL+"&offset=0&limit=1")
+		So(observationsDoc.Links.Prev.URL, ShouldEqual, pageURL+"&offset=0&limit=1")
+		So(observationsDoc.Links.Next.URL, ShouldEqual, pageURL+"&offset=2&limit=1")
+		So(observationsDoc.Links.Last.URL, ShouldEqual, pageURL+"&offset=2&limit=1")
+
+		// every observation is streamed to count the total, up to one more than the maximum a query can select
+		So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+		So(*graphDBMock.StreamCSVRowsCalls()[0].Limit, ShouldEqual, cfg.MaxObservationCellCount+1)
+		So(len(mockRowReader.ReadCalls()), ShouldEqual, 5)
+	})
+
+	Convey("Given a request to get a single observation for a version of a dataset with rewriting enabled returns 200 OK response", t, func() {
+		mockRowReader := newRowReaderMock(
+			aggregateObservationResponse,
+			foodObservationResponse,
+		)
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		cMock := &mock.CantabularClientMock{}
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: codeListDimensions,
+			Links: dataset.Links{
+				Dataset: dataset.Link{ID: "cpih012"},
+				Edition: dataset.Link{ID: "2017"},
+				Version: dataset.Link{
+					URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
+					ID:  "1",
+				},
 			},
-		}
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{UsageNotes: dataMarkingUsageNotes})
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := true
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		Convey("When request contains query parameters where the dimension name is in lower casing", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			r.Header.Add("X-Forwarded-Proto", "https")
+			r.Header.Add("X-Forwarded-Host", "api.example.com")
+			r.Header.Add("X-Forwarded-Path-Prefix", "v1")
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithSingleObservationAndRewriting"))
+
+			validateGetDataset(dcMock, "cpih012")
+			validateGetVersion(dcMock, "cpih012", "2017", "1")
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When request contains query parameters where the dimension name is in upper casing", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&AggregaTe=cpi1dim1S40403&GEOGRAPHY=K02000001", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			r.Header.Add("X-Forwarded-Proto", "https")
+			r.Header.Add("X-Forwarded-Host", "api.example.com")
+			r.Header.Add("X-Forwarded-Path-Prefix", "v1")
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedSecondDocWithSingleObservationAndRewriting"))
+
+			validateGetDataset(dcMock, "cpih012")
+			validateGetVersion(dcMock, "cpih012", "2017", "1")
+			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
+			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestPostObservationsQuery(t *testing.T) {
+	Convey("Given an API with a published version of a dataset", t, func() {
+		rows := []string{
+			aggregateObservationResponse,
+			foodObservationResponse,
+			"112.1,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste",
+		}
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: codeListDimensions,
+			Links: dataset.Links{
+				Dataset: dataset.Link{ID: "cpih012"},
+				Edition: dataset.Link{ID: "2017"},
+				Version: dataset.Link{ID: "1"},
+			},
+		})
+
+		// every query streams the rows from the start
+		graphDBMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				return newRowReaderMock(rows...), nil
+			},
+		}
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, hierarchyClientWithoutHierarchies(), &auth.NopHandler{}, enableURLRewriting)
+
+		postQuery := func(body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("POST", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations/query", bytes.NewBufferString(body))
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("When the body selects options, a wildcard and a page of observations", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","wildcard":true},{"name":"Geography","options":["K02000001"]}],"offset":1,"limit":1}`)
+
+			Convey("Then the page of observations is returned as for the equivalent query parameters", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "112.1")
+				So(observationsDoc.Offset, ShouldEqual, 1)
+				So(observationsDoc.Limit, ShouldEqual, 1)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.Links.Self.URL, ShouldEqual, "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?Geography=K02000001&aggregate=%2A&limit=1&offset=1&time=16-Aug")
+
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+				So(graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When the body selects a format", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","options":["*"]},{"name":"geography","options":["K02000001"]}],"format":"csv"}`)
+
+			Convey("Then the observations are returned in that format", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+				So(w.Body.String(), ShouldStartWith, "time_code,time,geography_code,geography,aggregate_code,aggregate,observation,data_marking,confidence_interval\n")
+			})
+		})
+
+		Convey("When the body selects an order", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","wildcard":true},{"name":"geography","options":["K02000001"]}],"sort":"value"}`)
+
+			Convey("Then the observations are returned in that order", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 2)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "112.1")
+				So(observationsDoc.Observations[1].Observation, ShouldEqual, "146.3")
+				So(observationsDoc.Links.Self.URL, ShouldContainSubstring, "sort=value")
+			})
+		})
+
+		Convey("When the body does not select every dimension", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]}]}`)
+
+			Convey("Then the missing dimensions are reported as for query parameters", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorMissingQueryParameters([]string{"aggregate", "geography"}).Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the body has a dimension without options that is not a wildcard", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","options":[" "]},{"name":"geography","options":["K02000001"]}]}`)
+
+			Convey("Then a bad request is returned instead of selecting the default option of the dimension", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "invalid query body: dimensions must select options or be a wildcard: aggregate")
+				So(dcMock.GetCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the body selects an option containing a comma", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","wildcard":true},{"name":"geography","options":["K02000001,E92000001"]}]}`)
+
+			Convey("Then it is validated as a single option rather than split into two", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "K02000001,E92000001")
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the body selects an option of the dimension containing a comma", func() {
+			dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+				options, err := getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+				options.Items = append(options.Items, dataset.Option{DimensionID: dimension, Option: "K02000001,E92000001"})
+				options.Count++
+				options.TotalCount++
+				return options, err
+			}
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"aggregate","wildcard":true},{"name":"geography","options":["K02000001,E92000001"]}],"limit":1}`)
+
+			Convey("Then the links select the option between quotes, so that it is not split when they are followed", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Links.Self.URL, ShouldContainSubstring, "geography=%22K02000001%2CE92000001%22")
+				So(observationsDoc.Links.Next.URL, ShouldContainSubstring, "geography=%22K02000001%2CE92000001%22")
+
+				r := httptest.NewRequest("GET", observationsDoc.Links.Next.URL, http.NoBody)
+				next := httptest.NewRecorder()
+				ap.Router.ServeHTTP(next, r)
+				So(next.Code, ShouldEqual, http.StatusOK)
+
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 2)
+				for _, call := range graphDBMock.StreamCSVRowsCalls() {
+					So(call.Filters.Dimensions, ShouldContain, &observation.Dimension{Name: "geography", Options: []string{"K02000001,E92000001"}})
+				}
+			})
+		})
+
+		Convey("When the body selects a dimension twice", func() {
+			w := postQuery(`{"dimensions":[{"name":"time","options":["16-Aug"]},{"name":"Time","options":["17-Aug"]}]}`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "invalid query body: dimensions can only be selected once: Time")
+			})
+		})
+
+		Convey("When the body has an unknown field", func() {
+			w := postQuery(`{"dimensions":[],"order":"value"}`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "invalid query body")
+			})
+		})
+
+		Convey("When the body is not valid JSON", func() {
+			w := postQuery(`{"dimensions":`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "invalid query body")
+				So(dcMock.GetCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithHierarchySelectors(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with a geography hierarchy", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"146.3,Month,Aug-16,E92000001,England,cpi1dim1A0,CPI (overall index)",
+		)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			ID:         "instance-id",
+			Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
+		})
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		hierarchyNode := func(code string, hasData bool, numberOfChildren int) hierarchy.Child {
+			return hierarchy.Child{
+				HasData:          hasData,
+				NumberofChildren: numberOfChildren,
+				Links:            hierarchy.Links{Code: hierarchy.Link{ID: code}},
+			}
+		}
+
+		// K02000001 > E92000001 > E12000007 > E09000001
+		//                       > E12000008
+		//           > W92000004
+		//           > N92000002 (without data)
+		hierarchyChildren := map[string][]hierarchy.Child{
+			"K02000001": {hierarchyNode("E92000001", true, 2), hierarchyNode("W92000004", true, 0), hierarchyNode("N92000002", false, 0)},
+			"E92000001": {hierarchyNode("E12000007", true, 1), hierarchyNode("E12000008", true, 0)},
+			"E12000007": {hierarchyNode("E09000001", true, 0)},
+		}
+
+		hMock := &mock.IHierarchyClientMock{
+			GetRootFunc: func(ctx context.Context, instanceID string, name string) (hierarchy.Model, error) {
+				return hierarchy.Model{
+					HasData:  true,
+					Links:    hierarchy.Links{Code: hierarchy.Link{ID: "K02000001"}},
+					Children: hierarchyChildren["K02000001"],
+				}, nil
+			},
+			GetChildFunc: func(ctx context.Context, instanceID string, name string, code string) (hierarchy.Model, error) {
+				if code == "E99999999" {
+					return hierarchy.Model{}, hierarchy.NewErrInvalidHierarchyAPIResponse(http.StatusOK, http.StatusNotFound, "/hierarchies/instance-id/geography/E99999999")
+				}
+				return hierarchy.Model{
+					HasData:  true,
+					Links:    hierarchy.Links{Code: hierarchy.Link{ID: code}},
+					Children: hierarchyChildren[code],
+				}, nil
+			},
+		}
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, hMock, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(geography string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1A0&geography="+url.QueryEscape(geography), nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		geographyFilter := func() []string {
+			So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+			for _, dimension := range graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions {
+				if dimension.Name == "geography" {
+					return dimension.Options
+				}
+			}
+			return nil
+		}
+
+		Convey("When the children of a code are selected", func() {
+			w := getObservations("children(K02000001)")
+
+			Convey("Then the observations of the children that have data are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"E92000001", "W92000004"})
+				So(hMock.GetChildCalls(), ShouldHaveLength, 1)
+				So(hMock.GetChildCalls()[0].InstanceID, ShouldEqual, "instance-id")
+				So(hMock.GetChildCalls()[0].Name, ShouldEqual, "geography")
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Dimensions["geography"].LinkObjects, ShouldHaveLength, 2)
+				So(observationsDoc.Links.Self.URL, ShouldContainSubstring, "geography=children%28K02000001%29")
+			})
+		})
+
+		Convey("When the descendants of a code are selected along with other options", func() {
+			w := getObservations("descendants(E92000001),E12000008,W92000004")
+
+			Convey("Then the observations of every descendant are queried once", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"E12000007", "E12000008", "E09000001", "W92000004"})
+			})
+		})
+
+		Convey("When a level of the hierarchy is selected", func() {
+			w := getObservations("level(2)")
+
+			Convey("Then the observations of the codes at that level are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"E12000007", "E12000008"})
+				So(hMock.GetRootCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the root level of the hierarchy is selected", func() {
+			w := getObservations("level(0)")
+
+			Convey("Then the observation of the root code is queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"K02000001"})
+			})
+		})
+
+		Convey("When the level of a selector is not a number", func() {
+			w := getObservations("level(regions)")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidSelector("level(regions)", "the level must be a positive integer").Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a selector has a code that is not in the hierarchy", func() {
+			w := getObservations("children(E99999999)")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidSelector("children(E99999999)", "the code is not in the hierarchy of the dimension").Error())
+			})
+		})
+
+		Convey("When a selector does not select any code with data", func() {
+			w := getObservations("children(W92000004)")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "it does not select any options with observations")
+			})
+		})
+
+		Convey("When the hierarchy API fails", func() {
+			hMock.GetChildFunc = func(ctx context.Context, instanceID string, name string, code string) (hierarchy.Model, error) {
+				return hierarchy.Model{}, errors.New("hierarchy api unavailable")
+			}
+			w := getObservations("children(K02000001)")
+
+			Convey("Then an internal server error is returned", func() {
+				assertInternalServerErr(w)
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithRangeSelectors(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with an ordered time dimension", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"146.3,2016,2016,K02000001,,cpi1dim1A0,CPI (overall index)",
+		)
+
+		// the periods are not listed in chronological order
+		timeOptions := []string{"2016", "2014", "2015", "2019", "2017", "2018", "2021", "2020", "1999"}
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if dimension != "time" {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+
+			// dataset API returns fewer options than requested, so that they are requested a batch at a time
+			options := dataset.Options{TotalCount: len(timeOptions)}
+			for i := q.Offset; i < len(timeOptions) && i < q.Offset+4; i++ {
+				options.Items = append(options.Items, dataset.Option{DimensionID: dimension, Option: timeOptions[i]})
+			}
+			options.Count = len(options.Items)
+			return options, nil
+		}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(time string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?geography=K02000001&aggregate=cpi1dim1A0&time="+url.QueryEscape(time), nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		timeFilter := func() []string {
+			So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+			for _, dimension := range graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions {
+				if dimension.Name == "time" {
+					return dimension.Options
+				}
+			}
+			return nil
+		}
+
+		Convey("When a range between two options is selected", func() {
+			w := getObservations("2015..2018")
+
+			Convey("Then the observations of every option of the range are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(timeFilter(), ShouldResemble, []string{"2015", "2016", "2017", "2018"})
+
+				timeCalls := getOptionsCallsOf(dcMock, "time")
+				So(timeCalls, ShouldHaveLength, 3)
+				So(timeCalls[0].Q.Offset, ShouldEqual, 0)
+				So(timeCalls[1].Q.Offset, ShouldEqual, 4)
+				So(timeCalls[2].Q.Offset, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When an option of the dimension that looks like a range is selected", func() {
+			timeOptions = append(timeOptions, "2014..2015")
+			w := getObservations("2014..2015")
+
+			Convey("Then the observations of that option are queried rather than of a range", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(timeFilter(), ShouldResemble, []string{"2014..2015"})
+			})
+		})
+
+		Convey("When a range from an option is selected", func() {
+			w := getObservations("from:2020")
+
+			Convey("Then the observations of the option and of every following period are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(timeFilter(), ShouldResemble, []string{"2020", "2021"})
+			})
+		})
+
+		Convey("When a range up to an option is selected along with other options", func() {
+			w := getObservations("to:2015,2015,2019")
+
+			Convey("Then the observations of every preceding period and of the other options are queried once", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(timeFilter(), ShouldResemble, []string{"1999", "2014", "2015", "2019"})
+			})
+		})
+
+		Convey("When a bound of a range is not an option of the dimension", func() {
+			w := getObservations("2010..2018")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidRange("2010..2018", "the start 2010 is not an option of the dimension").Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the end of a range is not an option of the dimension", func() {
+			w := getObservations("to:2030")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidRange("to:2030", "the end 2030 is not an option of the dimension").Error())
+			})
+		})
+
+		Convey("When a range is reversed", func() {
+			w := getObservations("2018..2015")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidRange("2018..2015", "the start comes after the end in the order of the dimension options").Error())
+			})
+		})
+
+		Convey("When a range has no bounds", func() {
+			w := getObservations("..")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidRange("..", "a start or an end must be provided").Error())
+			})
+		})
+
+		Convey("When dataset API fails to return the options of the dimension", func() {
+			dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+				return dataset.Options{}, errors.New("dataset api unavailable")
+			}
+			w := getObservations("2015..2018")
+
+			Convey("Then an internal server error is returned", func() {
+				assertInternalServerErr(w)
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithDefaultOptions(t *testing.T) {
+	Convey("Given an API with a published version of a dataset", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"146.3,Month,Aug-16,K02000001,United Kingdom,cpi1dim1A0,CPI (overall index)",
+		)
+
+		// the aggregate dimension has several options, and the geography dimension has a single option
+		dimensionOptions := map[string][]string{
+			"aggregate": {"cpi1dim1A0", "cpi1dim1G10100"},
+			"geography": {"K02000001"},
+			"time":      {"16-Aug", "16-Sep"},
+		}
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			ID:         "instance-id",
+			Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
+		})
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			options := dataset.Options{TotalCount: len(dimensionOptions[dimension])}
+			for i, option := range dimensionOptions[dimension] {
+				if (len(q.IDs) == 0 && i >= q.Offset && i < q.Offset+q.Limit) || containsString(q.IDs, option) {
+					options.Items = append(options.Items, dataset.Option{DimensionID: dimension, Option: option})
+				}
+			}
+			options.Count = len(options.Items)
+			return options, nil
+		}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		// the aggregate dimension has a hierarchy whose root is its total
+		hMock := &mock.IHierarchyClientMock{
+			GetRootFunc: func(ctx context.Context, instanceID string, name string) (hierarchy.Model, error) {
+				if name != "aggregate" {
+					return hierarchy.Model{}, hierarchy.NewErrInvalidHierarchyAPIResponse(http.StatusOK, http.StatusNotFound, "/hierarchies/"+instanceID+"/"+name)
+				}
+				return hierarchy.Model{HasData: true, Links: hierarchy.Links{Code: hierarchy.Link{ID: "cpi1dim1A0"}}}, nil
+			},
+		}
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, hMock, &auth.NopHandler{}, enableURLRewriting)
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		filters := func() map[string][]string {
+			So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+			filters := make(map[string][]string)
+			for _, dimension := range graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions {
+				filters[dimension.Name] = dimension.Options
+			}
+			return filters
+		}
+
+		Convey("When the dimensions that have a default option are left out of the query", func() {
+			w := getObservations("time=16-Aug")
+
+			Convey("Then the default options are selected, and reported in the dimensions of the response", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(filters(), ShouldResemble, map[string][]string{
+					"time":      {"16-Aug"},
+					"geography": {"K02000001"},
+					"aggregate": {"cpi1dim1A0"},
+				})
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Dimensions["time"].Default, ShouldBeFalse)
+				So(observationsDoc.Dimensions["geography"].Default, ShouldBeTrue)
+				So(observationsDoc.Dimensions["geography"].LinkObject.ID, ShouldEqual, "K02000001")
+				So(observationsDoc.Dimensions["aggregate"].Default, ShouldBeTrue)
+				So(observationsDoc.Dimensions["aggregate"].LinkObject.ID, ShouldEqual, "cpi1dim1A0")
+				So(observationsDoc.Links.Self.URL, ShouldEqual, "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug")
+
+				So(hMock.GetRootCalls(), ShouldHaveLength, 1)
+				So(hMock.GetRootCalls()[0].InstanceID, ShouldEqual, "instance-id")
+				So(hMock.GetRootCalls()[0].Name, ShouldEqual, "aggregate")
+			})
+		})
+
+		Convey("When a default option is configured for a dimension that is left out of the query", func() {
+			cfg.DefaultDimensionOptions = map[string]string{"aggregate": "cpi1dim1G10100"}
+			defer func() {
+				cfg.DefaultDimensionOptions = nil
+			}()
+			w := getObservations("time=16-Aug&geography=K02000001")
+
+			Convey("Then the configured option is selected, listing the options of the dimension once as they are cached", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(filters()["aggregate"], ShouldResemble, []string{"cpi1dim1G10100"})
+				So(listOptionsCallsOf(dcMock, "aggregate"), ShouldHaveLength, 1)
+				So(hMock.GetRootCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the configured default option is not an option of the dimension", func() {
+			cfg.DefaultDimensionOptions = map[string]string{"aggregate": "all-aggregates"}
+			defer func() {
+				cfg.DefaultDimensionOptions = nil
+			}()
+			w := getObservations("time=16-Aug&geography=K02000001")
+
+			Convey("Then the default option is found from the metadata of the dimension", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(filters()["aggregate"], ShouldResemble, []string{"cpi1dim1A0"})
+			})
+		})
+
+		Convey("When a dimension that does not have a default option is left out of the query", func() {
+			w := getObservations("geography=K02000001")
+
+			Convey("Then a bad request lists the dimensions that need to be selected", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorMissingQueryParameters([]string{"time"}).Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the hierarchy API fails while finding a default option", func() {
+			hMock.GetRootFunc = func(ctx context.Context, instanceID string, name string) (hierarchy.Model, error) {
+				return hierarchy.Model{}, errors.New("hierarchy api unavailable")
+			}
+			w := getObservations("time=16-Aug&geography=K02000001")
+
+			Convey("Then an internal server error is returned", func() {
+				assertInternalServerErr(w)
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithLabelSelectors(t *testing.T) {
+	Convey("Given an API with a published version of a dataset whose options have labels", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"146.3,Month,Aug-16,W06000015,Cardiff,cpi1dim1A0,CPI (overall index)",
+		)
+
+		geographyOptions := []dataset.Option{
+			{Option: "W06000015", Label: "Cardiff"},
+			{Option: "E06000023", Label: "Bristol, City of"},
+			{Option: "W06000022", Label: "Newport"},
+			{Option: "E07000011", Label: "Newport"},
+		}
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if dimension != "geography" {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+			return dataset.Options{Items: geographyOptions, Count: len(geographyOptions), TotalCount: len(geographyOptions)}, nil
+		}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(geography string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1A0&geography="+url.QueryEscape(geography), nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		geographyFilter := func() []string {
+			So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+			for _, dimension := range graphDBMock.StreamCSVRowsCalls()[0].Filters.Dimensions {
+				if dimension.Name == "geography" {
+					return dimension.Options
+				}
+			}
+			return nil
+		}
+
+		Convey("When an option is selected by its label", func() {
+			w := getObservations("label:cardiff")
+
+			Convey("Then the observations of the option with that label are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"W06000015"})
+				So(getOptionsCallsOf(dcMock, "geography"), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When options are selected by a quoted label containing a comma, and by code", func() {
+			w := getObservations(`label:"Bristol, City of",W06000015`)
+
+			Convey("Then the observations of both options are queried", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(geographyFilter(), ShouldResemble, []string{"E06000023", "W06000015"})
+			})
+		})
+
+		Convey("When an option is selected by a label shared by several options", func() {
+			w := getObservations("label:Newport")
+
+			Convey("Then a bad request lists the matching options", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorAmbiguousLabel("label:Newport", []string{"W06000022 (Newport)", "E07000011 (Newport)"}).Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When an option is selected by a label that no option has", func() {
+			w := getObservations("label:Atlantis")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorUnknownLabel("label:Atlantis").Error())
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithInvalidOptions(t *testing.T) {
+	Convey("Given an API with a published version of a dataset whose options have labels", t, func() {
+		dimensionOptions := map[string][]dataset.Option{
+			"aggregate": {{Option: "cpi1dim1A0", Label: "CPI (overall index)"}, {Option: "cpi1dim1G10100", Label: "01.1 Food"}},
+			"geography": {{Option: "W06000015", Label: "Cardiff"}, {Option: "W06000022", Label: "Newport"}, {Option: "K02000001", Label: "United Kingdom"}},
+			"time":      {{Option: "16-Aug"}, {Option: "16-Sep"}},
+		}
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			options := dimensionOptions[dimension]
+			return dataset.Options{Items: options, Count: len(options), TotalCount: len(options)}, nil
+		}
+
+		graphDBMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				read := false
+				return &observationtest.StreamRowReaderMock{
+					ReadFunc: func() (string, error) {
+						if !read {
+							read = true
+							return "v4_0,time,time,geography_code,geography,aggregate_code,aggregate", nil
+						}
+						return "", io.EOF
+					},
+					CloseFunc: func(context.Context) error {
+						return nil
+					},
+				}, nil
+			},
+		}
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("When options that are not options of their dimension are selected", func() {
+			w := getObservations("time=16-Aug,16-Agu&aggregate=*&geography=W0600015,cardif")
+
+			Convey("Then a bad request lists each invalid option as JSON along with the closest options by code or label", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+
+				var body errs.InvalidOptionsError
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Errors, ShouldResemble, []errs.InvalidOption{
+					{Dimension: "geography", Option: "W0600015", Suggestions: []errs.Suggestion{{Code: "W06000015", Label: "Cardiff"}}},
+					{Dimension: "geography", Option: "cardif", Suggestions: []errs.Suggestion{{Code: "W06000015", Label: "Cardiff"}}},
+					{Dimension: "time", Option: "16-Agu", Suggestions: []errs.Suggestion{{Code: "16-Aug"}}},
+				})
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When an option that is not close to any option of its dimension is selected", func() {
+			w := getObservations("time=16-Aug&aggregate=cpi1dim1A0&geography=Atlantis")
+
+			Convey("Then a bad request lists the invalid option as JSON with no suggestions", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldEqual, `{"errors":[{"dimension":"geography","option":"Atlantis","suggestions":[]}]}`)
+			})
+		})
+
+		Convey("When valid options are selected in two requests", func() {
+			first := getObservations("time=16-Aug&aggregate=cpi1dim1A0&geography=W06000015")
+			second := getObservations("time=16-Sep&aggregate=cpi1dim1A0&geography=K02000001")
+
+			Convey("Then the graph database is queried, and the options of each dimension are only requested once", func() {
+				So(first.Code, ShouldEqual, http.StatusOK)
+				So(second.Code, ShouldEqual, http.StatusOK)
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 2)
+				So(dcMock.GetOptionsCalls(), ShouldHaveLength, 3)
+			})
+		})
+	})
+}
+
+func TestGetObservationsSorted(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with observations for several geographies and times", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"10,16-Sep,September 2016,W06000015,Cardiff,cpi1dim1A0,CPI (overall index)",
+			"..,16-Aug,August 2016,E06000030,Swindon,cpi1dim1A0,CPI (overall index)",
+			"2.5,16-Aug,August 2016,W06000015,Cardiff,cpi1dim1A0,CPI (overall index)",
+			"30,16-Sep,September 2016,E06000030,Swindon,cpi1dim1A0,CPI (overall index)",
+		)
+
+		// dataset API lists the geographies in the order of their code list, which is not the order of their codes, and the
+		// periods out of chronological order
+		dimensionOptions := map[string][]dataset.Option{
+			"aggregate": {{Option: "cpi1dim1A0"}},
+			"geography": {{Option: "W06000015"}, {Option: "E06000030"}},
+			"time":      {{Option: "16-Sep"}, {Option: "16-Aug"}},
+		}
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			options := dimensionOptions[dimension]
+			return dataset.Options{Items: options, Count: len(options), TotalCount: len(options)}, nil
+		}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug,16-Sep&aggregate=cpi1dim1A0&geography=*"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		values := func(w *httptest.ResponseRecorder) []string {
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var observationsDoc models.ObservationsDoc
+			So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+
+			values := make([]string, 0, len(observationsDoc.Observations))
+			for _, o := range observationsDoc.Observations {
+				values = append(values, o.Observation)
+			}
+			return values
+		}
+
+		Convey("When no order is requested", func() {
+			w := getObservations("")
+
+			Convey("Then the observations are returned in the order of the graph database", func() {
+				So(values(w), ShouldResemble, []string{"10", "..", "2.5", "30"})
+			})
+		})
+
+		Convey("When the observations are sorted by code", func() {
+			w := getObservations("&sort=code")
+
+			Convey("Then they are sorted by the codes of the dimensions, in the order of the version dimensions", func() {
+				So(values(w), ShouldResemble, []string{"..", "30", "2.5", "10"})
+			})
+		})
+
+		Convey("When the observations are sorted by label", func() {
+			w := getObservations("&sort=Label")
+
+			Convey("Then they are sorted by the labels of the dimensions", func() {
+				So(values(w), ShouldResemble, []string{"2.5", "10", "..", "30"})
+			})
+		})
+
+		Convey("When the observations are sorted in the order of the options", func() {
+			w := getObservations("&sort=order")
+
+			Convey("Then they are sorted in the order in which dataset API lists the options, and the periods chronologically", func() {
+				So(values(w), ShouldResemble, []string{"2.5", "10", "..", "30"})
+				So(listOptionsCallsOf(dcMock, "geography"), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When more observations than can be sorted are selected", func() {
+			cfg.MaxSortedObservations = 3
+			w := getObservations("&sort=value")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorTooManySortedObservations(3).Error())
+				So(mockRowReader.CloseCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the observations are sorted by value", func() {
+			w := getObservations("&sort=value")
+
+			Convey("Then they are sorted by numeric value, with values that are not numbers last", func() {
+				So(values(w), ShouldResemble, []string{"2.5", "10", "30", ".."})
+			})
+		})
+
+		Convey("When the observations are sorted by descending value", func() {
+			w := getObservations("&sort=-value")
+
+			Convey("Then they are sorted by descending numeric value, with values that are not numbers last", func() {
+				So(values(w), ShouldResemble, []string{"30", "10", "2.5", ".."})
+			})
+		})
+
+		Convey("When a page of observations sorted by value is requested", func() {
+			w := getObservations("&sort=value&offset=1&limit=2")
+
+			Convey("Then the page is taken from the sorted observations", func() {
+				So(values(w), ShouldResemble, []string{"10", "30"})
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.TotalObservations, ShouldEqual, 4)
+				So(observationsDoc.Links.Next.URL, ShouldContainSubstring, "sort=value")
+			})
+		})
+
+		Convey("When sorted observations are requested as CSV", func() {
+			w := getObservations("&sort=-value&format=csv")
+
+			Convey("Then the rows are in the same order", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+				So(lines, ShouldHaveLength, 5)
+				So(lines[1], ShouldEndWith, ",30")
+				So(lines[4], ShouldEndWith, ",..")
+			})
+		})
+
+		Convey("When an order that is not supported is requested", func() {
+			w := getObservations("&sort=random")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidSort("random", []string{"code", "label", "value", "-value", "order"}).Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetObservationsFilteredByValue(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with observations for several geographies", t, func() {
+		mockRowReader := newRowReaderMock(
+			"v4_0,time,time,geography_code,geography,aggregate_code,aggregate",
+			"10,16-Aug,August 2016,W06000015,Cardiff,cpi1dim1A0,CPI (overall index)",
+			"..,16-Aug,August 2016,E06000030,Swindon,cpi1dim1A0,CPI (overall index)",
+			"2.5,16-Aug,August 2016,W06000022,Newport,cpi1dim1A0,CPI (overall index)",
+			"30,16-Aug,August 2016,E06000023,Bristol,cpi1dim1A0,CPI (overall index)",
+			",16-Aug,August 2016,E07000011,Huntingdonshire,cpi1dim1A0,CPI (overall index)",
+		)
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1A0&geography=*&"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		getObservationsDoc := func(w *httptest.ResponseRecorder) (models.ObservationsDoc, []string) {
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var observationsDoc models.ObservationsDoc
+			So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+
+			values := make([]string, 0, len(observationsDoc.Observations))
+			for _, o := range observationsDoc.Observations {
+				values = append(values, o.Observation)
+			}
+			return observationsDoc, values
+		}
+
+		Convey("When the observations are not filtered by value", func() {
+			observationsDoc, values := getObservationsDoc(getObservations(""))
+
+			Convey("Then every observation is returned, and no filtered observations are reported", func() {
+				So(values, ShouldResemble, []string{"10", "..", "2.5", "30", ""})
+				So(observationsDoc.FilteredObservations, ShouldBeNil)
+			})
+		})
+
+		Convey("When the observations are filtered by a minimum value", func() {
+			observationsDoc, values := getObservationsDoc(getObservations("value>=10"))
+
+			Convey("Then only the observations with a numeric value of at least the minimum are returned", func() {
+				So(values, ShouldResemble, []string{"10", "30"})
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the observations are filtered by encoded predicates on both sides of a range", func() {
+			observationsDoc, values := getObservationsDoc(getObservations("value%3E5&value%3C20"))
+
+			Convey("Then only the observations satisfying every predicate are returned", func() {
+				So(values, ShouldResemble, []string{"10"})
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When the observations without a value are filtered out", func() {
+			observationsDoc, values := getObservationsDoc(getObservations("value_not_null"))
+
+			Convey("Then the observations with a value or a marking are returned", func() {
+				So(values, ShouldResemble, []string{"10", "..", "2.5", "30"})
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the observations with the top values are requested", func() {
+			observationsDoc, values := getObservationsDoc(getObservations("top=2"))
+
+			Convey("Then the observations with the highest numeric values are returned, highest first", func() {
+				So(values, ShouldResemble, []string{"30", "10"})
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the observations with the bottom values are requested in another order", func() {
+			_, values := getObservationsDoc(getObservations("bottom=2&sort=-value"))
+
+			Convey("Then the observations with the lowest numeric values are returned in that order", func() {
+				So(values, ShouldResemble, []string{"10", "2.5"})
+			})
+		})
+
+		Convey("When a page of the observations with the top values is requested", func() {
+			observationsDoc, values := getObservationsDoc(getObservations("top=3&limit=2"))
+
+			Convey("Then the page is taken from the top observations", func() {
+				So(values, ShouldResemble, []string{"30", "10"})
+				So(observationsDoc.TotalObservations, ShouldEqual, 3)
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 2)
+				So(observationsDoc.Links.Next.URL, ShouldContainSubstring, "top=3")
+			})
+		})
+
+		Convey("When the observations to rank are more than the maximum that a query can select", func() {
+			cfg.MaxObservationCellCount = 2
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1A0&geography=K02000001,E92000001&top=1", nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			Convey("Then a bad request is returned rather than the top of some of the observations", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorTooManyRankedObservations(2).Error())
+				So(mockRowReader.CloseCalls(), ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When a predicate does not compare the value with a number", func() {
+			w := getObservations("value>=ten")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidValueFilter("value>=ten", "the value must be compared with a number").Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the number of top observations is not a positive integer", func() {
+			w := getObservations("top=0")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidValueFilter("top=0", "the number of observations must be a positive integer").Error())
+			})
+		})
+
+		Convey("When both the top and bottom observations are requested", func() {
+			w := getObservations("top=1&bottom=1")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidValueFilter("top and bottom", "only one of them can be requested").Error())
+			})
+		})
+	})
+}
+
+func TestGetObservationsAggregated(t *testing.T) {
+	Convey("Given an API with a published version of a dataset without an aggregate dimension, with observations for several geographies", t, func() {
+		rows := []string{
+			"v4_1,data_marking,time,time,geography_code,geography",
+			"10,,16-Aug,August 2016,W06000015,Cardiff",
+			"..,x,16-Aug,August 2016,E06000030,Swindon",
+			"2.5,,16-Aug,August 2016,W06000022,Newport",
+			"30,,16-Aug,August 2016,E06000023,Bristol",
+			",,16-Aug,August 2016,E07000011,Huntingdonshire",
+		}
+
+		graphDBMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				return newRowReaderMock(rows...), nil
+			},
+		}
+
+		dcMock := newDatasetClientMock(dimension2, dimension3)
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		getObservationsDoc := func(w *httptest.ResponseRecorder) models.ObservationsDoc {
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var observationsDoc models.ObservationsDoc
+			So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+			return observationsDoc
+		}
+
+		Convey("When the sum of the observations across a wildcard is requested", func() {
+			observationsDoc := getObservationsDoc(getObservations("time=16-Aug&geography=*&aggregate=sum"))
+
+			Convey("Then a single derived observation is returned without an option of the wildcard dimension", func() {
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "42.5")
+				So(observationsDoc.Observations[0].Derived, ShouldBeTrue)
+				So(observationsDoc.Observations[0].Dimensions, ShouldBeEmpty)
+				So(observationsDoc.Observations[0].Metadata, ShouldResemble, map[string]string{"data_marking": ""})
+				So(observationsDoc.TotalObservations, ShouldEqual, 1)
+			})
+
+			Convey("And the observations it was computed from and excluded from it are reported", func() {
+				So(observationsDoc.Aggregation, ShouldResemble, &models.Aggregation{
+					Aggregate:            "sum",
+					Dimensions:           []string{"geography"},
+					InputObservations:    3,
+					ExcludedObservations: 2,
+				})
+			})
+		})
+
+		Convey("When other aggregates of the observations are requested", func() {
+			for aggregate, expected := range map[string]string{
+				"mean":   "14.166666666666666",
+				"MIN":    "2.5",
+				"max":    "30",
+				"count":  "3",
+				"median": "10",
+			} {
+				observationsDoc := getObservationsDoc(getObservations("time=16-Aug&geography=*&aggregate=" + aggregate))
+
+				Convey("Then the "+aggregate+" of the numeric observations is returned", func() {
+					So(observationsDoc.Observations, ShouldHaveLength, 1)
+					So(observationsDoc.Observations[0].Observation, ShouldEqual, expected)
+					So(observationsDoc.Aggregation.Aggregate, ShouldEqual, strings.ToLower(aggregate))
+				})
+			}
+		})
+
+		Convey("When the observations filtered by value are aggregated", func() {
+			observationsDoc := getObservationsDoc(getObservations("time=16-Aug&geography=*&aggregate=median&value>=10"))
+
+			Convey("Then only the observations selected by the filter are aggregated", func() {
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "20")
+				So(observationsDoc.Aggregation.InputObservations, ShouldEqual, 2)
+				So(*observationsDoc.FilteredObservations, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the aggregate of the observations is requested as CSV", func() {
+			w := getObservations("time=16-Aug&geography=*&aggregate=max&format=csv")
+
+			Convey("Then the derived observation keeps the options of the other dimensions, and describes the aggregate in place of the wildcard option", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+				So(lines, ShouldHaveLength, 2)
+				So(lines[1], ShouldEqual, "16-Aug,August 2016,,max (derived),30,")
+			})
+		})
+
+		Convey("When the observations are more than the maximum that a query can select, without being aggregated", func() {
+			cfg.MaxObservationCellCount = 2
+			observationsDoc := getObservationsDoc(getObservations("time=16-Aug&geography=K02000001,E92000001&limit=1"))
+
+			Convey("Then the total is capped at the maximum, without a link to the last page", func() {
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeTrue)
+				So(observationsDoc.Links.Last, ShouldBeNil)
+			})
+		})
+
+		Convey("When the observations to aggregate are more than the maximum that a query can select", func() {
+			cfg.MaxObservationCellCount = 2
+			w := getObservations("time=16-Aug&geography=K02000001,E92000001&aggregate=sum")
+
+			Convey("Then a bad request is returned rather than an aggregate of some of the observations", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorTooManyAggregatedObservations(2).Error())
+				So(*graphDBMock.StreamCSVRowsCalls()[0].Limit, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When an aggregate that is not supported is requested", func() {
+			w := getObservations("time=16-Aug&geography=*&aggregate=total")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, `invalid aggregate query parameter: "total"`)
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When an aggregate is requested without a dimension to aggregate across", func() {
+			w := getObservations("time=16-Aug&geography=K02000001&aggregate=sum")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidAggregate("sum", "the observations can only be aggregated across a wildcard or a dimension with more than one option").Error())
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When an aggregate is requested in a format that cannot hold a derived observation", func() {
+			w := getObservations("time=16-Aug&geography=*&aggregate=sum&format=jsonstat")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorInvalidAggregate("sum", "the observations cannot be aggregated in the jsonstat format").Error())
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithDerivedValues(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with monthly observations for several geographies", t, func() {
+		rows := []string{
+			"v4_0,time,time,geography_code,geography",
+			"100,16-Jun,June 2016,K02000001,United Kingdom",
+			"110,16-Jul,July 2016,K02000001,United Kingdom",
+			"..,16-Aug,August 2016,K02000001,United Kingdom",
+			"121,16-Sep,September 2016,K02000001,United Kingdom",
+			"0,16-Jun,June 2016,E92000001,England",
+			"50,16-Jul,July 2016,E92000001,England",
+			"60,16-Sep,September 2016,E92000001,England",
+		}
+
+		graphDBMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				return newRowReaderMock(rows...), nil
+			},
+		}
+
+		dcMock := newDatasetClientMock(dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if dimension != "time" {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+
+			// the periods are not listed in chronological order
+			items := []dataset.Option{{Option: "16-Sep"}, {Option: "16-Jun"}, {Option: "16-Aug"}, {Option: "16-Jul"}}
+			return dataset.Options{Items: items, Count: len(items), Limit: q.Limit, TotalCount: len(items)}, nil
+		}
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?geography=K02000001,E92000001&"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		// getDerivedValues returns the derived value of each observation, or its status if it has no value
+		getDerivedValues := func(w *httptest.ResponseRecorder) []string {
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var observationsDoc models.ObservationsDoc
+			So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+
+			values := make([]string, 0, len(observationsDoc.Observations))
+			for _, o := range observationsDoc.Observations {
+				So(o.DerivedValue, ShouldNotBeNil)
+				if o.DerivedValue.Value == nil {
+					values = append(values, o.Observation+":"+o.DerivedValue.Status)
+				} else {
+					values = append(values, o.Observation+":"+strconv.FormatFloat(*o.DerivedValue.Value, 'f', 2, 64))
+				}
+			}
+			return values
+		}
+
+		Convey("When the change from the previous period is requested", func() {
+			values := getDerivedValues(getObservations("time=*&derive=change"))
+
+			Convey("Then each observation carries its change from the previous period of its series, or why it has none", func() {
+				So(values, ShouldResemble, []string{
+					"100:missing_predecessor",
+					"110:10.00",
+					"..:no_value",
+					"121:suppressed_predecessor",
+					"0:missing_predecessor",
+					"50:50.00",
+					"60:missing_predecessor",
+				})
+			})
+		})
+
+		Convey("When the percentage change from the previous period is requested", func() {
+			values := getDerivedValues(getObservations("time=*&derive=pct_change"))
+
+			Convey("Then a percentage change from zero has no value", func() {
+				So(values, ShouldResemble, []string{
+					"100:missing_predecessor",
+					"110:10.00",
+					"..:no_value",
+					"121:suppressed_predecessor",
+					"0:missing_predecessor",
+					"50:zero_predecessor",
+					"60:missing_predecessor",
+				})
+			})
+		})
+
+		Convey("When an index relative to a base period is requested", func() {
+			values := getDerivedValues(getObservations("time=*&derive=index&base=16-Jul"))
+
+			Convey("Then each observation is indexed on the observation of the base period of its series", func() {
+				So(values, ShouldResemble, []string{
+					"100:90.91",
+					"110:100.00",
+					"..:no_value",
+					"121:110.00",
+					"0:0.00",
+					"50:100.00",
+					"60:120.00",
+				})
+			})
+		})
+
+		Convey("When an index relative to a base period whose observation is zero is requested", func() {
+			values := getDerivedValues(getObservations("time=*&derive=index&base=16-Jun"))
+
+			Convey("Then the observations of that series have no index", func() {
+				So(values[4:], ShouldResemble, []string{"0:zero_base", "50:zero_base", "60:zero_base"})
+			})
+		})
+
+		Convey("When derived values are requested for sorted observations filtered by value", func() {
+			values := getDerivedValues(getObservations("time=*&derive=change&value>=100&sort=-value"))
+
+			Convey("Then the derived values follow their observations, and are calculated from the observations that were filtered out", func() {
+				So(values, ShouldResemble, []string{"121:suppressed_predecessor", "110:10.00", "100:missing_predecessor"})
+			})
+		})
+
+		Convey("When derived values are requested as CSV", func() {
+			w := getObservations("time=*&derive=change&format=csv&limit=2")
+
+			Convey("Then the derived value and its status follow the observation columns", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(strings.Split(strings.TrimSpace(w.Body.String()), "\n"), ShouldResemble, []string{
+					"time_code,time,geography_code,geography,observation,change,change_status",
+					"16-Jun,June 2016,K02000001,United Kingdom,100,,missing_predecessor",
+					"16-Jul,July 2016,K02000001,United Kingdom,110,10,",
+				})
+			})
+		})
+
+		Convey("When the metadata of derived values as CSV is requested", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations/metadata.json?geography=K02000001,E92000001&time=*&derive=change", nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			Convey("Then the derived value and its status are described after the observation columns, without querying the observations", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+
+				var metadata models.CSVWMetadata
+				So(json.Unmarshal(w.Body.Bytes(), &metadata), ShouldBeNil)
+				So(metadata.TableSchema.Columns, ShouldHaveLength, 7)
+				So(metadata.TableSchema.Columns[4].Name, ShouldEqual, "observation")
+				So(metadata.TableSchema.Columns[5], ShouldResemble, &models.CSVWColumn{
+					Name:     "change",
+					Titles:   "change",
+					Datatype: "number",
+					Null:     []string{""},
+				})
+				So(metadata.TableSchema.Columns[6], ShouldResemble, &models.CSVWColumn{
+					Name:     "change_status",
+					Titles:   "change_status",
+					Datatype: "string",
+				})
+			})
+		})
+
+		Convey("When no derivation is requested", func() {
+			w := getObservations("time=*")
+
+			Convey("Then the observations do not carry a derived value", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldNotContainSubstring, "derived_value")
+			})
+		})
+
+		Convey("When the observations to derive values from are more than the maximum that a query can select", func() {
+			rows = append(rows, "70,16-Aug,August 2016,E92000001,England", "80,16-Oct,October 2016,E92000001,England")
+			cfg.MaxObservationCellCount = 8
+			w := getObservations("time=*&derive=change")
+
+			Convey("Then a bad request is returned rather than values derived from cut off series", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorTooManyDerivedObservations(8).Error())
+			})
+		})
+
+		Convey("When a derivation cannot be calculated", func() {
+			for query, expected := range map[string]error{
+				"time=*&derive=growth":                  errs.ErrorInvalidDerivation("growth", "the derivation must be one of: [change pct_change index]"),
+				"time=*&derive=index":                   errs.ErrorInvalidDerivation("index", "a base period must be provided with the base query parameter"),
+				"time=*&base=16-Jun":                    errs.ErrorInvalidDerivation("base=16-Jun", "a base period can only be provided with derive=index"),
+				"time=*&derive=change&base=16-Jun":      errs.ErrorInvalidDerivation("base=16-Jun", "a base period can only be provided with derive=index"),
+				"time=*&derive=index&base=15-Jan":       errs.ErrorInvalidDerivation("base=15-Jan", "the base period is not an option of the time dimension"),
+				"time=16-Aug&derive=change":             errs.ErrorInvalidDerivation("change", "derived values can only be calculated when the time dimension is a wildcard"),
+				"time=*&derive=change&format=sdmx-json": errs.ErrorInvalidDerivation("change", "derived values cannot be calculated in the sdmx-json format"),
+				"time=*&derive=change&aggregate=sum":    errs.ErrorInvalidDerivation("change", "derived values cannot be calculated for an aggregated observation"),
+			} {
+				w := getObservations(query)
+
+				Convey("Then a bad request is returned for "+query, func() {
+					So(w.Code, ShouldEqual, http.StatusBadRequest)
+					So(w.Body.String(), ShouldContainSubstring, expected.Error())
+					So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+				})
+			}
+		})
+	})
+}
+
+func TestGetCensusObservationsFromCantabular(t *testing.T) {
+	Convey("Given an API with a published version of a census dataset, backed by a fake Cantabular GraphQL server", t, func() {
+		type graphQLRequest struct {
+			Query     string `json:"query"`
+			Variables struct {
+				Dataset   string              `json:"dataset"`
+				Variables []string            `json:"variables"`
+				Filters   []cantabular.Filter `json:"filters"`
+			} `json:"variables"`
+		}
+
+		var graphQLRequests []graphQLRequest
+		tableResponse := `{"data":{"dataset":{"table":{` +
+			`"dimensions":[` +
+			`{"count":2,"variable":{"name":"ltla","label":"Lower tier local authority"},"categories":[{"code":"E06000001","label":"Hartlepool"},{"code":"E06000002","label":"Middlesbrough, Redcar"}]},` +
+			`{"count":1,"variable":{"name":"sex","label":"Sex"},"categories":[{"code":"1","label":"Female"}]}` +
+			`],"values":[46813,71340],"error":null}}}}`
+
+		cantabularServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// assertions cannot be made from the goroutine of the server, so requests that cannot be decoded fail the query
+			var req graphQLRequest
+			if r.URL.Path != "/graphql" || json.NewDecoder(r.Body).Decode(&req) != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			graphQLRequests = append(graphQLRequests, req)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(tableResponse))
+		}))
+		defer cantabularServer.Close()
+
+		cantabularClient := cantabular.NewClient(cantabular.Config{ExtApiHost: cantabularServer.URL}, dphttp.NewClient(), nil)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: []dataset.VersionDimension{
+				{ID: "ltla", Name: "ltla", URL: "http://localhost:8081/code-lists/ltla"},
+				{ID: "sex", Name: "sex", URL: "http://localhost:8081/code-lists/sex"},
+			},
+			IsBasedOn: &dataset.IsBasedOn{ID: "UR", Type: "cantabular_flexible_table"},
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{Type: "cantabular_flexible_table"})
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			codes := map[string][]string{"ltla": {"E06000001", "E06000002"}, "sex": {"1", "2"}}[dimension]
+			items := make([]dataset.Option, 0, len(codes))
+			for _, code := range codes {
+				items = append(items, dataset.Option{DimensionID: dimension, Option: code})
+			}
+			return dataset.Options{Items: items, Count: len(items), Limit: q.Limit, TotalCount: len(items)}, nil
+		}
+
+		graphDBMock := &storeMock.GraphMock{}
+
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cantabularClient, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/TS008/editions/2021/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("When the observations of a wildcard are requested", func() {
+			w := getObservations("ltla=*&sex=1")
+
+			Convey("Then the selection is queried as a table of the dataset the version is based on, filtered by the selected options", func() {
+				So(graphQLRequests, ShouldHaveLength, 1)
+				So(graphQLRequests[0].Query, ShouldEqual, cantabular.QueryStaticDataset)
+				So(graphQLRequests[0].Variables.Dataset, ShouldEqual, "UR")
+				So(graphQLRequests[0].Variables.Variables, ShouldResemble, []string{"ltla", "sex"})
+				So(graphQLRequests[0].Variables.Filters, ShouldResemble, []cantabular.Filter{{Variable: "sex", Codes: []string{"1"}}})
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
+
+			Convey("And the cells of the table are returned as observations", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.Dimensions["sex"].LinkObject.ID, ShouldEqual, "1")
+				So(observationsDoc.Observations, ShouldResemble, []models.Observation{
+					{
+						Observation: "46813",
+						Dimensions: map[string]*models.DimensionObject{
+							"ltla": {ID: "E06000001", HRef: "http://localhost:8081/code-lists/ltla/codes/E06000001", Label: "Hartlepool"},
+						},
+					},
+					{
+						Observation: "71340",
+						Dimensions: map[string]*models.DimensionObject{
+							"ltla": {ID: "E06000002", HRef: "http://localhost:8081/code-lists/ltla/codes/E06000002", Label: "Middlesbrough, Redcar"},
+						},
+					},
+				})
+			})
+		})
+
+		Convey("When the observations are requested as sorted CSV", func() {
+			w := getObservations("ltla=*&sex=1&format=csv&sort=-value")
+
+			Convey("Then the table is rendered like the observations of the graph database", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "ltla_code,ltla,sex_code,sex,observation\n"+
+					`E06000002,"Middlesbrough, Redcar",1,Female,71340`+"\n"+
+					"E06000001,Hartlepool,1,Female,46813\n")
+			})
+		})
+
+		Convey("When Cantabular cannot return a table for the selected options", func() {
+			tableResponse = `{"data":{"dataset":{"table":{"dimensions":null,"values":null,"error":"withinMaxCells"}}}}`
+			w := getObservations("ltla=*&sex=*")
+
+			Convey("Then a bad request is returned with the reason", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrorCantabularTable("resulting dataset too large").Error())
+			})
+		})
+
+		Convey("When the Cantabular query fails", func() {
+			tableResponse = `{"data":null,"errors":[{"message":"dataset not loaded in this server"}]}`
+			w := getObservations("ltla=*&sex=1")
+
+			Convey("Then an internal server error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(w.Body.String(), ShouldContainSubstring, errs.ErrInternalServer.Error())
+			})
+		})
+
+		Convey("When the version is not based on a Cantabular dataset", func() {
+			dcMock.GetVersionFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
+				return dataset.Version{
+					Dimensions: []dataset.VersionDimension{{ID: "ltla", Name: "ltla"}, {ID: "sex", Name: "sex"}},
+					State:      dataset.StatePublished.String(),
+				}, nil
+			}
+			w := getObservations("ltla=*&sex=1")
+
+			Convey("Then an internal server error is returned without querying Cantabular", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(graphQLRequests, ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetObservationsFromMemoryStore(t *testing.T) {
+	Convey("Given an API whose observations are held by an in-memory store loaded with the V4 file of a version", t, func() {
+		memoryStore := store.NewMemoryStore()
+		So(memoryStore.Load("instance-1", strings.NewReader(
+			"v4_1,data_marking,time,time,uk-only,geography\n"+
+				"146.3,,16-Aug,August 2016,K02000001,United Kingdom\n"+
+				"112.1,p,16-Aug,August 2016,E92000001,England\n",
+		)), ShouldBeNil)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			ID:         "instance-1",
+			Dimensions: []dataset.VersionDimension{dimension2, dimension3},
+		})
+
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+
+		ap := GetAPIWithStore(cfg, memoryStore, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("When the observations of a wildcard are requested", func() {
+			w := getObservations("time=16-Aug&geography=*")
+
+			Convey("Then the observations of every option of the wildcard are read from the in-memory store", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 2)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "146.3")
+				So(observationsDoc.Observations[0].Dimensions["geography"].ID, ShouldEqual, "K02000001")
+				So(observationsDoc.Observations[1].Observation, ShouldEqual, "112.1")
+				So(observationsDoc.Observations[1].Metadata, ShouldResemble, map[string]string{"data_marking": "p"})
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeFalse)
+			})
+		})
+
+		Convey("When a page that is followed by more observations is requested", func() {
+			w := getObservations("time=16-Aug&geography=*&limit=1")
+
+			Convey("Then the observations after the page are counted in the total", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeFalse)
+				So(observationsDoc.Links.Next.URL, ShouldEndWith, "&offset=1&limit=1")
+				So(observationsDoc.Links.Last.URL, ShouldEndWith, "&offset=1&limit=1")
+			})
+		})
+
+		Convey("When the observations are as many as the maximum that a query can select", func() {
+			cfg.MaxObservationCellCount = 2
+			w := getObservations("time=16-Aug&geography=K02000001,E92000001&limit=1")
+
+			Convey("Then the total is not capped", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeFalse)
+				So(observationsDoc.Links.Last.URL, ShouldEndWith, "&offset=1&limit=1")
+			})
+		})
+
+		Convey("When the last page of the observations is requested", func() {
+			w := getObservations("time=16-Aug&geography=*&offset=1&limit=1")
+
+			Convey("Then the total is exact", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeFalse)
+				So(observationsDoc.Links.Next, ShouldBeNil)
+				So(observationsDoc.Links.Last.URL, ShouldEndWith, "&offset=1&limit=1")
+			})
+		})
+
+		Convey("When the observations sorted by value are requested a page at a time", func() {
+			w := getObservations("time=16-Aug&geography=*&limit=1&sort=value")
+
+			Convey("Then every observation is read to be sorted, so the total is exact", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "112.1")
+				So(observationsDoc.TotalObservations, ShouldEqual, 2)
+				So(observationsDoc.TotalCapped, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the observations are requested as CSV", func() {
+			w := getObservations("time=16-Aug&geography=E92000001&format=csv")
+
+			Convey("Then the columns of the V4 file are rendered", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "time_code,time,geography_code,geography,observation,data_marking\n"+
+					"16-Aug,August 2016,E92000001,England,112.1,p\n")
+			})
+		})
 
-		graphDBMock := &mock.IGraphMock{
-			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-				return mockRowReader, nil
-			},
+		Convey("When the selected options have no observations in the in-memory store", func() {
+			w := getObservations("time=16-Aug&geography=W92000004")
+
+			Convey("Then a 404 not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(strings.TrimSpace(w.Body.String()), ShouldEqual, errs.ErrObservationsNotFound.Error())
+			})
+		})
+	})
+}
+
+func TestGetObservationsWithPageParameterDimensions(t *testing.T) {
+	Convey("Given an API with a published version of a dataset with a dimension named limit", t, func() {
+		memoryStore := store.NewMemoryStore()
+		So(memoryStore.Load("instance-1", strings.NewReader(
+			"v4_0,time,time,limit-codes,limit\n"+
+				"10,16-Aug,August 2016,L1,Lower\n"+
+				"20,16-Aug,August 2016,L2,Upper\n",
+		)), ShouldBeNil)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			ID:         "instance-1",
+			Dimensions: []dataset.VersionDimension{{Name: "limit"}, dimension3},
+		})
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if dimension != "limit" {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+
+			items := []dataset.Option{{Option: "L1", Label: "Lower"}, {Option: "L2", Label: "Upper"}}
+			return dataset.Options{Items: items, Count: len(items), TotalCount: len(items)}, nil
 		}
 
-		cMock := &mock.CantabularClientMock{}
+		defer disableSortFilter()()
+
+		cfg := newTestConfig()
+
+		ap := GetAPIWithStore(cfg, memoryStore, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+
+		Convey("When the observations of an option of the dimension are requested", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&limit=L2&offset=0", nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			Convey("Then the page links keep the option of the dimension, and only replace the offset", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "20")
+				So(observationsDoc.Links.First.URL, ShouldEqual, "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&limit=L2&offset=0")
+			})
+		})
+	})
+}
 
+func TestGetObservationsRoutedToBackends(t *testing.T) {
+	Convey("Given an API routing the observations of datasets to an in-memory store by their ID or type, and to the graph database otherwise", t, func() {
+		memoryStore := store.NewMemoryStore()
+		So(memoryStore.Load("instance-1", strings.NewReader(
+			"v4_1,data_marking,time,time,uk-only,geography\n"+
+				"146.3,,16-Aug,August 2016,K02000001,United Kingdom\n",
+		)), ShouldBeNil)
+
+		// the graph database is not expected to be queried, so calls to the mock fail
+		registry, err := store.NewRegistry(store.GraphBackend,
+			map[string]store.ObservationStore{
+				store.GraphBackend:  store.NewGraphStore(&storeMock.GraphMock{}),
+				store.MemoryBackend: memoryStore,
+			},
+			map[string]string{"census-2021": store.MemoryBackend},
+			map[string]string{"filterable": store.MemoryBackend},
+		)
+		So(err, ShouldBeNil)
+
+		datasetTypes := map[string]string{"cpih012": "filterable", "census-2021": "cantabular_flexible_table"}
 		dcMock := &mock.IDatasetClientMock{
 			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{
-					State:      dataset.StatePublished.String(),
-					UsageNotes: usagesNotes,
-				}, nil
+				return dataset.DatasetDetails{State: dataset.StatePublished.String(), Type: datasetTypes[datasetID]}, nil
 			},
 			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
 				return dataset.Version{
-					Dimensions: dimensions,
-					Links: dataset.Links{
-						Dataset: dataset.Link{ID: "cpih012"},
-						Edition: dataset.Link{ID: "2017"},
-						Version: dataset.Link{
-							URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
-							ID:  "1",
-						},
-					},
-					State: dataset.StatePublished.String(),
+					ID:         "instance-1",
+					Dimensions: []dataset.VersionDimension{dimension2, dimension3},
+					State:      dataset.StatePublished.String(),
 				}, nil
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
-
-		cfg, err := config.Get()
-		So(err, ShouldBeNil)
+		defer disableSortFilter()()
 
-		cfg.ObservationAPIURL = observationAPIMockURL
-		cfg.DatasetAPIURL = datasetAPIMockURL
-		enableURLRewriting := false
+		cfg := newTestConfig()
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		cantabularMock := &mock.CantabularClientMock{}
+		ap := GetAPIWithRegistry(cfg, registry, dcMock, cantabularMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
 
-		Convey("When request contains query parameters where the dimension name is in lower casing", func() {
-			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
-			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		getObservations := func(datasetID string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/"+datasetID+"/editions/2017/versions/1/observations?time=16-Aug&geography=K02000001", nil)
 			w := httptest.NewRecorder()
 			ap.Router.ServeHTTP(w, r)
+			return w
+		}
 
-			So(w.Code, ShouldEqual, http.StatusOK)
-			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithSingleObservation"))
+		Convey("When the observations of a dataset routed by its type are requested", func() {
+			w := getObservations("cpih012")
 
-			validateGetDataset(dcMock, "cpih012")
-			validateGetVersion(dcMock, "cpih012", "2017", "1")
-			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
-			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+			Convey("Then they are read from the in-memory store instead of the graph database", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "146.3")
+			})
 		})
 
-		Convey("When request contains query parameters where the dimension name is in upper casing", func() {
-			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&AggregaTe=cpi1dim1S40403&GEOGRAPHY=K02000001", http.NoBody)
-			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
-			w := httptest.NewRecorder()
-			ap.Router.ServeHTTP(w, r)
+		Convey("When the observations of a census dataset routed by its ID are requested", func() {
+			w := getObservations("census-2021")
 
-			So(w.Code, ShouldEqual, http.StatusOK)
-			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedSecondDocWithSingleObservation"))
+			Convey("Then they are read from the in-memory store instead of Cantabular", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(cantabularMock.StaticDatasetQueryCalls(), ShouldBeEmpty)
 
-			validateGetDataset(dcMock, "cpih012")
-			validateGetVersion(dcMock, "cpih012", "2017", "1")
-			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
-			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+			})
 		})
 	})
+}
 
-	Convey("A successful request to get multiple observations via a wildcard for a version of a dataset returns 200 OK response", t, func() {
-		r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
-		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
-		w := httptest.NewRecorder()
+func TestPutInstanceObservations(t *testing.T) {
+	Convey("Given an API in dev mode whose observations are held by an empty in-memory store", t, func() {
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			ID:         "instance-1",
+			Dimensions: []dataset.VersionDimension{dimension2, dimension3},
+		})
 
-		dimensions := []dataset.VersionDimension{
-			{
-				Name: "aggregate",
-				URL:  "http://localhost:8081/code-lists/cpih1dim1aggid",
-			},
-			{
-				Name: "geography",
-				URL:  "http://localhost:8081/code-lists/uk-only",
-			},
-			{
-				Name: "time",
-				URL:  "http://localhost:8081/code-lists/time",
-			},
-		}
-		usagesNotes := &[]dataset.UsageNote{{Title: "data_marking", Note: "this marks the observation with a special character"}}
+		defer disableSortFilter()()
 
-		count := 0
-		mockRowReader := &observationtest.StreamRowReaderMock{
-			ReadFunc: func() (string, error) {
-				count++
-				if count == 1 {
-					return aggregateObservationResponse, nil
-				} else if count == 2 {
-					return foodObservationResponse, nil
-				} else if count == 3 {
-					return "112.1,,,Month,Aug-16,K02000001,,cpi1dim1G10101,01.2 Waste", nil
-				}
-				return "", io.EOF
-			},
-			CloseFunc: func(context.Context) error {
-				return nil
-			},
+		cfg := newTestConfig()
+		cfg.EnableDevMode = true
+		cfg.EnablePrivateEndpoints = true
+
+		ap := GetAPIWithStore(cfg, store.NewMemoryStore(), dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+
+		putObservations := func(body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("PUT", "http://localhost:8080/instances/instance-1/observations", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
 		}
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{
-					State:      dataset.StatePublished.String(),
-					UsageNotes: usagesNotes,
-				}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: dimensions,
-					Links: dataset.Links{
-						Dataset: dataset.Link{ID: "cpih012"},
-						Edition: dataset.Link{ID: "2017"},
-						Version: dataset.Link{
-							URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
-							ID:  "1",
-						},
-					},
-					State: dataset.StatePublished.String(),
-				}, nil
-			},
+		getObservations := func(query string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?"+query, nil)
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+			return w
 		}
 
-		cMock := &mock.CantabularClientMock{}
+		Convey("When the V4 file of the instance of a version is uploaded", func() {
+			w := putObservations("v4_1,data_marking,time,time,uk-only,geography\n" +
+				"146.3,,16-Aug,August 2016,K02000001,United Kingdom\n" +
+				"112.1,p,16-Aug,August 2016,E92000001,England\n")
 
-		graphDBMock := &mock.IGraphMock{
-			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-				return mockRowReader, nil
-			},
-		}
+			Convey("Then a 204 no content is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
+			Convey("Then the observations of the version are read from the imported file", func() {
+				w := getObservations("time=16-Aug&geography=*")
+				So(w.Code, ShouldEqual, http.StatusOK)
 
-		cfg, err := config.Get()
-		So(err, ShouldBeNil)
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 2)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "146.3")
+				So(observationsDoc.Observations[1].Metadata, ShouldResemble, map[string]string{"data_marking": "p"})
+			})
 
-		cfg.ObservationAPIURL = observationAPIMockURL
-		cfg.DatasetAPIURL = datasetAPIMockURL
-		enableURLRewriting := false
+			Convey("Then a later upload replaces the observations of the instance", func() {
+				So(putObservations("v4_0,time,time,uk-only,geography\n150.0,16-Aug,August 2016,K02000001,United Kingdom\n").Code, ShouldEqual, http.StatusNoContent)
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
-		ap.Router.ServeHTTP(w, r)
+				w := getObservations("time=16-Aug&geography=*")
+				So(w.Code, ShouldEqual, http.StatusOK)
 
-		So(w.Code, ShouldEqual, http.StatusOK)
-		So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithMultipleObservations"))
+				var observationsDoc models.ObservationsDoc
+				So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+				So(observationsDoc.Observations, ShouldHaveLength, 1)
+				So(observationsDoc.Observations[0].Observation, ShouldEqual, "150.0")
+			})
+		})
 
-		validateGetDataset(dcMock, "cpih012")
-		validateGetVersion(dcMock, "cpih012", "2017", "1")
-		So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
-		So(len(mockRowReader.ReadCalls()), ShouldEqual, 4)
-	})
+		Convey("When a file without a V4 header row is uploaded", func() {
+			w := putObservations("observation,time\n146.3,16-Aug\n")
 
-	Convey("Given a request to get a single observation for a version of a dataset with rewriting enabled returns 200 OK response", t, func() {
-		dimensions := []dataset.VersionDimension{
-			{
-				Name: "aggregate",
-				URL:  "http://localhost:8081/code-lists/cpih1dim1aggid",
-			},
-			{
-				Name: "geography",
-				URL:  "http://localhost:8081/code-lists/uk-only",
-			},
-			{
-				Name: "time",
-				URL:  "http://localhost:8081/code-lists/time",
-			},
-		}
-		usagesNotes := &[]dataset.UsageNote{{Title: "data_marking", Note: "this marks the observation with a special character"}}
+			Convey("Then a 400 bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(strings.TrimSpace(w.Body.String()), ShouldEqual, errs.ErrorInvalidV4File(store.ErrInvalidV4Header.Error()).Error())
+			})
+		})
 
-		count := 0
-		mockRowReader := &observationtest.StreamRowReaderMock{
-			ReadFunc: func() (string, error) {
-				count++
-				if count == 1 {
-					return aggregateObservationResponse, nil
-				} else if count == 2 {
-					return foodObservationResponse, nil
-				}
-				return "", io.EOF
-			},
-			CloseFunc: func(context.Context) error {
-				return nil
-			},
-		}
+		Convey("When a V4 file with a row missing some of its columns is uploaded", func() {
+			w := putObservations("v4_0,time,time\n146.3,16-Aug\n")
 
-		graphDBMock := &mock.IGraphMock{
-			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-				return mockRowReader, nil
-			},
-		}
+			Convey("Then a 400 bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(strings.TrimSpace(w.Body.String()), ShouldEqual, errs.ErrorInvalidV4File(store.ErrInvalidV4Row.Error()).Error())
+			})
+		})
 
-		cMock := &mock.CantabularClientMock{}
+		Convey("When a file that is not valid CSV is uploaded", func() {
+			w := putObservations("v4_0,time,time\n146.3,\"16-Aug,August 2016\n")
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{
-					State:      dataset.StatePublished.String(),
-					UsageNotes: usagesNotes,
-				}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: dimensions,
-					Links: dataset.Links{
-						Dataset: dataset.Link{ID: "cpih012"},
-						Edition: dataset.Link{ID: "2017"},
-						Version: dataset.Link{
-							URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
-							ID:  "1",
-						},
-					},
-					State: dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+			Convey("Then a 400 bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldStartWith, "invalid V4 file: ")
+			})
+		})
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
+		Convey("When a V4 file larger than the maximum import size is uploaded", func() {
+			cfg.MaxImportSize = 64
+			w := putObservations("v4_1,data_marking,time,time,uk-only,geography\n" +
+				"146.3,,16-Aug,August 2016,K02000001,United Kingdom\n" +
+				"112.1,p,16-Aug,August 2016,E92000001,England\n")
 
-		cfg, err := config.Get()
-		So(err, ShouldBeNil)
+			Convey("Then a 413 request entity too large is returned, without importing the file", func() {
+				So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+				So(strings.TrimSpace(w.Body.String()), ShouldEqual, errs.ErrV4FileTooLarge.Error())
+				So(getObservations("time=16-Aug&geography=*").Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
 
-		cfg.ObservationAPIURL = observationAPIMockURL
-		cfg.DatasetAPIURL = datasetAPIMockURL
-		enableURLRewriting := true
+func TestGetObservationsMetadata(t *testing.T) {
+	Convey("Given an API with a published version of a dataset", t, func() {
+		dimensions := append([]dataset.VersionDimension(nil), codeListDimensions...)
+		dimensions[0].Description = "Special aggregations of goods and services"
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: dimensions,
+			CSVHeader:  []string{"V4_2", "data_marking", "confidence_interval", "time", "time", "geography_code", "geography", "aggregate_code", "aggregate"},
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{
+			Title:         "CPIH",
+			UnitOfMeasure: "Index: 2015=100",
+			UsageNotes:    dataMarkingUsageNotes,
+		})
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		// the observations are not queried to describe them
+		graphDBMock := &storeMock.GraphMock{}
 
-		Convey("When request contains query parameters where the dimension name is in lower casing", func() {
-			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
+		cfg := newTestConfig()
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, hierarchyClientWithoutHierarchies(), &auth.NopHandler{}, enableURLRewriting)
+
+		Convey("When the metadata of the CSV observations is requested", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations/metadata.json?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
 			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
-			r.Header.Add("X-Forwarded-Proto", "https")
-			r.Header.Add("X-Forwarded-Host", "api.example.com")
-			r.Header.Add("X-Forwarded-Path-Prefix", "v1")
 			w := httptest.NewRecorder()
 			ap.Router.ServeHTTP(w, r)
 
-			So(w.Code, ShouldEqual, http.StatusOK)
-			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedDocWithSingleObservationAndRewriting"))
-
-			validateGetDataset(dcMock, "cpih012")
-			validateGetVersion(dcMock, "cpih012", "2017", "1")
-			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
-			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+			Convey("Then the CSV on the Web metadata document describes the header of the V4 file of the version", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/csvm+json")
+
+				var metadata models.CSVWMetadata
+				So(json.Unmarshal(w.Body.Bytes(), &metadata), ShouldBeNil)
+				So(metadata.Context, ShouldEqual, "http://www.w3.org/ns/csvw")
+				So(metadata.URL, ShouldEqual, "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&format=csv")
+				So(metadata.Title, ShouldEqual, "CPIH")
+				So(metadata.Comment, ShouldResemble, []string{"data_marking: this marks the observation with a special character"})
+				So(metadata.TableSchema.Columns, ShouldHaveLength, 9)
+				So(metadata.TableSchema.Columns[4], ShouldResemble, &models.CSVWColumn{
+					Name:        "aggregate_code",
+					Titles:      "aggregate_code",
+					Datatype:    "string",
+					Required:    true,
+					PropertyURL: "http://localhost:8081/code-lists/cpih1dim1aggid",
+					ValueURL:    "http://localhost:8081/code-lists/cpih1dim1aggid/codes/{aggregate_code}",
+				})
+				So(metadata.TableSchema.Columns[5], ShouldResemble, &models.CSVWColumn{
+					Name:        "aggregate",
+					Titles:      "aggregate",
+					Description: "Special aggregations of goods and services",
+					Datatype:    "string",
+				})
+				So(metadata.TableSchema.Columns[6], ShouldResemble, &models.CSVWColumn{
+					Name:        "observation",
+					Titles:      "observation",
+					Description: "Index: 2015=100",
+					Datatype:    "string",
+				})
+				So(metadata.TableSchema.Columns[7].Name, ShouldEqual, "data_marking")
+				So(metadata.TableSchema.Columns[8].Name, ShouldEqual, "confidence_interval")
+				So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+			})
 		})
 
-		Convey("When request contains query parameters where the dimension name is in upper casing", func() {
-			r := httptest.NewRequest("GET", "http://localhost:8080/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&AggregaTe=cpi1dim1S40403&GEOGRAPHY=K02000001", http.NoBody)
+		Convey("When the metadata of observations in a format other than CSV is requested", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations/metadata.json?time=16-Aug&aggregate=*&geography=K02000001&format=json", http.NoBody)
 			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
-			r.Header.Add("X-Forwarded-Proto", "https")
-			r.Header.Add("X-Forwarded-Host", "api.example.com")
-			r.Header.Add("X-Forwarded-Path-Prefix", "v1")
 			w := httptest.NewRecorder()
 			ap.Router.ServeHTTP(w, r)
 
-			So(w.Code, ShouldEqual, http.StatusOK)
-			So(w.Body.String(), ShouldContainSubstring, getTestData(ctx, "expectedSecondDocWithSingleObservationAndRewriting"))
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "invalid format query parameter")
+			})
+		})
 
-			validateGetDataset(dcMock, "cpih012")
-			validateGetVersion(dcMock, "cpih012", "2017", "1")
-			So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
-			So(len(mockRowReader.ReadCalls()), ShouldEqual, 3)
+		Convey("When the metadata is requested without selecting every dimension", func() {
+			r := httptest.NewRequest("GET", "http://localhost:8082/datasets/cpih012/editions/2017/versions/1/observations/metadata.json?time=16-Aug", http.NoBody)
+			r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+			w := httptest.NewRecorder()
+			ap.Router.ServeHTTP(w, r)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
 		})
 	})
 }
@@ -373,6 +2722,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
 				return dataset.DatasetDetails{}, errs.ErrInternalServer
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -381,7 +2731,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusInternalServerError)
@@ -399,6 +2749,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
 				return dataset.DatasetDetails{}, errs.ErrDatasetNotFound
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -407,7 +2758,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusNotFound)
@@ -428,6 +2779,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
 				return dataset.Version{}, errs.ErrVersionNotFound
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -436,7 +2788,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusNotFound)
@@ -455,6 +2807,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
 				return dataset.DatasetDetails{State: dataset.StateCreated.String()}, nil
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -463,7 +2816,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusNotFound)
@@ -484,6 +2837,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
 				return dataset.Version{State: "gobbly-gook"}, nil
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -492,7 +2846,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusNotFound)
@@ -514,6 +2868,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
 				return dataset.Version{State: "gobbly-gook"}, nil
 			},
+			GetOptionsFunc: getTestOptions,
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -532,7 +2887,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		cfg.EnablePrivateEndpoints = true
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, pMock, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, pMock, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		assertInternalServerErr(w)
@@ -554,38 +2909,19 @@ func TestGetObservationsReturnsError(t *testing.T) {
 			},
 		}
 
-		graphDBMock := &mock.IGraphMock{
-			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-				return mockRowReader, nil
-			},
-		}
+		graphDBMock := newGraphMock(mockRowReader)
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
-					State:      dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
 
 		cMock := &mock.CantabularClientMock{}
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
+		defer disableSortFilter()()
 
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		ap.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusInternalServerError)
@@ -600,16 +2936,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					State: dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+		dcMock := newDatasetClientMock()
 
 		cMock := &mock.CantabularClientMock{}
 
@@ -617,7 +2944,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusInternalServerError)
@@ -632,47 +2959,30 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		mockRowReader := &observationtest.StreamRowReaderMock{
-			ReadFunc: func() (string, error) {
-				return "v4,data_marking,confidence_interval,time,time,geography_code,geography,aggregate_code,aggregate", nil
-			},
-			CloseFunc: func(context.Context) error {
-				return nil
-			},
-		}
-
-		graphDBMock := &mock.IGraphMock{
-			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-				return mockRowReader, nil
-			},
-		}
-
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
-					CSVHeader:  []string{"v4"},
-					State:      dataset.StatePublished.String(),
-				}, nil
+			ReadFunc: func() (string, error) {
+				return "v4,data_marking,confidence_interval,time,time,geography_code,geography,aggregate_code,aggregate", nil
+			},
+			CloseFunc: func(context.Context) error {
+				return nil
 			},
 		}
 
+		graphDBMock := newGraphMock(mockRowReader)
+
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
+			CSVHeader:  []string{"v4"},
+		})
+
 		cMock := &mock.CantabularClientMock{}
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
+		defer disableSortFilter()()
 
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		ap.Router.ServeHTTP(w, r)
 
 		assertInternalServerErr(w)
@@ -686,17 +2996,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension3},
-					State:      dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+		dcMock := newDatasetClientMock(dimension1, dimension3)
 
 		cMock := &mock.CantabularClientMock{}
 
@@ -704,7 +3004,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusBadRequest)
@@ -719,17 +3019,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3, dimension4},
-					State:      dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3, dimension4)
 
 		cMock := &mock.CantabularClientMock{}
 
@@ -737,7 +3027,7 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, hierarchyClientWithoutHierarchies(), &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusBadRequest)
@@ -747,22 +3037,20 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		validateGetVersion(dcMock, "cpih012", "2017", "1")
 	})
 
-	Convey("When there are too many query parameters that are set to wildcard (*) value request returns 400 bad request", t, func() {
+	Convey("When the wildcards (*) in query parameters can select more observations than the configured maximum request returns 400 bad request", t, func() {
 		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=*&aggregate=*&geography=K02000001", http.NoBody)
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
-					CSVHeader:  []string{"v4_0", "time_code", "time", "aggregate_code", "aggregate", "geography_code", "geography"},
-					State:      dataset.StatePublished.String(),
-				}, nil
-			},
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
+			CSVHeader:  []string{"v4_0", "time_code", "time", "aggregate_code", "aggregate", "geography_code", "geography"},
+		})
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if q.Limit > 0 {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+			return dataset.Options{TotalCount: 2000}, nil
 		}
 
 		cMock := &mock.CantabularClientMock{}
@@ -771,53 +3059,66 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusBadRequest)
-		So(w.Body.String(), ShouldResemble, "only one wildcard (*) is allowed as a value in selected query parameters\n")
+		So(w.Body.String(), ShouldResemble, "the selected query parameters can return more than the maximum of 1000000 observations, select fewer options or wildcards\n")
 
 		validateGetDataset(dcMock, "cpih012")
 		validateGetVersion(dcMock, "cpih012", "2017", "1")
 	})
 
+	Convey("When a single wildcard (*) combined with dimensions of several options can select more observations than the configured maximum request returns 400 bad request", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=*&aggregate=cpi1dim1A0,cpi1dim1S40403&geography=K02000001,E92000001", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+		dcMock.GetOptionsFunc = func(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+			if q.Limit > 0 {
+				return getTestOptions(ctx, userAuthToken, serviceAuthToken, collectionID, id, edition, version, dimension, q)
+			}
+			return dataset.Options{TotalCount: 300}, nil
+		}
+
+		graphDBMock := &storeMock.GraphMock{}
+
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.MaxObservationCellCount = 1000
+		enableURLRewriting := false
+
+		api := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		api.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldResemble, "the selected query parameters can return more than the maximum of 1000 observations, select fewer options or wildcards\n")
+		So(graphDBMock.StreamCSVRowsCalls(), ShouldHaveLength, 0)
+	})
+
 	Convey("When requested query does not find a unique observation return no observations found", t, func() {
 		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001", http.NoBody)
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{State: dataset.StatePublished.String()}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: []dataset.VersionDimension{dimension1, dimension2, dimension3},
-					State:      dataset.StatePublished.String(),
-				}, nil
-			},
-		}
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
 
 		cMock := &mock.CantabularClientMock{}
 
-		graphDBMock := &mock.IGraphMock{
+		graphDBMock := &storeMock.GraphMock{
 			StreamCSVRowsFunc: func(context.Context, string, string, *observation.DimensionFilters, *int) (observation.StreamRowReader, error) {
 				return nil, errs.ErrObservationsNotFound
 			},
 		}
 
-		originalFunc := api.SortFilter
-		defer func() {
-			api.SortFilter = originalFunc
-		}()
-		api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
-		}
+		defer disableSortFilter()()
 
 		cfg, err := config.Get()
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		ap.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusNotFound)
@@ -828,47 +3129,21 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(len(graphDBMock.StreamCSVRowsCalls()), ShouldEqual, 1)
 	})
 
-	Convey("When requested query has a multi-valued dimension return bad request", t, func() {
-		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001&geography=K02000002", http.NoBody)
+	Convey("When requested query combines a wildcard with other options for a dimension return bad request", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=cpi1dim1S40403&geography=K02000001&geography=*", http.NoBody)
 		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
 		w := httptest.NewRecorder()
 
-		dimensions := []dataset.VersionDimension{
-			{
-				Name: "aggregate",
-				URL:  "http://localhost:8081/code-lists/cpih1dim1aggid",
-			},
-			{
-				Name: "geography",
-				URL:  "http://localhost:8081/code-lists/uk-only",
-			},
-			{
-				Name: "time",
-				URL:  "http://localhost:8081/code-lists/time",
-			},
-		}
-		usagesNotes := &[]dataset.UsageNote{{Title: "data_marking", Note: "this marks the observation with a special character"}}
-
-		dcMock := &mock.IDatasetClientMock{
-			GetFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
-				return dataset.DatasetDetails{
-					State:      dataset.StatePublished.String(),
-					UsageNotes: usagesNotes,
-				}, nil
-			},
-			GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
-				return dataset.Version{
-					Dimensions: dimensions,
-					Links: dataset.Links{
-						Version: dataset.Link{
-							URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
-							ID:  "1",
-						},
-					},
-					State: dataset.StatePublished.String(),
-				}, nil
+		dcMock := newDatasetClientMockOfVersion(dataset.Version{
+			Dimensions: codeListDimensions,
+			Links: dataset.Links{
+				Version: dataset.Link{
+					URL: "http://localhost:8080/datasets/cpih012/editions/2017/versions/1",
+					ID:  "1",
+				},
 			},
-		}
+		})
+		dcMock.GetFunc = getPublishedDataset(dataset.DatasetDetails{UsageNotes: dataMarkingUsageNotes})
 
 		cMock := &mock.CantabularClientMock{}
 
@@ -876,15 +3151,105 @@ func TestGetObservationsReturnsError(t *testing.T) {
 		So(err, ShouldBeNil)
 		enableURLRewriting := false
 
-		api := GetAPIWithMocks(cfg, &mock.IGraphMock{}, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		api := GetAPIWithMocks(cfg, &storeMock.GraphMock{}, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 		api.Router.ServeHTTP(w, r)
 
 		So(w.Code, ShouldEqual, http.StatusBadRequest)
-		So(w.Body.String(), ShouldResemble, "multi-valued query parameters for the following dimensions: [geography]\n")
+		So(w.Body.String(), ShouldResemble, "a wildcard (*) cannot be combined with other options for the following dimensions: [geography]\n")
 
 		validateGetDataset(dcMock, "cpih012")
 		validateGetVersion(dcMock, "cpih012", "2017", "1")
 	})
+
+	Convey("When the graph stream fails after observations have been written the document ends with an error", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001", http.NoBody)
+		r = r.WithContext(context.WithValue(r.Context(), request.FlorenceIdentityKey, testUserAuthToken))
+		w := httptest.NewRecorder()
+
+		dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+
+		cMock := &mock.CantabularClientMock{}
+
+		count := 0
+		mockRowReader := &observationtest.StreamRowReaderMock{
+			ReadFunc: func() (string, error) {
+				count++
+				switch count {
+				case 1:
+					return aggregateObservationResponse, nil
+				case 2:
+					return foodObservationResponse, nil
+				}
+				return "", errors.New("connection reset by peer")
+			},
+			CloseFunc: func(context.Context) error {
+				return nil
+			},
+		}
+
+		graphDBMock := newGraphMock(mockRowReader)
+
+		defer disableSortFilter()()
+
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		enableURLRewriting := false
+
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
+		ap.Router.ServeHTTP(w, r)
+
+		So(w.Code, ShouldEqual, http.StatusOK)
+
+		var observationsDoc models.ObservationsDoc
+		So(json.Unmarshal(w.Body.Bytes(), &observationsDoc), ShouldBeNil)
+		So(observationsDoc.Observations, ShouldHaveLength, 1)
+		So(observationsDoc.Error, ShouldEqual, errs.ErrInternalServer.Error())
+		So(observationsDoc.Links, ShouldBeNil)
+		So(w.Result().Trailer.Get("X-Stream-Error"), ShouldEqual, errs.ErrInternalServer.Error())
+		So(len(mockRowReader.CloseCalls()), ShouldEqual, 1)
+	})
+
+	for _, format := range []string{"csv", "arrow", "parquet"} {
+		Convey("When the graph stream fails after "+format+" observations have been written the failure is reported in a trailer", t, func() {
+			r := httptest.NewRequest("GET", "http://localhost:22000/datasets/cpih012/editions/2017/versions/1/observations?time=16-Aug&aggregate=*&geography=K02000001&format="+format, http.NoBody)
+			w := httptest.NewRecorder()
+
+			dcMock := newDatasetClientMock(dimension1, dimension2, dimension3)
+
+			count := 0
+			graphDBMock := &storeMock.GraphMock{
+				StreamCSVRowsFunc: func(context.Context, string, string, *observation.DimensionFilters, *int) (observation.StreamRowReader, error) {
+					return &observationtest.StreamRowReaderMock{
+						ReadFunc: func() (string, error) {
+							count++
+							switch count {
+							case 1:
+								return aggregateObservationResponse, nil
+							case 2:
+								return foodObservationResponse, nil
+							}
+							return "", errors.New("connection reset by peer")
+						},
+						CloseFunc: func(context.Context) error {
+							return nil
+						},
+					}, nil
+				},
+			}
+
+			defer disableSortFilter()()
+
+			cfg, err := config.Get()
+			So(err, ShouldBeNil)
+
+			ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, &mock.CantabularClientMock{}, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, false)
+			ap.Router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Trailer"), ShouldEqual, "X-Stream-Error")
+			So(w.Result().Trailer.Get("X-Stream-Error"), ShouldEqual, errs.ErrInternalServer.Error())
+		})
+	}
 }
 
 func TestGetListOfValidDimensionNames(t *testing.T) {
@@ -1027,9 +3392,9 @@ func TestExtractQueryParameters(t *testing.T) {
 				queryParameters, err := api.ExtractQueryParameters(r.URL.Query(), headers)
 				So(err, ShouldBeNil)
 				So(len(queryParameters), ShouldEqual, 3)
-				So(queryParameters["time"], ShouldEqual, "JAN08")
-				So(queryParameters["aggregate"], ShouldEqual, "Overall Index")
-				So(queryParameters["geography"], ShouldEqual, "wales")
+				So(queryParameters["time"], ShouldResemble, []string{"JAN08"})
+				So(queryParameters["aggregate"], ShouldResemble, []string{"Overall Index"})
+				So(queryParameters["geography"], ShouldResemble, []string{"wales"})
 			})
 		})
 
@@ -1065,7 +3430,68 @@ func TestExtractQueryParameters(t *testing.T) {
 
 		Convey("When a request is made containing all query parameters for each dimensions/headers but there is a duplicate", func() {
 			r, err := http.NewRequest("GET",
-				"http://localhost:22000/datasets/123/editions/2017/versions/1/observations?time=JAN08&aggregate=Food&geography=wales&time=JAN0",
+				"http://localhost:22000/datasets/123/editions/2017/versions/1/observations?time=JAN08&aggregate=Food&geography=wales&time=JAN09&TIME=JAN08",
+				http.NoBody,
+			)
+			So(err, ShouldBeNil)
+
+			Convey("Then extractQueryParameters func returns every distinct option for the dimension", func() {
+				queryParameters, err := api.ExtractQueryParameters(r.URL.Query(), headers)
+				So(err, ShouldBeNil)
+				So(len(queryParameters), ShouldEqual, 3)
+				So(queryParameters["time"], ShouldHaveLength, 2)
+				So(queryParameters["time"], ShouldContain, "JAN08")
+				So(queryParameters["time"], ShouldContain, "JAN09")
+				So(queryParameters["aggregate"], ShouldResemble, []string{"Food"})
+			})
+		})
+
+		Convey("When a request is made containing a comma separated list of options for a dimension", func() {
+			r, err := http.NewRequest("GET",
+				"http://localhost:22000/datasets/123/editions/2017/versions/1/observations?time=JAN08,JAN09&aggregate=Food&geography=wales,,england",
+				http.NoBody,
+			)
+			So(err, ShouldBeNil)
+
+			Convey("Then extractQueryParameters func returns each of the options in order", func() {
+				queryParameters, err := api.ExtractQueryParameters(r.URL.Query(), headers)
+				So(err, ShouldBeNil)
+				So(queryParameters["time"], ShouldResemble, []string{"JAN08", "JAN09"})
+				So(queryParameters["geography"], ShouldResemble, []string{"wales", "england"})
+			})
+		})
+
+		Convey("When a request is made containing a comma between double quotes in a list of options", func() {
+			query := url.Values{
+				"time":      {"JAN08"},
+				"aggregate": {"Food"},
+				"geography": {`label:"Bristol, City of",label:Cardiff`},
+			}
+
+			Convey("Then extractQueryParameters func does not split the quoted option", func() {
+				queryParameters, err := api.ExtractQueryParameters(query, headers)
+				So(err, ShouldBeNil)
+				So(queryParameters["geography"], ShouldResemble, []string{`label:"Bristol, City of"`, "label:Cardiff"})
+			})
+		})
+
+		Convey("When a request is made containing an option with a comma entirely between double quotes", func() {
+			query := url.Values{
+				"time":      {"JAN08"},
+				"aggregate": {"Food"},
+				"geography": {`"Bristol, City of",Cardiff`},
+			}
+
+			Convey("Then extractQueryParameters func returns the option without its quotes", func() {
+				queryParameters, err := api.ExtractQueryParameters(query, headers)
+				So(err, ShouldBeNil)
+				So(queryParameters["geography"], ShouldResemble, []string{"Bristol, City of", "Cardiff"})
+			})
+		})
+
+		Convey("When a request is made containing a wildcard and an option for the same dimension", func() {
+			r, err := http.NewRequest("GET",
+				"http://localhost:22000/datasets/123/editions/2017/versions/1/observations?time=JAN08&aggregate=*,Food&geography=wales",
 				http.NoBody,
 			)
 			So(err, ShouldBeNil)
@@ -1073,13 +3499,74 @@ func TestExtractQueryParameters(t *testing.T) {
 			Convey("Then extractQueryParameters func returns an error", func() {
 				queryParameters, err := api.ExtractQueryParameters(r.URL.Query(), headers)
 				So(err, ShouldNotBeNil)
-				So(err, ShouldResemble, errs.ErrorMultivaluedQueryParameters([]string{"time"}))
+				So(err, ShouldResemble, errs.ErrorWildcardWithOptions([]string{"aggregate"}))
 				So(queryParameters, ShouldBeNil)
 			})
 		})
 	})
 }
 
+func TestExtractPaginationParameters(t *testing.T) {
+	Convey("Given a list of valid dimension headers for version", t, func() {
+		headers := []string{
+			"time",
+			"aggregate",
+			"geography",
+		}
+
+		Convey("When a request is made without offset or limit", func() {
+			query := url.Values{"time": []string{"JAN08"}}
+
+			Convey("Then the default offset and limit are returned", func() {
+				offset, limit, err := api.ExtractPaginationParameters(query, headers, 20, 100)
+				So(err, ShouldBeNil)
+				So(offset, ShouldEqual, 0)
+				So(limit, ShouldEqual, 20)
+			})
+		})
+
+		Convey("When a request is made with a valid offset and limit", func() {
+			query := url.Values{"offset": []string{"40"}, "limit": []string{"100"}}
+
+			Convey("Then the requested offset and limit are returned", func() {
+				offset, limit, err := api.ExtractPaginationParameters(query, headers, 20, 100)
+				So(err, ShouldBeNil)
+				So(offset, ShouldEqual, 40)
+				So(limit, ShouldEqual, 100)
+			})
+		})
+
+		Convey("When a request is made with a negative offset", func() {
+			query := url.Values{"offset": []string{"-1"}}
+
+			Convey("Then an error is returned", func() {
+				_, _, err := api.ExtractPaginationParameters(query, headers, 20, 100)
+				So(err, ShouldResemble, errs.ErrorInvalidOffset("-1"))
+			})
+		})
+
+		Convey("When a request is made with a limit greater than the maximum", func() {
+			query := url.Values{"limit": []string{"101"}}
+
+			Convey("Then an error is returned", func() {
+				_, _, err := api.ExtractPaginationParameters(query, headers, 20, 100)
+				So(err, ShouldResemble, errs.ErrorInvalidLimit("101", 100))
+			})
+		})
+
+		Convey("When a version has a dimension named limit", func() {
+			query := url.Values{"limit": []string{"abc"}}
+
+			Convey("Then the query parameter is not treated as the limit", func() {
+				offset, limit, err := api.ExtractPaginationParameters(query, append(headers, "limit"), 20, 100)
+				So(err, ShouldBeNil)
+				So(offset, ShouldEqual, 0)
+				So(limit, ShouldEqual, 20)
+			})
+		})
+	})
+}
+
 func getTestData(ctx context.Context, filename string) string {
 	jsonBytes, err := os.ReadFile("./observation_test_data/" + filename + ".json")
 	if err != nil {
@@ -1095,6 +3582,153 @@ func getTestData(ctx context.Context, filename string) string {
 	return buffer.String()
 }
 
+// testOptions are the options of every dimension of the versions mocked by the observations tests
+var testOptions = []string{
+	"16-Aug",
+	"K02000001", "E92000001", "E12000007", "E12000008", "E09000001", "W92000004", "N92000002",
+	"cpi1dim1A0", "cpi1dim1S40403", "cpi1dim1G10100", "cpi1dim1G10101",
+}
+
+// getTestOptions mocks dataset API listing the test options for every dimension, which are several so that the
+// dimensions do not have a default option
+func getTestOptions(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, id string, edition string, version string, dimension string, q *dataset.QueryParams) (dataset.Options, error) {
+	items := make([]dataset.Option, 0, len(testOptions))
+	for _, option := range testOptions {
+		items = append(items, dataset.Option{DimensionID: dimension, Option: option})
+	}
+
+	return dataset.Options{
+		Items:      items,
+		Count:      len(items),
+		Limit:      q.Limit,
+		TotalCount: len(items),
+	}, nil
+}
+
+// hierarchyClientWithoutHierarchies returns a hierarchy client mock for a version whose dimensions do not have a hierarchy
+func hierarchyClientWithoutHierarchies() *mock.IHierarchyClientMock {
+	return &mock.IHierarchyClientMock{
+		GetRootFunc: func(ctx context.Context, instanceID string, name string) (hierarchy.Model, error) {
+			return hierarchy.Model{}, hierarchy.NewErrInvalidHierarchyAPIResponse(http.StatusOK, http.StatusNotFound, "/hierarchies/"+instanceID+"/"+name)
+		},
+	}
+}
+
+// newDatasetClientMock returns a dataset client mock of a published dataset, and of a published version of it with the
+// provided dimensions, whose options are the test options
+func newDatasetClientMock(dimensions ...dataset.VersionDimension) *mock.IDatasetClientMock {
+	return newDatasetClientMockOfVersion(dataset.Version{Dimensions: dimensions})
+}
+
+// newDatasetClientMockOfVersion returns a dataset client mock like newDatasetClientMock, of the provided version
+func newDatasetClientMockOfVersion(versionDoc dataset.Version) *mock.IDatasetClientMock {
+	versionDoc.State = dataset.StatePublished.String()
+	return &mock.IDatasetClientMock{
+		GetFunc: getPublishedDataset(dataset.DatasetDetails{}),
+		GetVersionFunc: func(ctx context.Context, userAuthToken string, serviceAuthToken string, downloadServiceAuthToken string, collectionID string, datasetID string, edition string, version string) (dataset.Version, error) {
+			return versionDoc, nil
+		},
+		GetOptionsFunc: getTestOptions,
+	}
+}
+
+// getPublishedDataset returns a function that mocks dataset API getting the provided details of a published dataset
+func getPublishedDataset(datasetDoc dataset.DatasetDetails) func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
+	datasetDoc.State = dataset.StatePublished.String()
+	return func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, datasetID string) (dataset.DatasetDetails, error) {
+		return datasetDoc, nil
+	}
+}
+
+// newRowReaderMock returns a row reader mock that reads the provided rows, the first of which is the header row
+func newRowReaderMock(rows ...string) *observationtest.StreamRowReaderMock {
+	read := 0
+	return &observationtest.StreamRowReaderMock{
+		ReadFunc: func() (string, error) {
+			if read == len(rows) {
+				return "", io.EOF
+			}
+			read++
+			return rows[read-1], nil
+		},
+		CloseFunc: func(context.Context) error {
+			return nil
+		},
+	}
+}
+
+// newGraphMock returns a graph database mock that streams the rows of the provided row reader
+func newGraphMock(rowReader observation.StreamRowReader) *storeMock.GraphMock {
+	return &storeMock.GraphMock{
+		StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+			return rowReader, nil
+		},
+	}
+}
+
+// newTestConfig returns the default config, with the URLs of the observation and dataset APIs used by the tests
+func newTestConfig() *config.Config {
+	cfg, err := config.Get()
+	So(err, ShouldBeNil)
+
+	cfg.ObservationAPIURL = observationAPIMockURL
+	cfg.DatasetAPIURL = datasetAPIMockURL
+	return cfg
+}
+
+// disableSortFilter replaces SortFilter with a function that leaves the filters unsorted, and returns a function that
+// restores it
+func disableSortFilter() func() {
+	originalFunc := api.SortFilter
+	api.SortFilter = func(ctx context.Context, api *api.API, event *models.FilterSubmitted, dbFilter *observation.DimensionFilters) {
+	}
+	return func() {
+		api.SortFilter = originalFunc
+	}
+}
+
+// getOptionsCallsOf returns the calls made to get the options of the provided dimension
+func getOptionsCallsOf(dcMock *mock.IDatasetClientMock, dimension string) []struct {
+	Ctx              context.Context
+	UserAuthToken    string
+	ServiceAuthToken string
+	CollectionID     string
+	ID               string
+	Edition          string
+	Version          string
+	Dimension        string
+	Q                *dataset.QueryParams
+} {
+	calls := dcMock.GetOptionsCalls()
+	dimensionCalls := calls[:0:0]
+	for _, call := range calls {
+		if call.Dimension == dimension {
+			dimensionCalls = append(dimensionCalls, call)
+		}
+	}
+	return dimensionCalls
+}
+
+// listOptionsCallsOf returns the calls made to list the options of the provided dimension, rather than only count them
+func listOptionsCallsOf(dcMock *mock.IDatasetClientMock, dimension string) []*dataset.QueryParams {
+	var calls []*dataset.QueryParams
+	for _, call := range getOptionsCallsOf(dcMock, dimension) {
+		if call.Q.Limit > 0 {
+			calls = append(calls, call.Q)
+		}
+	}
+	return calls
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func validateGetDataset(dcMock *mock.IDatasetClientMock, datasetID string) {
 	So(len(dcMock.GetCalls()), ShouldEqual, 1)
 	So(dcMock.GetCalls()[0].ServiceAuthToken, ShouldEqual, testServiceAuthToken)
@@ -1129,11 +3763,7 @@ func TestSortFilter(t *testing.T) {
 		},
 	}
 
-	graphDBMock := &mock.IGraphMock{
-		StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
-			return mockRowReader, nil
-		},
-	}
+	graphDBMock := newGraphMock(mockRowReader)
 
 	var pub = false
 
@@ -1163,7 +3793,7 @@ func TestSortFilter(t *testing.T) {
 		cfg.ObservationAPIURL = observationAPIMockURL
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 
 		Convey("When SortFilter is called", func() {
 			api.SortFilter(ctx, ap, &eventFilterSubmitted, &dbFilter)
@@ -1208,7 +3838,7 @@ func TestSortFilter(t *testing.T) {
 		cfg.ObservationAPIURL = observationAPIMockURL
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 
 		Convey("When SortFilter is called", func() {
 			api.SortFilter(ctx, ap, &eventFilterSubmitted, &dbFilter)
@@ -1269,7 +3899,7 @@ func TestSortFilter(t *testing.T) {
 		cfg.ObservationAPIURL = observationAPIMockURL
 		enableURLRewriting := false
 
-		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &auth.NopHandler{}, enableURLRewriting)
+		ap := GetAPIWithMocks(cfg, graphDBMock, dcMock, cMock, &mock.IHierarchyClientMock{}, &auth.NopHandler{}, enableURLRewriting)
 
 		Convey("When SortFilter is called", func() {
 			api.SortFilter(ctx, ap, &eventFilterSubmitted, &dbFilter)
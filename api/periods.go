@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+// periodMonths are the months of the abbreviated month names found in time codes
+var periodMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// periodPatterns are the formats of the time codes that can be ordered chronologically, matched against lower case codes.
+// A period is identified by its year, given in full or by its last two digits, and by the month or quarter it starts in.
+var periodPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<year>\d{4})$`),
+	regexp.MustCompile(`^(?P<year>\d{4})-(?P<mm>\d{2})$`),
+	regexp.MustCompile(`^(?P<yy>\d{2})-(?P<month>[a-z]{3})$`),
+	regexp.MustCompile(`^(?P<month>[a-z]{3})-(?P<yy>\d{2})$`),
+	regexp.MustCompile(`^(?P<month>[a-z]{3}) (?P<year>\d{4})$`),
+	regexp.MustCompile(`^(?P<year>\d{4}) (?P<month>[a-z]{3})$`),
+	regexp.MustCompile(`^(?P<month>[a-z]{3})-[a-z]{3} (?P<year>\d{4})$`),
+	regexp.MustCompile(`^(?P<year>\d{4})[ -]?q(?P<quarter>[1-4])$`),
+	regexp.MustCompile(`^q(?P<quarter>[1-4]) (?P<year>\d{4})$`),
+}
+
+// parsePeriod returns the number of months from year zero to the start of the period of the time code, and whether the
+// format of the time code is recognised. Years given by two digits are in the 2000s up to 49, and in the 1900s otherwise.
+func parsePeriod(code string) (int, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	for _, pattern := range periodPatterns {
+		match := pattern.FindStringSubmatch(code)
+		if match == nil {
+			continue
+		}
+
+		year, month := 0, 1
+		for i, name := range pattern.SubexpNames() {
+			switch name {
+			case "year":
+				year, _ = strconv.Atoi(match[i])
+			case "yy":
+				year, _ = strconv.Atoi(match[i])
+				if year < 50 {
+					year += 2000
+				} else {
+					year += 1900
+				}
+			case "mm":
+				month, _ = strconv.Atoi(match[i])
+			case "month":
+				month = periodMonths[match[i]]
+			case "quarter":
+				quarter, _ := strconv.Atoi(match[i])
+				month = 3*quarter - 2
+			}
+		}
+
+		if month < 1 || month > 12 {
+			return 0, false
+		}
+		return year*12 + month - 1, true
+	}
+
+	return 0, false
+}
+
+// getOrderedDimensionOptions returns the options of the dimension in the order of the dimension. Dataset API lists the
+// options in the order of their code list, which is not always chronological for the time dimension, so its options are
+// ordered by the start of their periods when every one of them is a time code in a recognised format. The options of
+// other dimensions, and of a time dimension with unrecognised time codes, are kept in the order they are listed in.
+func (api *API) getOrderedDimensionOptions(ctx context.Context, event *models.FilterSubmitted, dimension string) ([]dataset.Option, error) {
+	options, err := api.getDimensionOptions(ctx, event, dimension)
+	if err != nil || dimension != timeDimension {
+		return options, err
+	}
+
+	periods := make(map[string]int, len(options))
+	for _, option := range options {
+		period, ok := parsePeriod(option.Option)
+		if !ok {
+			return options, nil
+		}
+		periods[option.Option] = period
+	}
+
+	// the listed options are cached, so they are ordered in a copy
+	ordered := append([]dataset.Option(nil), options...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return periods[ordered[i].Option] < periods[ordered[j].Option]
+	})
+
+	return ordered, nil
+}
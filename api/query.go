@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// maxQueryBodySize is the maximum size in bytes of the body of a request to query observations
+const maxQueryBodySize = 1 << 20
+
+// postObservationsQuery queries the observations selected by the JSON body of the request, so that the query is validated
+// and responded to as a request to get observations. The dimension options of the body are selected as they are, while
+// its page, format and order are converted into the equivalent query parameters, along with the options for the links
+// of the response.
+func (api *API) postObservationsQuery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	logData := log.Data{"dataset_id": vars["dataset_id"], "edition": vars["edition"], "version": vars["version"]}
+
+	var query models.ObservationsQuery
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQueryBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&query); err != nil {
+		handleObservationsErrorType(ctx, w, errs.ErrorInvalidQueryBody(err.Error()), logData)
+		return
+	}
+
+	if err := query.Validate(); err != nil {
+		handleObservationsErrorType(ctx, w, err, logData)
+		return
+	}
+
+	queryRequest := r.Clone(ctx)
+	queryRequest.URL.RawQuery = query.QueryValues(offsetParameter, limitParameter, formatParameter, sortParameter).Encode()
+
+	api.serveObservations(w, queryRequest, query.Selections())
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	rangeSeparator  = ".."
+	rangeFromPrefix = "from:"
+	rangeToPrefix   = "to:"
+)
+
+// rangeSelector selects the options of an ordered dimension between two bounds, inclusive. A range without
+// a start begins at the first option of the dimension, and a range without an end stops at its last option.
+type rangeSelector struct {
+	start string
+	end   string
+}
+
+// parseRangeSelector parses an option selecting a range of options: start..end, from:start or to:end
+func parseRangeSelector(option string) (rangeSelector, bool) {
+	switch {
+	case strings.HasPrefix(option, rangeFromPrefix):
+		return rangeSelector{start: strings.TrimSpace(strings.TrimPrefix(option, rangeFromPrefix))}, true
+	case strings.HasPrefix(option, rangeToPrefix):
+		return rangeSelector{end: strings.TrimSpace(strings.TrimPrefix(option, rangeToPrefix))}, true
+	}
+
+	if i := strings.Index(option, rangeSeparator); i >= 0 {
+		return rangeSelector{
+			start: strings.TrimSpace(option[:i]),
+			end:   strings.TrimSpace(option[i+len(rangeSeparator):]),
+		}, true
+	}
+
+	return rangeSelector{}, false
+}
+
+// getRangeOptions returns the options of the dimension selected by a range selector, in the order of the dimension,
+// which is chronological for the periods of the time dimension. Both bounds must be options of the dimension, and the
+// start cannot come after the end. An option of the dimension that looks like a range, e.g. 2015..2016, selects itself.
+func (api *API) getRangeOptions(ctx context.Context, event *models.FilterSubmitted, dimension, option string, selector rangeSelector) ([]string, error) {
+	dimensionOptions, err := api.getOrderedDimensionOptions(ctx, event, dimension)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dimensionOption := range dimensionOptions {
+		if dimensionOption.Option == option {
+			return []string{option}, nil
+		}
+	}
+
+	if selector.start == "" && selector.end == "" {
+		return nil, errs.ErrorInvalidRange(option, "a start or an end must be provided")
+	}
+
+	start, end := 0, len(dimensionOptions)-1
+	startFound, endFound := selector.start == "", selector.end == ""
+	for i, dimensionOption := range dimensionOptions {
+		if !startFound && dimensionOption.Option == selector.start {
+			start, startFound = i, true
+		}
+		if !endFound && dimensionOption.Option == selector.end {
+			end, endFound = i, true
+		}
+	}
+
+	if !startFound {
+		return nil, errs.ErrorInvalidRange(option, "the start "+selector.start+" is not an option of the dimension")
+	}
+
+	if !endFound {
+		return nil, errs.ErrorInvalidRange(option, "the end "+selector.end+" is not an option of the dimension")
+	}
+
+	if start > end {
+		return nil, errs.ErrorInvalidRange(option, "the start comes after the end in the order of the dimension options")
+	}
+
+	options := make([]string, 0, end-start+1)
+	for _, dimensionOption := range dimensionOptions[start : end+1] {
+		options = append(options, dimensionOption.Option)
+	}
+
+	return options, nil
+}
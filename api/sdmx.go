@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+// renderSDMXJSON renders the page of observations as an SDMX-JSON data message
+func renderSDMXJSON(result *observationsResult) ([]byte, error) {
+	observations, err := result.collectObservations()
+	if err != nil {
+		return nil, err
+	}
+
+	message := models.CreateSDMXJSONMessage(result.doc, result.versionDoc, result.datasetDoc, observations, time.Now())
+	return marshalJSON(message)
+}
+
+// renderSDMXML renders the page of observations as an SDMX-ML 2.1 generic data message
+func renderSDMXML(result *observationsResult) ([]byte, error) {
+	observations, err := result.collectObservations()
+	if err != nil {
+		return nil, err
+	}
+
+	message := models.CreateSDMXGenericData(result.doc, result.versionDoc, result.datasetDoc, observations, time.Now())
+
+	b, err := xml.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
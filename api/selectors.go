@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// expandSelectors replaces the hierarchy, label and range selectors of the query parameters by the options they select,
+// before they are used to filter the observations. An option selected more than once is only kept the first time.
+func (api *API) expandSelectors(ctx context.Context, instanceID string, event *models.FilterSubmitted, queryParameters map[string][]string, logData log.Data) error {
+	for dimension, options := range queryParameters {
+		if !containsSelector(options) {
+			continue
+		}
+
+		expandedOptions := make([]string, 0, len(options))
+		selected := make(map[string]bool)
+
+		for _, option := range options {
+			selectedOptions, err := api.getSelectedOptions(ctx, instanceID, event, dimension, option)
+			if err != nil {
+				logData["dimension"] = dimension
+				logData["selector"] = option
+				log.Error(ctx, "get observations: failed to expand selector", err, logData)
+				return err
+			}
+
+			for _, selectedOption := range selectedOptions {
+				if !selected[selectedOption] {
+					selected[selectedOption] = true
+					expandedOptions = append(expandedOptions, selectedOption)
+				}
+			}
+		}
+
+		queryParameters[dimension] = expandedOptions
+	}
+
+	return nil
+}
+
+// getSelectedOptions returns the options selected by an option of the query parameters, which is the option itself unless it is a selector
+func (api *API) getSelectedOptions(ctx context.Context, instanceID string, event *models.FilterSubmitted, dimension, option string) ([]string, error) {
+	if match := hierarchySelector.FindStringSubmatch(option); match != nil {
+		return api.getHierarchyOptions(ctx, instanceID, dimension, option, match)
+	}
+
+	// a label can contain the range separator, so labels are parsed first
+	if label, found := parseLabelSelector(option); found {
+		return api.getLabelOptions(ctx, event, dimension, option, label)
+	}
+
+	if selector, found := parseRangeSelector(option); found {
+		return api.getRangeOptions(ctx, event, dimension, option, selector)
+	}
+
+	return []string{option}, nil
+}
+
+func containsSelector(options []string) bool {
+	for _, option := range options {
+		if isSelector(option) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelector returns whether an option of the query parameters is a hierarchy, label or range selector, rather than an option
+func isSelector(option string) bool {
+	if hierarchySelector.MatchString(option) {
+		return true
+	}
+
+	if _, found := parseLabelSelector(option); found {
+		return true
+	}
+
+	_, found := parseRangeSelector(option)
+	return found
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	sortParameter = "sort"
+
+	sortByCode            = "code"
+	sortByLabel           = "label"
+	sortByValue           = "value"
+	sortByValueDescending = "-value"
+	sortByOrder           = "order"
+)
+
+// supportedSorts are the values accepted by the sort query parameter
+var supportedSorts = []string{sortByCode, sortByLabel, sortByValue, sortByValueDescending, sortByOrder}
+
+// getSort returns the order of the observations requested by the sort query parameter, unless it is a version dimension.
+// An empty order is returned if no order has been requested, in which case the order of the graph database is kept.
+func getSort(urlQuery url.Values, validDimensions []string) (string, error) {
+	value, found := getReservedQueryParameter(urlQuery, validDimensions, sortParameter)
+	if !found {
+		return "", nil
+	}
+
+	order := strings.ToLower(strings.TrimSpace(value))
+	if !containsOption(supportedSorts, order) {
+		return "", errs.ErrorInvalidSort(value, supportedSorts)
+	}
+
+	return order, nil
+}
+
+// sortedRows iterates over rows of observations in sorted order. Every row has to be read before the first one is
+// returned, so the rows are read and sorted on the first call to Next, and any error reading them is returned then.
+// As the rows are held in memory to be sorted, an error is returned if there are more of them than the maximum.
+type sortedRows struct {
+	rows     rowIterator
+	less     func(a, b []string) bool
+	maxCount int
+	sorted   [][]string
+	read     bool
+}
+
+// Next returns the next row in sorted order, or io.EOF when there are no more rows
+func (s *sortedRows) Next() ([]string, error) {
+	if !s.read {
+		for {
+			row, err := s.rows.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(s.sorted) == s.maxCount {
+				return nil, errs.ErrorTooManySortedObservations(s.maxCount)
+			}
+			s.sorted = append(s.sorted, row)
+		}
+
+		sort.SliceStable(s.sorted, func(i, j int) bool {
+			return s.less(s.sorted[i], s.sorted[j])
+		})
+		s.read = true
+	}
+
+	if len(s.sorted) == 0 {
+		return nil, io.EOF
+	}
+
+	row := s.sorted[0]
+	s.sorted = s.sorted[1:]
+	return row, nil
+}
+
+// sortedDimension is a dimension whose options vary between observations, along with the columns of its code and label
+type sortedDimension struct {
+	name        string
+	codeColumn  int
+	labelColumn int
+	ranks       map[string]int
+}
+
+// sortObservationRows returns the rows of observations in the provided order, whose columns are described by the header
+// of the observations. Observations are compared by the options of the dimensions that vary between them, in the order of
+// the version dimensions, or by their value first when they are sorted by value, in which case observations without a
+// numeric value come last. The order of the options of a dimension is the order of the dimension, which is chronological
+// for the periods of the time dimension.
+func (api *API) sortObservationRows(ctx context.Context, event *models.FilterSubmitted, versionDoc *dataset.Version, header []string, dimensionOffset int, rows rowIterator, observationDimensions map[string]struct{}, order string) (rowIterator, error) {
+	var dimensions []sortedDimension
+	for _, versionDimension := range versionDoc.Dimensions {
+		if _, found := observationDimensions[versionDimension.Name]; !found {
+			continue
+		}
+
+		for i := dimensionOffset + 2; i < len(header); i += 2 {
+			if strings.ToLower(header[i]) == versionDimension.Name {
+				dimensions = append(dimensions, sortedDimension{name: versionDimension.Name, codeColumn: i - 1, labelColumn: i})
+				break
+			}
+		}
+	}
+
+	if order == sortByOrder {
+		for i := range dimensions {
+			options, err := api.getOrderedDimensionOptions(ctx, event, dimensions[i].name)
+			if err != nil {
+				return nil, err
+			}
+
+			dimensions[i].ranks = make(map[string]int, len(options))
+			for rank, option := range options {
+				dimensions[i].ranks[option.Option] = rank
+			}
+		}
+	}
+
+	compareOptions := func(a, b []string) int {
+		for _, dimension := range dimensions {
+			if c := dimension.compare(a, b, order); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	less := func(a, b []string) bool {
+		return compareOptions(a, b) < 0
+	}
+
+	if order == sortByValue || order == sortByValueDescending {
+		less = func(a, b []string) bool {
+			if c := compareValues(a[0], b[0], order == sortByValueDescending); c != 0 {
+				return c < 0
+			}
+			return compareOptions(a, b) < 0
+		}
+	}
+
+	return &sortedRows{rows: rows, less: less, maxCount: api.cfg.MaxSortedObservations}, nil
+}
+
+// compare compares the options of the dimension of two rows, by label if the rows are sorted by label, by the order of
+// the options of the dimension if they are sorted by order, and by code otherwise
+func (d sortedDimension) compare(a, b []string, order string) int {
+	switch order {
+	case sortByLabel:
+		if c := strings.Compare(strings.ToLower(a[d.labelColumn]), strings.ToLower(b[d.labelColumn])); c != 0 {
+			return c
+		}
+	case sortByOrder:
+		if c := d.rank(a[d.codeColumn]) - d.rank(b[d.codeColumn]); c != 0 {
+			return c
+		}
+	}
+
+	return strings.Compare(a[d.codeColumn], b[d.codeColumn])
+}
+
+// rank returns the position of the option in the order of the options of the dimension, options that are not listed coming last
+func (d sortedDimension) rank(code string) int {
+	if rank, found := d.ranks[code]; found {
+		return rank
+	}
+	return len(d.ranks)
+}
+
+// compareValues compares two observation values numerically, in descending order if requested. Values that are not
+// numbers, such as the markings of suppressed observations, come after numbers in either order.
+func compareValues(a, b string, descending bool) int {
+	x, isNumberA := parseValue(a)
+	y, isNumberB := parseValue(b)
+
+	switch {
+	case !isNumberA && !isNumberB:
+		return 0
+	case !isNumberA:
+		return 1
+	case !isNumberB:
+		return -1
+	case x == y:
+		return 0
+	case (x < y) != descending:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func parseValue(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) {
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/dp-observation-api/store"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// rowIterator iterates over rows of observations, split into their columns
+type rowIterator interface {
+	// Next returns the next row, or io.EOF when there are no more rows
+	Next() ([]string, error)
+}
+
+// observationRows reads the rows of observations streamed from an observation store, split into the columns of a V4
+// file, as described by the header of the rows: the observation, its metadata, then the code and label of each option.
+// The rows are counted, and no more rows than the limit are returned, so that a stream with rows past its limit can be
+// told to have been capped. The stream is expected to have one row more than the limit to tell whether it was capped.
+type observationRows struct {
+	reader          store.RowReader
+	header          []string
+	dimensionOffset int
+	limit           int
+	count           int
+	exceeded        bool
+}
+
+func newObservationRows(reader store.RowReader) *observationRows {
+	header := reader.Header()
+	return &observationRows{
+		reader:          reader,
+		header:          header.V4Columns(),
+		dimensionOffset: len(header.Metadata),
+	}
+}
+
+// Next returns the next row of observations, or io.EOF when there are no more rows or the limit has been reached
+func (o *observationRows) Next() ([]string, error) {
+	if o.exceeded {
+		return nil, io.EOF
+	}
+
+	row, err := o.reader.Read()
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, errs.ErrObservationsNotFound
+		}
+		return nil, err
+	}
+
+	if o.limit > 0 && o.count == o.limit {
+		o.exceeded = true
+		return nil, io.EOF
+	}
+
+	o.count++
+	return row.V4Columns(), nil
+}
+
+// capped returns whether the stream had more rows than its limit, which were not read
+func (o *observationRows) capped() bool {
+	return o.exceeded
+}
+
+// observationPage iterates over the rows of the page determined by offset and limit,
+// while counting every row so that the total number of rows can be reported
+type observationPage struct {
+	rows   rowIterator
+	offset int
+	limit  int
+	total  int
+	peeked []string
+	done   bool
+}
+
+func newObservationPage(rows rowIterator, offset, limit int) *observationPage {
+	return &observationPage{
+		rows:   rows,
+		offset: offset,
+		limit:  limit,
+	}
+}
+
+// peek reads the first row of the page in advance, so that any error reading it can be handled before the page is used
+func (p *observationPage) peek() error {
+	row, err := p.next()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	p.peeked = row
+	return nil
+}
+
+// Next returns the next row of the page, or io.EOF when there are no more rows in the page
+func (p *observationPage) Next() ([]string, error) {
+	if p.peeked != nil {
+		row := p.peeked
+		p.peeked = nil
+		return row, nil
+	}
+	return p.next()
+}
+
+func (p *observationPage) next() ([]string, error) {
+	for p.total < p.offset {
+		if _, err := p.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.total >= p.offset+p.limit {
+		return nil, io.EOF
+	}
+
+	return p.read()
+}
+
+func (p *observationPage) read() ([]string, error) {
+	if p.done {
+		return nil, io.EOF
+	}
+
+	row, err := p.rows.Next()
+	if err != nil {
+		if err == io.EOF {
+			p.done = true
+		}
+		return nil, err
+	}
+
+	p.total++
+	return row, nil
+}
+
+// Total reads the remaining rows and returns the total number of rows
+func (p *observationPage) Total() (int, error) {
+	for {
+		if _, err := p.read(); err != nil {
+			if err == io.EOF {
+				return p.total, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+// observationsResult holds the document describing the observations that matched a query,
+// along with the page of observations, which are read as the response is written
+type observationsResult struct {
+	doc                   *models.ObservationsDoc
+	versionDoc            *dataset.Version
+	datasetDoc            *dataset.DatasetDetails
+	rows                  *observationRows
+	page                  *observationPage
+	observationDimensions map[string]struct{}
+	format                string
+	filtered              *filteredRows
+	aggregated            *aggregatedRows
+	derived               *derivedRows
+}
+
+// nextObservation returns the next observation of the page, or io.EOF when there are no more observations
+func (o *observationsResult) nextObservation() (*models.Observation, error) {
+	row, err := o.page.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	var derivedColumns []string
+	if o.derived != nil {
+		row, derivedColumns = o.derived.splitRow(row)
+	}
+
+	observation := createObservation(o.versionDoc, row, o.rows.header, o.rows.dimensionOffset, o.observationDimensions)
+	observation.Derived = o.aggregated != nil
+	if o.derived != nil {
+		observation.DerivedValue = derivedValue(derivedColumns)
+	}
+	return &observation, nil
+}
+
+// collectObservations reads the whole page of observations, and sets the page of the observations document
+func (o *observationsResult) collectObservations() ([]models.Observation, error) {
+	var observations []models.Observation
+	for {
+		observation, err := o.nextObservation()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, *observation)
+	}
+
+	totalObservations, err := o.page.Total()
+	if err != nil {
+		return nil, err
+	}
+
+	o.setPage(len(observations), totalObservations)
+	return observations, nil
+}
+
+// setPage sets the page of the observations document once every observation has been read, along with the number of
+// observations filtered out by their value and the aggregation of the observations
+func (o *observationsResult) setPage(count, totalObservations int) {
+	o.doc.SetPage(count, totalObservations, o.rows.capped())
+	if o.filtered != nil {
+		o.doc.SetFilteredObservations(o.filtered.filtered)
+	}
+	if o.aggregated != nil {
+		o.doc.Aggregation = o.aggregated.aggregation
+	}
+}
+
+func (o *observationsResult) close(ctx context.Context) {
+	if err := o.rows.reader.Close(ctx); err != nil {
+		log.Error(ctx, "get observations: failed to close observation row reader", err)
+	}
+}
+
+// streamErrorTrailer is the trailer of a streamed observations response that fails once it has started
+const streamErrorTrailer = "X-Stream-Error"
+
+// writeObservationsDoc writes the observations document, encoding each observation as it is read. The fields that
+// depend on the number of observations are written after the observations. If reading the observations fails once
+// the document has been started, the observations are closed and the document ends with an error field instead.
+func writeObservationsDoc(w io.Writer, result *observationsResult) error {
+	bw := bufio.NewWriter(w)
+	doc := result.doc
+
+	head, err := marshalJSON(struct {
+		Dimensions map[string]models.Option `json:"dimensions"`
+		Limit      int                      `json:"limit"`
+	}{
+		Dimensions: doc.Dimensions,
+		Limit:      doc.Limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	bw.Write(head[:len(head)-1])
+	bw.WriteString(`,"observations":[`)
+
+	count := 0
+	for {
+		observation, err := result.nextObservation()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return writeStreamError(bw, err)
+		}
+
+		b, err := marshalJSON(observation)
+		if err != nil {
+			return writeStreamError(bw, err)
+		}
+
+		if count > 0 {
+			bw.WriteByte(',')
+		}
+		bw.Write(b)
+		count++
+	}
+
+	totalObservations, err := result.page.Total()
+	if err != nil {
+		return writeStreamError(bw, err)
+	}
+
+	result.setPage(count, totalObservations)
+
+	tail, err := marshalJSON(struct {
+		Count                int                      `json:"count"`
+		Offset               int                      `json:"offset"`
+		TotalObservations    int                      `json:"total_observations"`
+		TotalCapped          bool                     `json:"total_capped,omitempty"`
+		FilteredObservations *int                     `json:"filtered_observations,omitempty"`
+		Aggregation          *models.Aggregation      `json:"aggregation,omitempty"`
+		Links                *models.ObservationLinks `json:"links"`
+		UnitOfMeasure        string                   `json:"unit_of_measure,omitempty"`
+		UsageNotes           *[]dataset.UsageNote     `json:"usage_notes,omitempty"`
+	}{
+		Count:                doc.Count,
+		Offset:               doc.Offset,
+		TotalObservations:    doc.TotalObservations,
+		TotalCapped:          doc.TotalCapped,
+		FilteredObservations: doc.FilteredObservations,
+		Aggregation:          doc.Aggregation,
+		Links:                doc.Links,
+		UnitOfMeasure:        doc.UnitOfMeasure,
+		UsageNotes:           doc.UsageNotes,
+	})
+	if err != nil {
+		return writeStreamError(bw, err)
+	}
+
+	bw.WriteString("],")
+	bw.Write(tail[1:])
+	bw.WriteByte('\n')
+
+	return bw.Flush()
+}
+
+// writeStreamError ends the observations document with an error field, and returns the provided error
+func writeStreamError(bw *bufio.Writer, err error) error {
+	bw.WriteString(`],"error":"` + errs.ErrInternalServer.Error() + `"}` + "\n")
+
+	// the stream error is more relevant than any error writing the response
+	_ = bw.Flush()
+	return err
+}
+
+// marshalJSON encodes the provided value without escaping HTML characters
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	// The ampersand "&" is escaped to "\u0026" to keep some browsers from
+	// misinterpreting JSON output as HTML. This escaping can be disabled using
+	// an Encoder that had SetEscapeHTML(false) called on it.
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+	"github.com/ONSdigital/dp-observation-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// maxSuggestions is the maximum number of options suggested instead of an invalid option
+const maxSuggestions = 3
+
+// validateOptions checks that the options of the query parameters are options of their dimension before the graph database
+// is queried, as it cannot tell an option that does not exist from a selection without observations. Wildcards and
+// selectors are not validated here, since they are resolved against the options of the dimension when they are expanded,
+// and neither are the default options of the provided dimensions.
+func (api *API) validateOptions(ctx context.Context, event *models.FilterSubmitted, queryParameters map[string][]string, defaultedDimensions []string, logData log.Data) error {
+	dimensions := make([]string, 0, len(queryParameters))
+	for dimension := range queryParameters {
+		if !containsOption(defaultedDimensions, dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	sort.Strings(dimensions)
+
+	var invalidOptions []errs.InvalidOption
+
+	for _, dimension := range dimensions {
+		var options []string
+		for _, option := range queryParameters[dimension] {
+			if option != "*" && !isSelector(option) {
+				options = append(options, option)
+			}
+		}
+
+		if len(options) == 0 {
+			continue
+		}
+
+		dimensionOptions, err := api.getDimensionOptions(ctx, event, dimension)
+		if err != nil {
+			logData["dimension"] = dimension
+			return err
+		}
+
+		codes := make(map[string]bool, len(dimensionOptions))
+		for _, dimensionOption := range dimensionOptions {
+			codes[dimensionOption.Option] = true
+		}
+
+		for _, option := range options {
+			if !codes[option] {
+				invalidOptions = append(invalidOptions, errs.InvalidOption{
+					Dimension:   dimension,
+					Option:      option,
+					Suggestions: suggestOptions(option, dimensionOptions),
+				})
+			}
+		}
+	}
+
+	if len(invalidOptions) > 0 {
+		logData["invalid_options"] = invalidOptions
+		return errs.ErrorInvalidOptions(invalidOptions)
+	}
+
+	return nil
+}
+
+// suggestOptions returns the options of the dimension whose code or label is closest to the invalid option, ignoring case,
+// closest first. Only the options that are close enough to the invalid option for it to be a typo of them are suggested.
+func suggestOptions(option string, dimensionOptions []dataset.Option) []errs.Suggestion {
+	type suggestion struct {
+		option   dataset.Option
+		distance int
+	}
+
+	target := strings.ToLower(option)
+	maxDistance := len([]rune(target)) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	var suggestions []suggestion
+	for _, dimensionOption := range dimensionOptions {
+		distance := editDistance(target, strings.ToLower(dimensionOption.Option))
+		if dimensionOption.Label != "" {
+			if labelDistance := editDistance(target, strings.ToLower(dimensionOption.Label)); labelDistance < distance {
+				distance = labelDistance
+			}
+		}
+
+		if distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{option: dimensionOption, distance: distance})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	suggested := make([]errs.Suggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		suggested = append(suggested, errs.Suggestion{Code: s.option.Option, Label: s.option.Label})
+	}
+	return suggested
+}
+
+// describeOption returns the code of the option followed by its label, if it has one
+func describeOption(option dataset.Option) string {
+	if option.Label == "" {
+		return option.Option
+	}
+	return option.Option + " (" + option.Label + ")"
+}
+
+// editDistance returns the Levenshtein distance between two strings, which is the number of single character insertions,
+// deletions and substitutions needed to change one into the other
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			substitution := previous[j-1]
+			if source[i-1] != target[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
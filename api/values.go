@@ -0,0 +1,257 @@
+package api
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+)
+
+const (
+	valueParameter        = "value"
+	valueNotNullParameter = "value_not_null"
+	topParameter          = "top"
+	bottomParameter       = "bottom"
+)
+
+// valuePredicateExpression matches a predicate on the value of the observations, e.g. value>=1000. The query parameter
+// of value>=1000 is named "value>" and has the value "1000", so predicates are matched against the name of the query
+// parameter and its value joined back together.
+var valuePredicateExpression = regexp.MustCompile(`^value\s*(>=|<=|!=|=|>|<)\s*(.+)$`)
+
+// valuePredicate compares the value of an observation with a number
+type valuePredicate struct {
+	operator string
+	operand  float64
+}
+
+// matches returns whether the provided value is a number that satisfies the predicate
+func (p valuePredicate) matches(value string) bool {
+	number, isNumber := parseValue(value)
+	if !isNumber {
+		return false
+	}
+
+	switch p.operator {
+	case ">=":
+		return number >= p.operand
+	case "<=":
+		return number <= p.operand
+	case ">":
+		return number > p.operand
+	case "<":
+		return number < p.operand
+	case "!=":
+		return number != p.operand
+	default:
+		return number == p.operand
+	}
+}
+
+// valueFilter selects the observations whose value satisfies every predicate, and that have a value if null values are
+// excluded. It can then keep the observations with the top or bottom values, ranked by value.
+type valueFilter struct {
+	predicates []valuePredicate
+	notNull    bool
+	top        int
+	bottom     int
+}
+
+// matches returns whether an observation with the provided value is selected by the predicates of the filter. Only
+// observations with a numeric value can be ranked, so the other observations are not selected by a top or bottom filter.
+func (f *valueFilter) matches(value string) bool {
+	if f.notNull && strings.TrimSpace(value) == "" {
+		return false
+	}
+
+	if f.top > 0 || f.bottom > 0 {
+		if _, isNumber := parseValue(value); !isNumber {
+			return false
+		}
+	}
+
+	for _, predicate := range f.predicates {
+		if !predicate.matches(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValuePredicateParameter returns whether a query parameter name is part of a predicate on the value of the
+// observations, rather than a dimension
+func isValuePredicateParameter(name string, validDimensions []string) bool {
+	if name == valueParameter {
+		return !containsOption(validDimensions, valueParameter)
+	}
+	return strings.HasPrefix(name, valueParameter) && valuePredicateExpression.MatchString(name+"=0")
+}
+
+// getValueFilter returns the value filter of the observations selected by the value predicates, value_not_null, top and
+// bottom query parameters, or nil if none of them have been provided. A version dimension named value takes precedence
+// over the value=n predicate, like the other reserved query parameters.
+func getValueFilter(urlQuery url.Values, validDimensions []string) (*valueFilter, error) {
+	filter := &valueFilter{}
+	found := false
+
+	names := make([]string, 0, len(urlQuery))
+	for name := range urlQuery {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !isValuePredicateParameter(name, validDimensions) {
+			continue
+		}
+
+		for _, value := range urlQuery[name] {
+			expression := name
+			if value != "" {
+				expression += "=" + value
+			}
+
+			predicate, err := parseValuePredicate(expression)
+			if err != nil {
+				return nil, err
+			}
+
+			filter.predicates = append(filter.predicates, predicate)
+			found = true
+		}
+	}
+
+	if _, ok := getReservedQueryParameter(urlQuery, validDimensions, valueNotNullParameter); ok {
+		filter.notNull = true
+		found = true
+	}
+
+	for _, ranking := range []struct {
+		name string
+		n    *int
+	}{{topParameter, &filter.top}, {bottomParameter, &filter.bottom}} {
+		value, ok := getReservedQueryParameter(urlQuery, validDimensions, ranking.name)
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, errs.ErrorInvalidValueFilter(ranking.name+"="+value, "the number of observations must be a positive integer")
+		}
+		*ranking.n = n
+		found = true
+	}
+
+	if filter.top > 0 && filter.bottom > 0 {
+		return nil, errs.ErrorInvalidValueFilter("top and bottom", "only one of them can be requested")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return filter, nil
+}
+
+// parseValuePredicate parses a predicate on the value of the observations, e.g. value>=1000
+func parseValuePredicate(expression string) (valuePredicate, error) {
+	match := valuePredicateExpression.FindStringSubmatch(expression)
+	if match == nil {
+		return valuePredicate{}, errs.ErrorInvalidValueFilter(expression, "the predicate must compare the value with a number using one of >=, <=, >, <, = or !=")
+	}
+
+	operand, isNumber := parseValue(match[2])
+	if !isNumber {
+		return valuePredicate{}, errs.ErrorInvalidValueFilter(expression, "the value must be compared with a number")
+	}
+
+	return valuePredicate{operator: match[1], operand: operand}, nil
+}
+
+// filteredRows iterates over the rows of observations selected by a value filter, counting the rows that are filtered
+// out. The graph database cannot compare the values of observations, so they are filtered as they are read. When the top
+// or bottom observations are kept, every selected row is read and ranked by value on the first call to Next, which fails
+// if the stream of observations was capped as the top or bottom observations may not have been read.
+type filteredRows struct {
+	rows     rowIterator
+	stream   *observationRows
+	filter   *valueFilter
+	ranked   [][]string
+	read     bool
+	filtered int
+}
+
+// Next returns the next row selected by the filter, or io.EOF when there are no more rows
+func (f *filteredRows) Next() ([]string, error) {
+	if f.filter.top == 0 && f.filter.bottom == 0 {
+		return f.nextMatch()
+	}
+
+	if !f.read {
+		if err := f.rank(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(f.ranked) == 0 {
+		return nil, io.EOF
+	}
+
+	row := f.ranked[0]
+	f.ranked = f.ranked[1:]
+	return row, nil
+}
+
+// nextMatch returns the next row whose value matches the predicates of the filter
+func (f *filteredRows) nextMatch() ([]string, error) {
+	for {
+		row, err := f.rows.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if f.filter.matches(row[0]) {
+			return row, nil
+		}
+		f.filtered++
+	}
+}
+
+// rank reads every row that matches the predicates of the filter, and keeps the rows with the top or bottom values,
+// highest or lowest value first
+func (f *filteredRows) rank() error {
+	for {
+		row, err := f.nextMatch()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f.ranked = append(f.ranked, row)
+	}
+
+	if f.stream.capped() {
+		return errs.ErrorTooManyRankedObservations(f.stream.limit)
+	}
+
+	descending := f.filter.top > 0
+	sort.SliceStable(f.ranked, func(i, j int) bool {
+		return compareValues(f.ranked[i][0], f.ranked[j][0], descending) < 0
+	})
+
+	n := f.filter.top + f.filter.bottom
+	if len(f.ranked) > n {
+		f.filtered += len(f.ranked) - n
+		f.ranked = f.ranked[:n]
+	}
+
+	f.read = true
+	return nil
+}
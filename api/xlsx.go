@@ -0,0 +1,238 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-observation-api/models"
+)
+
+const (
+	xlsxDataSheet  = "Data"
+	xlsxNotesSheet = "Notes"
+)
+
+// xlsxCell is a cell of an XLSX worksheet, holding either a number or a string
+type xlsxCell struct {
+	value   string
+	numeric bool
+}
+
+// xlsxSheet is an XLSX worksheet, whose rows are written as SpreadsheetML as they are added
+type xlsxSheet struct {
+	name string
+	rows bytes.Buffer
+	n    int
+}
+
+func stringCells(values ...string) []xlsxCell {
+	cells := make([]xlsxCell, len(values))
+	for i, value := range values {
+		cells[i] = xlsxCell{value: value}
+	}
+	return cells
+}
+
+// addRow adds a row to the worksheet. Empty cells are left out.
+func (s *xlsxSheet) addRow(cells []xlsxCell) {
+	s.n++
+	rowRef := strconv.Itoa(s.n)
+
+	s.rows.WriteString(`<row r="` + rowRef + `">`)
+	for i, cell := range cells {
+		if cell.value == "" {
+			continue
+		}
+
+		ref := xlsxColumnName(i) + rowRef
+		if cell.numeric {
+			s.rows.WriteString(`<c r="` + ref + `"><v>` + cell.value + `</v></c>`)
+			continue
+		}
+
+		s.rows.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		_ = xml.EscapeText(&s.rows, []byte(cell.value))
+		s.rows.WriteString(`</t></is></c>`)
+	}
+	s.rows.WriteString(`</row>`)
+}
+
+// xlsxColumnName returns the name of the column at the provided zero based index, e.g. A, Z, AA
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xlsxPart is a part of the package of an XLSX workbook
+type xlsxPart struct {
+	name    string
+	content []byte
+}
+
+// writeXLSX writes a workbook holding the provided worksheets, in order, as an Office Open XML spreadsheet
+func writeXLSX(w io.Writer, sheets []*xlsxSheet) error {
+	var contentTypes, workbook, workbookRels bytes.Buffer
+
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, sheet := range sheets {
+		id := strconv.Itoa(i + 1)
+
+		contentTypes.WriteString(`<Override PartName="/xl/worksheets/sheet` + id + `.xml" ` +
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+
+		workbook.WriteString(`<sheet name="`)
+		_ = xml.EscapeText(&workbook, []byte(sheet.name))
+		workbook.WriteString(`" sheetId="` + id + `" r:id="rId` + id + `"/>`)
+
+		workbookRels.WriteString(`<Relationship Id="rId` + id + `" ` +
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + id + `.xml"/>`)
+	}
+
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	parts := []xlsxPart{
+		{name: "[Content_Types].xml", content: contentTypes.Bytes()},
+		{name: "_rels/.rels", content: []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{name: "xl/workbook.xml", content: workbook.Bytes()},
+		{name: "xl/_rels/workbook.xml.rels", content: workbookRels.Bytes()},
+	}
+
+	for i, sheet := range sheets {
+		content := []byte(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+		content = append(content, sheet.rows.Bytes()...)
+		content = append(content, `</sheetData></worksheet>`...)
+
+		parts = append(parts, xlsxPart{name: "xl/worksheets/sheet" + strconv.Itoa(i+1) + ".xml", content: content})
+	}
+
+	zw := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err = f.Write(part.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// renderXLSX renders the page of observations as an XLSX workbook, with a data sheet holding the same columns as the
+// CSV rendering, and a notes sheet holding the unit of measure, usage notes and links of the observations document
+func renderXLSX(result *observationsResult) ([]byte, error) {
+	dimensionOffset := result.rows.dimensionOffset
+	header := models.CSVHeader(result.rows.header, dimensionOffset)
+
+	observationColumn := 0
+	for i, column := range header {
+		if column == models.CSVObservationColumn {
+			observationColumn = i
+		}
+	}
+
+	if result.derived != nil {
+		header = append(header, result.derived.derivation.csvHeader()...)
+	}
+
+	data := &xlsxSheet{name: xlsxDataSheet}
+	data.addRow(stringCells(header...))
+
+	count := 0
+	for {
+		row, err := result.page.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var derivedColumns []string
+		if result.derived != nil {
+			row, derivedColumns = result.derived.splitRow(row)
+		}
+
+		csvRow := append(models.CSVRow(row, dimensionOffset), derivedColumns...)
+
+		cells := stringCells(csvRow...)
+		numericColumns := []int{observationColumn}
+		if result.derived != nil {
+			numericColumns = append(numericColumns, len(cells)-2)
+		}
+
+		for _, column := range numericColumns {
+			if value, err := strconv.ParseFloat(cells[column].value, 64); err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				cells[column] = xlsxCell{value: strconv.FormatFloat(value, 'g', -1, 64), numeric: true}
+			}
+		}
+
+		data.addRow(cells)
+		count++
+	}
+
+	totalObservations, err := result.page.Total()
+	if err != nil {
+		return nil, err
+	}
+
+	result.setPage(count, totalObservations)
+	doc := result.doc
+
+	notes := &xlsxSheet{name: xlsxNotesSheet}
+	notes.addRow(stringCells("Dataset", result.datasetDoc.Title))
+	notes.addRow(stringCells("Unit of measure", doc.UnitOfMeasure))
+	notes.addRow([]xlsxCell{{value: "Observations"}, {value: strconv.Itoa(doc.Count), numeric: true}})
+	if doc.TotalCapped {
+		notes.addRow([]xlsxCell{{value: "Total observations (at least)"}, {value: strconv.Itoa(doc.TotalObservations), numeric: true}})
+	} else {
+		notes.addRow([]xlsxCell{{value: "Total observations"}, {value: strconv.Itoa(doc.TotalObservations), numeric: true}})
+	}
+	if doc.FilteredObservations != nil {
+		notes.addRow([]xlsxCell{{value: "Filtered observations"}, {value: strconv.Itoa(*doc.FilteredObservations), numeric: true}})
+	}
+	if doc.Aggregation != nil {
+		notes.addRow(stringCells("Aggregate", doc.Aggregation.Aggregate+" across "+strings.Join(doc.Aggregation.Dimensions, ", ")))
+		notes.addRow([]xlsxCell{{value: "Input observations"}, {value: strconv.Itoa(doc.Aggregation.InputObservations), numeric: true}})
+		notes.addRow([]xlsxCell{{value: "Excluded observations"}, {value: strconv.Itoa(doc.Aggregation.ExcludedObservations), numeric: true}})
+	}
+	notes.addRow(stringCells("Self", doc.Links.Self.URL))
+	notes.addRow(stringCells("Version", doc.Links.Version.URL))
+
+	if doc.UsageNotes != nil && len(*doc.UsageNotes) > 0 {
+		notes.addRow(nil)
+		notes.addRow(stringCells("Usage notes"))
+		for _, usageNote := range *doc.UsageNotes {
+			notes.addRow(stringCells(usageNote.Title, usageNote.Note))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, []*xlsxSheet{data, notes}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
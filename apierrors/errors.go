@@ -3,6 +3,7 @@ package apierrors
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // A list of error messages for Observation API
@@ -11,13 +12,14 @@ var (
 	ErrEditionNotFound          = errors.New("edition not found")
 	ErrVersionNotFound          = errors.New("version not found")
 	ErrObservationsNotFound     = errors.New("no observations found")
-	ErrTooManyWildcards         = errors.New("only one wildcard (*) is allowed as a value in selected query parameters")
 	ErrMissingVersionDimensions = errors.New("missing list of dimensions from version doc")
 	ErrIndexOutOfRange          = errors.New("index out of range")
 	ErrInternalServer           = errors.New("internal error")
 	ErrUnauthorised             = errors.New("unauthorised")
 	ErrResourceState            = errors.New("incorrect resource state")
 	ErrInvalidDocType           = errors.New("incorrect document type")
+	ErrMissingCantabularDataset = errors.New("missing cantabular dataset from version doc")
+	ErrV4FileTooLarge           = errors.New("the V4 file is larger than the maximum import size")
 )
 
 // ObservationQueryError is an error structure to handle observation query errors
@@ -44,9 +46,196 @@ func ErrorMissingQueryParameters(params []string) error {
 	}
 }
 
-// ErrorMultivaluedQueryParameters returns an error for multi-valued query parameters
-func ErrorMultivaluedQueryParameters(params []string) error {
+// ErrorWildcardWithOptions returns an error for query parameters combining a wildcard with other options
+func ErrorWildcardWithOptions(params []string) error {
 	return ObservationQueryError{
-		message: fmt.Sprintf("multi-valued query parameters for the following dimensions: %v", params),
+		message: fmt.Sprintf("a wildcard (*) cannot be combined with other options for the following dimensions: %v", params),
 	}
 }
+
+// ErrorTooManyObservations returns an error for a selection of query parameters that can return more observations than allowed
+func ErrorTooManyObservations(maxCount int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the selected query parameters can return more than the maximum of %d observations, select fewer options or wildcards", maxCount),
+	}
+}
+
+// ErrorTooManySortedObservations returns an error for a sorted selection of observations that has more observations than
+// can be sorted
+func ErrorTooManySortedObservations(maxCount int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the selected observations cannot be sorted as there are more than the maximum of %d, select fewer options or wildcards", maxCount),
+	}
+}
+
+// ErrorTooManyAggregatedObservations returns an error for a selection of observations that has more observations than
+// can be read to aggregate them
+func ErrorTooManyAggregatedObservations(maxCount int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the selected observations cannot be aggregated as there are more than the maximum of %d, select fewer options or wildcards", maxCount),
+	}
+}
+
+// ErrorTooManyRankedObservations returns an error for a selection of observations that has more observations than can be
+// read to rank them by value
+func ErrorTooManyRankedObservations(maxCount int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the top or bottom selected observations cannot be found as there are more than the maximum of %d, select fewer options or wildcards", maxCount),
+	}
+}
+
+// ErrorTooManyDerivedObservations returns an error for a selection of observations that has more observations than can
+// be read to calculate their derived values
+func ErrorTooManyDerivedObservations(maxCount int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the derived values of the selected observations cannot be calculated as there are more than the maximum of %d, select fewer options or wildcards", maxCount),
+	}
+}
+
+// ErrorInvalidOffset returns an error for an offset query parameter that is not a positive integer
+func ErrorInvalidOffset(value string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid offset query parameter: %q, the offset must be a positive integer", value),
+	}
+}
+
+// ErrorInvalidLimit returns an error for a limit query parameter that is not a positive integer up to the maximum limit
+func ErrorInvalidLimit(value string, maxLimit int) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid limit query parameter: %q, the limit must be a positive integer up to %d", value, maxLimit),
+	}
+}
+
+// ErrorInvalidFormat returns an error for a format query parameter that is not supported
+func ErrorInvalidFormat(value string, formats []string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid format query parameter: %q, the format must be one of: %v", value, formats),
+	}
+}
+
+// ErrorInvalidSort returns an error for a sort query parameter that is not supported
+func ErrorInvalidSort(value string, sorts []string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid sort query parameter: %q, the sort must be one of: %v", value, sorts),
+	}
+}
+
+// ErrorInvalidValueFilter returns an error for a filter on the value of the observations that cannot be applied
+func ErrorInvalidValueFilter(filter, reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid value filter: %q, %s", filter, reason),
+	}
+}
+
+// ErrorInvalidAggregate returns an error for an aggregate of the observations that cannot be computed
+func ErrorInvalidAggregate(value, reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid aggregate query parameter: %q, %s", value, reason),
+	}
+}
+
+// ErrorInvalidDerivation returns an error for a derived value of the observations that cannot be calculated
+func ErrorInvalidDerivation(value, reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid derive query parameter: %q, %s", value, reason),
+	}
+}
+
+// ErrorCantabularTable returns an error for a selection of options that Cantabular cannot return a table of observations for,
+// such as a table that is too large or that would disclose the observations of too few people
+func ErrorCantabularTable(reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("the selected options cannot be queried: %s", reason),
+	}
+}
+
+// ErrorInvalidQueryBody returns an error for a query body that cannot be used to query observations
+func ErrorInvalidQueryBody(reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid query body: %s", reason),
+	}
+}
+
+// ErrorInvalidSelector returns an error for a hierarchy selector that cannot be expanded into the options it selects
+func ErrorInvalidSelector(selector, reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid hierarchy selector: %q, %s", selector, reason),
+	}
+}
+
+// ErrorInvalidRange returns an error for a range selector whose bounds do not select a range of options of the dimension
+func ErrorInvalidRange(selector, reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid range selector: %q, %s", selector, reason),
+	}
+}
+
+// ErrorUnknownLabel returns an error for a label selector that does not match the label of any option of the dimension
+func ErrorUnknownLabel(selector string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid label selector: %q, no option of the dimension has this label", selector),
+	}
+}
+
+// ErrorAmbiguousLabel returns an error for a label selector that matches the label of more than one option of the dimension
+func ErrorAmbiguousLabel(selector string, candidates []string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("ambiguous label selector: %q, select one of the matching options by its code: %v", selector, candidates),
+	}
+}
+
+// ErrorInvalidV4File returns an error for an uploaded V4 file that cannot be imported into the observation store
+func ErrorInvalidV4File(reason string) error {
+	return ObservationQueryError{
+		message: fmt.Sprintf("invalid V4 file: %s", reason),
+	}
+}
+
+// InvalidOption is an option of the query parameters that is not an option of its dimension, along with the closest
+// options of the dimension to suggest instead
+type InvalidOption struct {
+	Dimension   string       `json:"dimension"`
+	Option      string       `json:"option"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// Suggestion is an option of a dimension suggested instead of an invalid option, identified by its code along with its label
+type Suggestion struct {
+	Code  string `json:"code"`
+	Label string `json:"label,omitempty"`
+}
+
+// InvalidOptionsError is an error for options that are not options of their dimension, which is reported to clients as a
+// JSON body listing each invalid option, rather than as a message, so that they can tell which options to correct
+type InvalidOptionsError struct {
+	Errors []InvalidOption `json:"errors"`
+}
+
+// Error returns a message describing each invalid option and the options suggested instead
+func (e InvalidOptionsError) Error() string {
+	descriptions := make([]string, 0, len(e.Errors))
+	for _, option := range e.Errors {
+		description := fmt.Sprintf("%q is not an option of the %s dimension", option.Option, option.Dimension)
+		if len(option.Suggestions) > 0 {
+			suggestions := make([]string, 0, len(option.Suggestions))
+			for _, suggestion := range option.Suggestions {
+				suggestions = append(suggestions, suggestion.Code)
+			}
+			description += fmt.Sprintf(", did you mean: %v", suggestions)
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	return fmt.Sprintf("invalid options in query parameters: %s", strings.Join(descriptions, "; "))
+}
+
+// ErrorInvalidOptions returns an error for options that are not options of their dimension, suggesting the closest valid options
+func ErrorInvalidOptions(options []InvalidOption) error {
+	for i := range options {
+		if options[i].Suggestions == nil {
+			options[i].Suggestions = []Suggestion{}
+		}
+	}
+
+	return InvalidOptionsError{Errors: options}
+}
@@ -8,23 +8,36 @@ import (
 
 // Config represents service configuration for dp-observation-api
 type Config struct {
-	BindAddr                     string        `envconfig:"BIND_ADDR"`
-	HTTPWriteTimeout             time.Duration `envconfig:"HTTP_WRITE_TIMEOUT"`
-	CantabularRequestTimeout     time.Duration `envconfig:"CANTABULAR_REQUEST_TIMEOUT"`
-	ServiceAuthToken             string        `envconfig:"SERVICE_AUTH_TOKEN"          json:"-"`
-	CodeListAPIURL               string        `envconfig:"CODE_LIST_API_URL"`
-	DatasetAPIURL                string        `envconfig:"DATASET_API_URL"`
-	ObservationAPIURL            string        `envconfig:"OBSERVATION_API_URL"`
-	ZebedeeURL                   string        `envconfig:"ZEBEDEE_URL"`
-	CantabularURL                string        `envconfig:"CANTABULAR_URL"`
-	CantabularExtURL             string        `envconfig:"CANTABULAR_EXT_API_URL"`
-	CantabularHealthcheckEnabled bool          `envconfig:"CANTABULAR_HEALTHCHECK_ENABLED"`
-	DefaultObservationLimit      int           `envconfig:"DEFAULT_OBSERVATION_LIMIT"`
-	EnablePrivateEndpoints       bool          `envconfig:"ENABLE_PRIVATE_ENDPOINTS"`
-	EnableURLRewriting           bool          `envconfig:"ENABLE_URL_REWRITING"`
-	GracefulShutdownTimeout      time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
-	HealthCheckInterval          time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
-	HealthCheckCriticalTimeout   time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
+	BindAddr                      string            `envconfig:"BIND_ADDR"`
+	HTTPWriteTimeout              time.Duration     `envconfig:"HTTP_WRITE_TIMEOUT"`
+	CantabularRequestTimeout      time.Duration     `envconfig:"CANTABULAR_REQUEST_TIMEOUT"`
+	ServiceAuthToken              string            `envconfig:"SERVICE_AUTH_TOKEN"          json:"-"`
+	CodeListAPIURL                string            `envconfig:"CODE_LIST_API_URL"`
+	DatasetAPIURL                 string            `envconfig:"DATASET_API_URL"`
+	HierarchyAPIURL               string            `envconfig:"HIERARCHY_API_URL"`
+	ObservationAPIURL             string            `envconfig:"OBSERVATION_API_URL"`
+	ZebedeeURL                    string            `envconfig:"ZEBEDEE_URL"`
+	CantabularURL                 string            `envconfig:"CANTABULAR_URL"`
+	CantabularExtURL              string            `envconfig:"CANTABULAR_EXT_API_URL"`
+	CantabularHealthcheckEnabled  bool              `envconfig:"CANTABULAR_HEALTHCHECK_ENABLED"`
+	DefaultObservationLimit       int               `envconfig:"DEFAULT_OBSERVATION_LIMIT"`
+	MaxObservationLimit           int               `envconfig:"MAX_OBSERVATION_LIMIT"`
+	MaxObservationCellCount       int               `envconfig:"MAX_OBSERVATION_CELL_COUNT"`
+	MaxSortedObservations         int               `envconfig:"MAX_SORTED_OBSERVATIONS"`
+	ObservationStoreDir           string            `envconfig:"OBSERVATION_STORE_DIR"`
+	ObservationStoreDriver        string            `envconfig:"OBSERVATION_STORE_DRIVER"`
+	ObservationStoreDSN           string            `envconfig:"OBSERVATION_STORE_DSN"       json:"-"`
+	ObservationStoreByDataset     map[string]string `envconfig:"OBSERVATION_STORE_BY_DATASET"`
+	ObservationStoreByDatasetType map[string]string `envconfig:"OBSERVATION_STORE_BY_DATASET_TYPE"`
+	DefaultDimensionOptions       map[string]string `envconfig:"DEFAULT_DIMENSION_OPTIONS"`
+	DimensionOptionsCacheTTL      time.Duration     `envconfig:"DIMENSION_OPTIONS_CACHE_TTL"`
+	EnablePrivateEndpoints        bool              `envconfig:"ENABLE_PRIVATE_ENDPOINTS"`
+	EnableDevMode                 bool              `envconfig:"ENABLE_DEV_MODE"`
+	MaxImportSize                 int64             `envconfig:"MAX_IMPORT_SIZE"`
+	EnableURLRewriting            bool              `envconfig:"ENABLE_URL_REWRITING"`
+	GracefulShutdownTimeout       time.Duration     `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
+	HealthCheckInterval           time.Duration     `envconfig:"HEALTHCHECK_INTERVAL"`
+	HealthCheckCriticalTimeout    time.Duration     `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
 }
 
 var cfg *Config
@@ -43,13 +56,23 @@ func Get() (*Config, error) {
 		ServiceAuthToken:             "",
 		CodeListAPIURL:               "http://localhost:22400",
 		DatasetAPIURL:                "http://localhost:22000",
+		HierarchyAPIURL:              "http://localhost:22600",
 		ObservationAPIURL:            "http://localhost:24500",
 		ZebedeeURL:                   "http://localhost:8082",
 		CantabularURL:                "localhost:8491",
 		CantabularExtURL:             "http://localhost:8492",
 		CantabularHealthcheckEnabled: false,
 		DefaultObservationLimit:      10000,
+		MaxObservationLimit:          10000,
+		MaxObservationCellCount:      1000000,
+		MaxSortedObservations:        100000,
+		ObservationStoreDir:          "",
+		ObservationStoreDriver:       "",
+		ObservationStoreDSN:          "",
+		DimensionOptionsCacheTTL:     10 * time.Minute,
 		EnablePrivateEndpoints:       false,
+		EnableDevMode:                false,
+		MaxImportSize:                100 << 20,
 		EnableURLRewriting:           false,
 		GracefulShutdownTimeout:      5 * time.Second,
 		HealthCheckInterval:          30 * time.Second,
@@ -27,6 +27,7 @@ func TestConfig(t *testing.T) {
 					ServiceAuthToken:           "",
 					CodeListAPIURL:             "http://localhost:22400",
 					DatasetAPIURL:              "http://localhost:22000",
+					HierarchyAPIURL:            "http://localhost:22600",
 					ObservationAPIURL:          "http://localhost:24500",
 					ZebedeeURL:                 "http://localhost:8082",
 					CantabularURL:              "localhost:8491",
@@ -34,6 +35,11 @@ func TestConfig(t *testing.T) {
 					EnablePrivateEndpoints:     false,
 					EnableURLRewriting:         false,
 					DefaultObservationLimit:    10000,
+					MaxObservationLimit:        10000,
+					MaxObservationCellCount:    1000000,
+					MaxSortedObservations:      100000,
+					MaxImportSize:              100 << 20,
+					DimensionOptionsCacheTTL:   10 * time.Minute,
 					GracefulShutdownTimeout:    5 * time.Second,
 					HealthCheckInterval:        30 * time.Second,
 					HealthCheckCriticalTimeout: 90 * time.Second,
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+)
+
+// dimensionCodes holds the ordered option codes of a dimension for a page of observations, with their labels where known
+type dimensionCodes struct {
+	codes   []string
+	labels  map[string]string
+	indexes map[string]int
+}
+
+// getDimensionCodes returns the selected options of the dimension, followed by any option found in the observations,
+// so that wildcard dimensions only list the options of the page
+func getDimensionCodes(name string, selectedOptions map[string]Option, observations []Observation) *dimensionCodes {
+	d := &dimensionCodes{
+		labels:  make(map[string]string),
+		indexes: make(map[string]int),
+	}
+
+	for _, link := range selectedOptions[name].Links() {
+		d.add(link.ID, "")
+	}
+
+	for i := range observations {
+		for key, dimensionObject := range observations[i].Dimensions {
+			if strings.ToLower(key) == name {
+				d.add(dimensionObject.ID, dimensionObject.Label)
+			}
+		}
+	}
+
+	return d
+}
+
+// add adds an option code if it is not already present, and sets its label if it is known
+func (d *dimensionCodes) add(code, label string) {
+	if _, found := d.indexes[code]; !found {
+		d.indexes[code] = len(d.codes)
+		d.codes = append(d.codes, code)
+	}
+
+	if label != "" {
+		d.labels[code] = label
+	}
+}
+
+// getObservationCodes returns the option code of each dimension of the observation, including the
+// dimensions that are not part of the observation as they have a single selected option
+func getObservationCodes(observation *Observation, selectedOptions map[string]Option) map[string]string {
+	codes := make(map[string]string, len(selectedOptions)+len(observation.Dimensions))
+	for name, option := range selectedOptions {
+		if links := option.Links(); len(links) == 1 {
+			codes[name] = links[0].ID
+		}
+	}
+
+	for key, dimensionObject := range observation.Dimensions {
+		codes[strings.ToLower(key)] = dimensionObject.ID
+	}
+
+	return codes
+}
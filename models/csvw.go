@@ -0,0 +1,158 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
+
+const (
+	csvwContext = "http://www.w3.org/ns/csvw"
+
+	// CSVObservationColumn is the column of the observations in the CSV rendering of observations
+	CSVObservationColumn = "observation"
+
+	csvCodeColumnSuffix = "_code"
+)
+
+// CSVWMetadata represents the CSV on the Web metadata document describing the CSV rendering of observations
+type CSVWMetadata struct {
+	Context     string           `json:"@context"`
+	URL         string           `json:"url"`
+	Title       string           `json:"dct:title,omitempty"`
+	Description string           `json:"dct:description,omitempty"`
+	Comment     []string         `json:"rdfs:comment,omitempty"`
+	TableSchema *CSVWTableSchema `json:"tableSchema"`
+}
+
+// CSVWTableSchema represents the columns of a CSV on the Web table
+type CSVWTableSchema struct {
+	Columns []*CSVWColumn `json:"columns"`
+}
+
+// CSVWColumn represents a column of a CSV on the Web table. The value URL of a dimension code column
+// is a template of the code list href of each option, which are the hrefs of the dimension options.
+type CSVWColumn struct {
+	Name        string   `json:"name"`
+	Titles      string   `json:"titles"`
+	Description string   `json:"dct:description,omitempty"`
+	Datatype    string   `json:"datatype"`
+	Null        []string `json:"null,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	PropertyURL string   `json:"propertyUrl,omitempty"`
+	ValueURL    string   `json:"valueUrl,omitempty"`
+}
+
+// CSVHeader returns the header of the CSV rendering of observations from the header row of a V4 file: a code
+// and a label column for each dimension, followed by the observation and its metadata columns
+func CSVHeader(v4Header []string, dimensionOffset int) []string {
+	csvHeader := make([]string, 0, len(v4Header)+1)
+	for i := dimensionOffset + 2; i < len(v4Header); i += 2 {
+		csvHeader = append(csvHeader, v4Header[i]+csvCodeColumnSuffix, v4Header[i])
+	}
+	csvHeader = append(csvHeader, CSVObservationColumn)
+	return append(csvHeader, v4Header[1:dimensionOffset+1]...)
+}
+
+// CSVRow returns a row of the CSV rendering of observations from a row of a V4 file, in the order of CSVHeader
+func CSVRow(v4Row []string, dimensionOffset int) []string {
+	csvRow := make([]string, 0, len(v4Row)+1)
+	for i := dimensionOffset + 2; i < len(v4Row); i += 2 {
+		csvRow = append(csvRow, v4Row[i-1], v4Row[i])
+	}
+	csvRow = append(csvRow, v4Row[0])
+	return append(csvRow, v4Row[1:dimensionOffset+1]...)
+}
+
+// CreateCSVWMetadata creates the CSV on the Web metadata document of the CSV rendering of observations, found at the
+// provided table URL. The columns are described by the V4 header of the observations, in the order of CSVHeader,
+// followed by the value and status columns of the derived values of the observations, if they are provided. The
+// dimensions and code lists of the version describe the dimension columns, and the dataset details describe the table.
+// The observation column is described by the unit of measure and the usage notes are comments on the table.
+func CreateCSVWMetadata(tableURL string, v4Header []string, dimensionOffset int, derivedHeader []string, versionDoc *dataset.Version, datasetDetails *dataset.DatasetDetails) *CSVWMetadata {
+	metadata := &CSVWMetadata{
+		Context:     csvwContext,
+		URL:         tableURL,
+		Title:       datasetDetails.Title,
+		Description: datasetDetails.Description,
+		TableSchema: &CSVWTableSchema{},
+	}
+
+	if datasetDetails.UsageNotes != nil {
+		for _, usageNote := range *datasetDetails.UsageNotes {
+			metadata.Comment = append(metadata.Comment, usageNote.Title+": "+usageNote.Note)
+		}
+	}
+
+	versionDimensions := make(map[string]*dataset.VersionDimension)
+	for i := range versionDoc.Dimensions {
+		versionDimensions[versionDoc.Dimensions[i].Name] = &versionDoc.Dimensions[i]
+	}
+
+	for i := dimensionOffset + 2; i < len(v4Header); i += 2 {
+		dimensionName := v4Header[i]
+		codeColumn := &CSVWColumn{
+			Name:     csvwColumnName(dimensionName + csvCodeColumnSuffix),
+			Titles:   dimensionName + csvCodeColumnSuffix,
+			Datatype: "string",
+			Required: true,
+		}
+		labelColumn := &CSVWColumn{
+			Name:     csvwColumnName(dimensionName),
+			Titles:   dimensionName,
+			Datatype: "string",
+		}
+
+		if versionDimension, found := versionDimensions[strings.ToLower(dimensionName)]; found {
+			if versionDimension.URL != "" {
+				codeColumn.PropertyURL = versionDimension.URL
+				codeColumn.ValueURL = versionDimension.URL + "/codes/{" + codeColumn.Name + "}"
+			}
+			labelColumn.Description = versionDimension.Description
+		}
+
+		metadata.TableSchema.Columns = append(metadata.TableSchema.Columns, codeColumn, labelColumn)
+	}
+
+	// observations are not always numbers, as sparsity markers are held in the same column
+	metadata.TableSchema.Columns = append(metadata.TableSchema.Columns, &CSVWColumn{
+		Name:        CSVObservationColumn,
+		Titles:      CSVObservationColumn,
+		Description: datasetDetails.UnitOfMeasure,
+		Datatype:    "string",
+	})
+
+	for _, metadataColumn := range v4Header[1 : dimensionOffset+1] {
+		metadata.TableSchema.Columns = append(metadata.TableSchema.Columns, &CSVWColumn{
+			Name:     csvwColumnName(metadataColumn),
+			Titles:   metadataColumn,
+			Datatype: "string",
+		})
+	}
+
+	// a derived value is a number, or empty when it cannot be calculated, in which case its status tells why
+	if len(derivedHeader) == 2 {
+		metadata.TableSchema.Columns = append(metadata.TableSchema.Columns, &CSVWColumn{
+			Name:     csvwColumnName(derivedHeader[0]),
+			Titles:   derivedHeader[0],
+			Datatype: "number",
+			Null:     []string{""},
+		}, &CSVWColumn{
+			Name:     csvwColumnName(derivedHeader[1]),
+			Titles:   derivedHeader[1],
+			Datatype: "string",
+		})
+	}
+
+	return metadata
+}
+
+// csvwColumnName returns a column name that can be used in URI templates, replacing any other character by '_'
+func csvwColumnName(title string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, title)
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"strconv"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
+
+const (
+	jsonStatVersion = "2.0"
+	jsonStatClass   = "dataset"
+
+	// dataMarkingColumn is the metadata column of V4 files that marks an observation, e.g. as provisional
+	dataMarkingColumn = "data_marking"
+)
+
+// jsonStatRoles maps the dimension names that have a well known JSON-stat role to that role
+var jsonStatRoles = map[string]string{
+	"time":      "time",
+	"geography": "geo",
+}
+
+// JSONStatDataset represents a page of observations as a JSON-stat 2.0 dataset
+type JSONStatDataset struct {
+	Version   string                        `json:"version"`
+	Class     string                        `json:"class"`
+	Href      string                        `json:"href,omitempty"`
+	Label     string                        `json:"label,omitempty"`
+	Note      []string                      `json:"note,omitempty"`
+	ID        []string                      `json:"id"`
+	Size      []int                         `json:"size"`
+	Role      map[string][]string           `json:"role,omitempty"`
+	Dimension map[string]*JSONStatDimension `json:"dimension"`
+	Value     []*float64                    `json:"value"`
+	Status    map[string]string             `json:"status,omitempty"`
+	Extension *JSONStatExtension            `json:"extension,omitempty"`
+}
+
+// JSONStatDimension represents a dimension of a JSON-stat dataset
+type JSONStatDimension struct {
+	Label    string            `json:"label,omitempty"`
+	Href     string            `json:"href,omitempty"`
+	Category *JSONStatCategory `json:"category"`
+}
+
+// JSONStatCategory represents the ordered option codes of a JSON-stat dimension, along with their labels
+type JSONStatCategory struct {
+	Index []string          `json:"index"`
+	Label map[string]string `json:"label,omitempty"`
+}
+
+// JSONStatExtension holds the information of the observations document that has no JSON-stat equivalent
+type JSONStatExtension struct {
+	UnitOfMeasure        string            `json:"unit_of_measure,omitempty"`
+	Count                int               `json:"count"`
+	Offset               int               `json:"offset"`
+	Limit                int               `json:"limit"`
+	TotalObservations    int               `json:"total_observations"`
+	TotalCapped          bool              `json:"total_capped,omitempty"`
+	FilteredObservations *int              `json:"filtered_observations,omitempty"`
+	Links                *ObservationLinks `json:"links"`
+}
+
+// CreateJSONStatDataset creates a JSON-stat dataset from the observations document and its page of observations.
+// The categories of each dimension are the selected options, followed by any option found in the observations, so
+// that wildcard dimensions only list the options of the page. Values that are not numeric, such as sparsity markers,
+// are null with the marker reported as their status, otherwise the status is the data marking of the observation.
+func CreateJSONStatDataset(doc *ObservationsDoc, versionDoc *dataset.Version, datasetTitle string, observations []Observation) *JSONStatDataset {
+	jsonStat := &JSONStatDataset{
+		Version:   jsonStatVersion,
+		Class:     jsonStatClass,
+		Href:      doc.Links.Self.URL,
+		Label:     datasetTitle,
+		Dimension: make(map[string]*JSONStatDimension),
+		Extension: &JSONStatExtension{
+			UnitOfMeasure:        doc.UnitOfMeasure,
+			Count:                doc.Count,
+			Offset:               doc.Offset,
+			Limit:                doc.Limit,
+			TotalObservations:    doc.TotalObservations,
+			TotalCapped:          doc.TotalCapped,
+			FilteredObservations: doc.FilteredObservations,
+			Links:                doc.Links,
+		},
+	}
+
+	if doc.UsageNotes != nil {
+		for _, usageNote := range *doc.UsageNotes {
+			jsonStat.Note = append(jsonStat.Note, usageNote.Title+": "+usageNote.Note)
+		}
+	}
+
+	dimensionIndexes := make(map[string]map[string]int)
+
+	for i := range versionDoc.Dimensions {
+		versionDimension := &versionDoc.Dimensions[i]
+		name := versionDimension.Name
+
+		label := versionDimension.Label
+		if label == "" {
+			label = name
+		}
+
+		codes := getDimensionCodes(name, doc.Dimensions, observations)
+		dimension := &JSONStatDimension{
+			Label:    label,
+			Href:     versionDimension.URL,
+			Category: &JSONStatCategory{Index: codes.codes},
+		}
+
+		if len(codes.labels) > 0 {
+			dimension.Category.Label = codes.labels
+		}
+
+		if role, found := jsonStatRoles[name]; found {
+			if jsonStat.Role == nil {
+				jsonStat.Role = make(map[string][]string)
+			}
+			jsonStat.Role[role] = append(jsonStat.Role[role], name)
+		}
+
+		jsonStat.ID = append(jsonStat.ID, name)
+		jsonStat.Size = append(jsonStat.Size, len(codes.codes))
+		jsonStat.Dimension[name] = dimension
+		dimensionIndexes[name] = codes.indexes
+	}
+
+	valueCount := 1
+	for _, size := range jsonStat.Size {
+		valueCount *= size
+	}
+	jsonStat.Value = make([]*float64, valueCount)
+
+	for i := range observations {
+		observation := &observations[i]
+
+		// the values are in row-major order of the dimensions
+		index := 0
+		observationCodes := getObservationCodes(observation, doc.Dimensions)
+		for j, name := range jsonStat.ID {
+			index = index*jsonStat.Size[j] + dimensionIndexes[name][observationCodes[name]]
+		}
+
+		value, err := strconv.ParseFloat(observation.Observation, 64)
+		if err == nil {
+			jsonStat.Value[index] = &value
+		}
+
+		status := observation.Metadata[dataMarkingColumn]
+		if err != nil && observation.Observation != "" {
+			status = observation.Observation
+		}
+
+		if status != "" {
+			if jsonStat.Status == nil {
+				jsonStat.Status = make(map[string]string)
+			}
+			jsonStat.Status[strconv.Itoa(index)] = status
+		}
+	}
+
+	return jsonStat
+}
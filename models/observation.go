@@ -1,27 +1,67 @@
 package models
 
-import "github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
 
 const wildcard = "*"
 
-// ObservationsDoc represents information (observations) relevant to a version
+// pageParameters are the query parameters of the page of observations, unless they are version dimensions
+var pageParameters = []string{"offset", "limit"}
+
+// ObservationsDoc represents information (observations) relevant to a version.
+// The fields that depend on the number of observations follow them, as observations are streamed.
+// The total is a lower bound when it is capped, as the observations are only read up to the maximum a query can select.
 type ObservationsDoc struct {
-	Dimensions        map[string]Option    `json:"dimensions"`
-	Limit             int                  `json:"limit"`
-	Links             *ObservationLinks    `json:"links"`
-	Observations      []Observation        `json:"observations"`
-	Offset            int                  `json:"offset"`
-	TotalObservations int                  `json:"total_observations"`
-	UnitOfMeasure     string               `json:"unit_of_measure,omitempty"`
-	UsageNotes        *[]dataset.UsageNote `json:"usage_notes,omitempty"`
+	Dimensions           map[string]Option    `json:"dimensions"`
+	Limit                int                  `json:"limit"`
+	Observations         []Observation        `json:"observations"`
+	Error                string               `json:"error,omitempty"`
+	Count                int                  `json:"count"`
+	Offset               int                  `json:"offset"`
+	TotalObservations    int                  `json:"total_observations"`
+	TotalCapped          bool                 `json:"total_capped,omitempty"`
+	FilteredObservations *int                 `json:"filtered_observations,omitempty"`
+	Aggregation          *Aggregation         `json:"aggregation,omitempty"`
+	Links                *ObservationLinks    `json:"links"`
+	UnitOfMeasure        string               `json:"unit_of_measure,omitempty"`
+	UsageNotes           *[]dataset.UsageNote `json:"usage_notes,omitempty"`
+
+	// pageParameters are the query parameters of the page links, which are not the version dimensions of the same name
+	pageParameters []string
 }
 
 // Observation represents an object containing a single
 // observation and its equivalent metadata
 type Observation struct {
-	Dimensions  map[string]*DimensionObject `json:"dimensions,omitempty"`
-	Metadata    map[string]string           `json:"metadata,omitempty"`
-	Observation string                      `json:"observation"`
+	Dimensions   map[string]*DimensionObject `json:"dimensions,omitempty"`
+	Metadata     map[string]string           `json:"metadata,omitempty"`
+	Observation  string                      `json:"observation"`
+	Derived      bool                        `json:"derived,omitempty"`
+	DerivedValue *DerivedValue               `json:"derived_value,omitempty"`
+}
+
+// DerivedValue is a value calculated from an observation and the observation of another period of the same series. The
+// value is null when it cannot be calculated, in which case the status gives the reason: no_value when the observation is
+// not a number, missing_predecessor or missing_base when there is no observation for the previous or base period,
+// suppressed_predecessor or suppressed_base when that observation is not a number, such as a sparsity marker, and
+// zero_predecessor or zero_base when a percentage change or an index would divide by zero.
+type DerivedValue struct {
+	Value  *float64 `json:"value"`
+	Status string   `json:"status,omitempty"`
+}
+
+// Aggregation describes an observation derived by aggregating the observations across the dimensions that vary between
+// them, along with the number of observations it was computed from, and the number of observations excluded from it
+// because they were not numbers, such as sparsity markers
+type Aggregation struct {
+	Aggregate            string   `json:"aggregate"`
+	Dimensions           []string `json:"dimensions"`
+	InputObservations    int      `json:"input_observations"`
+	ExcludedObservations int      `json:"excluded_observations"`
 }
 
 // DimensionObject represents the unique dimension option data relevant to the observation
@@ -34,14 +74,41 @@ type DimensionObject struct {
 // ObservationLinks represents a link object to list of links relevant to the observation
 type ObservationLinks struct {
 	DatasetMetadata *dataset.Link `json:"dataset_metadata,omitempty"`
+	First           *dataset.Link `json:"first,omitempty"`
+	Last            *dataset.Link `json:"last,omitempty"`
+	Next            *dataset.Link `json:"next,omitempty"`
+	Prev            *dataset.Link `json:"prev,omitempty"`
 	Self            *dataset.Link `json:"self,omitempty"`
 	Version         *dataset.Link `json:"version,omitempty"`
 }
 
-// Option represents an object containing a list of link objects that refer to the
-// code url for that dimension option
+// PageLinks returns the pagination links that have been set
+func (l *ObservationLinks) PageLinks() []*dataset.Link {
+	var pageLinks []*dataset.Link
+	for _, link := range []*dataset.Link{l.First, l.Prev, l.Next, l.Last} {
+		if link != nil {
+			pageLinks = append(pageLinks, link)
+		}
+	}
+	return pageLinks
+}
+
+// Option represents an object containing a link object that refers to the code url for
+// the selected dimension option, or a list of them when more than one option was selected.
+// Default is set when the option was not selected, but is the default option of a dimension
+// that was left out of the query.
 type Option struct {
-	LinkObject *dataset.Link `json:"option,omitempty"`
+	LinkObject  *dataset.Link   `json:"option,omitempty"`
+	LinkObjects []*dataset.Link `json:"options,omitempty"`
+	Default     bool            `json:"default,omitempty"`
+}
+
+// Links returns all the link objects of the dimension option
+func (o Option) Links() []*dataset.Link {
+	if o.LinkObject != nil {
+		return []*dataset.Link{o.LinkObject}
+	}
+	return o.LinkObjects
 }
 
 // FilterSubmitted is the structure of each event consumed.
@@ -53,8 +120,9 @@ type FilterSubmitted struct {
 	Version    string `avro:"version"`
 }
 
-// CreateObservationsDoc manages the creation of metadata across dataset and version docs
-func CreateObservationsDoc(obsAPIURL, datasetAPIURL, rawQuery, datasetID, edition, version string, versionDoc *dataset.Version, datasetDetails dataset.DatasetDetails, observations []Observation, queryParameters map[string]string, offset, limit int) *ObservationsDoc {
+// CreateObservationsDoc manages the creation of metadata across dataset and version docs.
+// The page of observations, determined by offset and limit, is set once the observations have been read.
+func CreateObservationsDoc(obsAPIURL, datasetAPIURL, rawQuery, datasetID, edition, version string, versionDoc *dataset.Version, datasetDetails dataset.DatasetDetails, queryParameters map[string][]string, offset, limit int) *ObservationsDoc {
 	selfLink := generateSelfURL(obsAPIURL, rawQuery, datasetID, edition, version)
 	versionLink := generateVersionLink(datasetAPIURL, datasetID, edition, version)
 
@@ -69,11 +137,9 @@ func CreateObservationsDoc(obsAPIURL, datasetAPIURL, rawQuery, datasetID, editio
 			},
 			Version: versionLink,
 		},
-		Observations:      observations,
-		Offset:            offset,
-		TotalObservations: len(observations),
-		UnitOfMeasure:     datasetDetails.UnitOfMeasure,
-		UsageNotes:        datasetDetails.UsageNotes,
+		Offset:        offset,
+		UnitOfMeasure: datasetDetails.UnitOfMeasure,
+		UsageNotes:    datasetDetails.UsageNotes,
 	}
 
 	var dimensions = make(map[string]Option)
@@ -85,17 +151,31 @@ func CreateObservationsDoc(obsAPIURL, datasetAPIURL, rawQuery, datasetID, editio
 		versionDocDimensions[dim.Name] = *dim
 	}
 
+	for _, param := range pageParameters {
+		if _, found := versionDocDimensions[param]; !found {
+			observationsDoc.pageParameters = append(observationsDoc.pageParameters, param)
+		}
+	}
+
 	// add the dimension codes
-	for paramKey, paramValue := range queryParameters {
+	for paramKey, paramValues := range queryParameters {
 		dimension, found := versionDocDimensions[paramKey]
-		if found && paramValue != wildcard {
-			linkObject := &dataset.Link{
+		if !found || len(paramValues) == 0 || paramValues[0] == wildcard {
+			continue
+		}
+
+		linkObjects := make([]*dataset.Link, 0, len(paramValues))
+		for _, paramValue := range paramValues {
+			linkObjects = append(linkObjects, &dataset.Link{
 				URL: dimension.URL + "/codes/" + paramValue,
 				ID:  paramValue,
-			}
-			dimensions[paramKey] = Option{
-				LinkObject: linkObject,
-			}
+			})
+		}
+
+		if len(linkObjects) == 1 {
+			dimensions[paramKey] = Option{LinkObject: linkObjects[0]}
+		} else {
+			dimensions[paramKey] = Option{LinkObjects: linkObjects}
 		}
 	}
 	observationsDoc.Dimensions = dimensions
@@ -103,6 +183,90 @@ func CreateObservationsDoc(obsAPIURL, datasetAPIURL, rawQuery, datasetID, editio
 	return observationsDoc
 }
 
+// SetDefaultOptions marks the options of the provided dimensions as their default options
+func (doc *ObservationsDoc) SetDefaultOptions(dimensions []string) {
+	for _, dimension := range dimensions {
+		if option, found := doc.Dimensions[dimension]; found {
+			option.Default = true
+			doc.Dimensions[dimension] = option
+		}
+	}
+}
+
+// SetPage sets the number of observations in the page, out of the total number of observations found, along
+// with the links to the first, previous, next and last pages, which are relative to the self link. A capped total
+// is a lower bound of the number of observations, so there is no link to the last page.
+func (doc *ObservationsDoc) SetPage(count, totalObservations int, totalCapped bool) {
+	doc.Count = count
+	doc.TotalObservations = totalObservations
+	doc.TotalCapped = totalCapped
+
+	selfURL, rawQuery, _ := strings.Cut(doc.Links.Self.URL, "?")
+	pageLink := func(pageOffset int) *dataset.Link {
+		return &dataset.Link{
+			URL: selfURL + "?" + doc.pageQuery(rawQuery, pageOffset),
+		}
+	}
+
+	lastOffset := 0
+	if totalObservations > 0 {
+		lastOffset = ((totalObservations - 1) / doc.Limit) * doc.Limit
+	}
+
+	doc.Links.First = pageLink(0)
+	if !totalCapped {
+		doc.Links.Last = pageLink(lastOffset)
+	}
+
+	if doc.Offset > 0 {
+		prevOffset := doc.Offset - doc.Limit
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+		doc.Links.Prev = pageLink(prevOffset)
+	}
+
+	if doc.Offset+doc.Limit < totalObservations {
+		doc.Links.Next = pageLink(doc.Offset + doc.Limit)
+	}
+}
+
+// SetFilteredObservations sets the number of observations that matched the dimension options, but were filtered out by their value
+func (doc *ObservationsDoc) SetFilteredObservations(filteredObservations int) {
+	doc.FilteredObservations = &filteredObservations
+}
+
+// pageQuery replaces the offset and limit of the provided raw query, keeping the rest of the query as it was requested.
+// An offset or limit query parameter that is a version dimension selects options, so it is kept and the page is not
+// given by it.
+func (doc *ObservationsDoc) pageQuery(rawQuery string, offset int) string {
+	pageValues := map[string]int{"offset": offset, "limit": doc.Limit}
+
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		key := strings.SplitN(param, "=", 2)[0]
+		if param == "" || containsParameter(doc.pageParameters, key) {
+			continue
+		}
+		params = append(params, param)
+	}
+
+	for _, param := range doc.pageParameters {
+		params = append(params, param+"="+strconv.Itoa(pageValues[param]))
+	}
+
+	return strings.Join(params, "&")
+}
+
+func containsParameter(params []string, key string) bool {
+	for _, param := range params {
+		if param == key {
+			return true
+		}
+	}
+	return false
+}
+
 func generateSelfURL(obsAPIURL, rawQuery, datasetID, edition, version string) string {
 	return obsAPIURL + "/datasets/" + datasetID + "/editions/" +
 		edition + "/versions/" + version + "/observations?" + rawQuery
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-observation-api/apierrors"
+)
+
+// ObservationsQuery represents the body of a request to query observations, selecting
+// the same dimension options, page, format and order as the query parameters of a request
+type ObservationsQuery struct {
+	Dimensions []QueryDimension `json:"dimensions"`
+	Offset     *int             `json:"offset,omitempty"`
+	Limit      *int             `json:"limit,omitempty"`
+	Format     string           `json:"format,omitempty"`
+	Sort       string           `json:"sort,omitempty"`
+}
+
+// QueryDimension represents the options selected for a dimension, or all of them if the dimension is a wildcard
+type QueryDimension struct {
+	Name     string   `json:"name"`
+	Options  []string `json:"options,omitempty"`
+	Wildcard bool     `json:"wildcard,omitempty"`
+}
+
+// Validate checks that each dimension of the query has a name, selects options or is a wildcard, and is only selected once
+func (q *ObservationsQuery) Validate() error {
+	var missingNames bool
+	var duplicates, missingOptions []string
+	names := make(map[string]bool)
+
+	for _, dimension := range q.Dimensions {
+		name := strings.ToLower(strings.TrimSpace(dimension.Name))
+		if name == "" {
+			missingNames = true
+			continue
+		}
+
+		if !dimension.Wildcard && !dimension.hasOptions() {
+			missingOptions = append(missingOptions, dimension.Name)
+		}
+
+		if names[name] {
+			duplicates = append(duplicates, dimension.Name)
+		}
+		names[name] = true
+	}
+
+	if missingNames {
+		return errs.ErrorInvalidQueryBody("every dimension must have a name")
+	}
+
+	if len(missingOptions) > 0 {
+		return errs.ErrorInvalidQueryBody("dimensions must select options or be a wildcard: " + strings.Join(missingOptions, ", "))
+	}
+
+	if len(duplicates) > 0 {
+		return errs.ErrorInvalidQueryBody("dimensions can only be selected once: " + strings.Join(duplicates, ", "))
+	}
+
+	return nil
+}
+
+// hasOptions returns whether the dimension selects any option that is not blank
+func (d *QueryDimension) hasOptions() bool {
+	for _, option := range d.Options {
+		if strings.TrimSpace(option) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Selections returns the options selected for each dimension of the query, by the name of the dimension. Wildcard
+// dimensions are selected with the wildcard option. The options are not split on commas, unlike query parameters.
+func (q *ObservationsQuery) Selections() map[string][]string {
+	selections := make(map[string][]string, len(q.Dimensions))
+	for _, dimension := range q.Dimensions {
+		name := strings.TrimSpace(dimension.Name)
+		if dimension.Wildcard {
+			selections[name] = append(selections[name], wildcard)
+		}
+		selections[name] = append(selections[name], dimension.Options...)
+	}
+	return selections
+}
+
+// QueryValues returns the query parameters equivalent to the query, using the provided names for the
+// offset, limit, format and sort parameters. Wildcard dimensions are selected with the wildcard option, and
+// options containing commas are quoted so that they are not split on them.
+func (q *ObservationsQuery) QueryValues(offsetParameter, limitParameter, formatParameter, sortParameter string) url.Values {
+	values := url.Values{}
+	for _, dimension := range q.Dimensions {
+		name := strings.TrimSpace(dimension.Name)
+		if dimension.Wildcard {
+			values.Add(name, wildcard)
+		}
+
+		for _, option := range dimension.Options {
+			values.Add(name, quoteOption(option))
+		}
+	}
+
+	if q.Offset != nil {
+		values.Set(offsetParameter, strconv.Itoa(*q.Offset))
+	}
+
+	if q.Limit != nil {
+		values.Set(limitParameter, strconv.Itoa(*q.Limit))
+	}
+
+	if q.Format != "" {
+		values.Set(formatParameter, q.Format)
+	}
+
+	if q.Sort != "" {
+		values.Set(sortParameter, q.Sort)
+	}
+
+	return values
+}
+
+// quoteOption returns the option between double quotes if it has a comma outside double quotes, as query parameters
+// separate options by such commas
+func quoteOption(option string) string {
+	quoted := false
+	for _, r := range option {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return `"` + option + `"`
+			}
+		}
+	}
+	return option
+}
@@ -0,0 +1,460 @@
+package models
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
+
+const (
+	sdmxSenderID          = "ONS"
+	sdmxTimeDimension     = "time"
+	sdmxActionInformation = "Information"
+
+	sdmxMessageNamespace = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/message"
+	sdmxGenericNamespace = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/data/generic"
+	sdmxCommonNamespace  = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/common"
+)
+
+// SDMXJSONMessage represents a page of observations as an SDMX-JSON 1.0 data message
+type SDMXJSONMessage struct {
+	Header    *SDMXJSONHeader    `json:"header"`
+	DataSets  []*SDMXJSONDataSet `json:"dataSets"`
+	Structure *SDMXJSONStructure `json:"structure"`
+}
+
+// SDMXJSONHeader represents the header of an SDMX-JSON data message
+type SDMXJSONHeader struct {
+	ID       string          `json:"id"`
+	Test     bool            `json:"test"`
+	Prepared string          `json:"prepared"`
+	Sender   *SDMXJSONSender `json:"sender"`
+	Links    []*SDMXJSONLink `json:"links,omitempty"`
+}
+
+// SDMXJSONSender represents the organisation sending an SDMX-JSON data message
+type SDMXJSONSender struct {
+	ID string `json:"id"`
+}
+
+// SDMXJSONLink represents a link of an SDMX-JSON data message
+type SDMXJSONLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// SDMXJSONDataSet represents the series of observations of an SDMX-JSON data message. Series are keyed
+// by the positions of their values in the series dimensions, joined by ':', and observations by the
+// position of their value in the observation dimension. Each observation holds its value followed by
+// the positions of its attribute values, or null when an attribute has no value.
+type SDMXJSONDataSet struct {
+	Action string                     `json:"action"`
+	Series map[string]*SDMXJSONSeries `json:"series"`
+}
+
+// SDMXJSONSeries represents a series of an SDMX-JSON data set
+type SDMXJSONSeries struct {
+	Observations map[string][]interface{} `json:"observations"`
+}
+
+// SDMXJSONStructure represents the dimensions and attributes of an SDMX-JSON data message
+type SDMXJSONStructure struct {
+	Name        string                   `json:"name,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Links       []*SDMXJSONLink          `json:"links,omitempty"`
+	Dimensions  *SDMXJSONComponentLevels `json:"dimensions"`
+	Attributes  *SDMXJSONComponentLevels `json:"attributes"`
+}
+
+// SDMXJSONComponentLevels represents the components of an SDMX-JSON data message at each level of the message
+type SDMXJSONComponentLevels struct {
+	DataSet     []*SDMXJSONComponent `json:"dataSet"`
+	Series      []*SDMXJSONComponent `json:"series"`
+	Observation []*SDMXJSONComponent `json:"observation"`
+}
+
+// SDMXJSONComponent represents a dimension or an attribute of an SDMX-JSON data message, along with its values
+type SDMXJSONComponent struct {
+	ID          string                    `json:"id"`
+	Name        string                    `json:"name"`
+	KeyPosition *int                      `json:"keyPosition,omitempty"`
+	Role        string                    `json:"role,omitempty"`
+	Values      []*SDMXJSONComponentValue `json:"values"`
+}
+
+// SDMXJSONComponentValue represents a value of an SDMX-JSON dimension or attribute
+type SDMXJSONComponentValue struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SDMXGenericData represents a page of observations as an SDMX-ML 2.1 generic data message
+type SDMXGenericData struct {
+	XMLName          xml.Name           `xml:"message:GenericData"`
+	MessageNamespace string             `xml:"xmlns:message,attr"`
+	GenericNamespace string             `xml:"xmlns:generic,attr"`
+	CommonNamespace  string             `xml:"xmlns:common,attr"`
+	Header           *SDMXGenericHeader `xml:"message:Header"`
+	DataSet          *SDMXGenericSet    `xml:"message:DataSet"`
+}
+
+// SDMXGenericHeader represents the header of an SDMX-ML generic data message
+type SDMXGenericHeader struct {
+	ID        string                `xml:"message:ID"`
+	Test      bool                  `xml:"message:Test"`
+	Prepared  string                `xml:"message:Prepared"`
+	Sender    *SDMXGenericSender    `xml:"message:Sender"`
+	Structure *SDMXGenericStructure `xml:"message:Structure"`
+}
+
+// SDMXGenericSender represents the organisation sending an SDMX-ML generic data message
+type SDMXGenericSender struct {
+	ID string `xml:"id,attr"`
+}
+
+// SDMXGenericStructure references the structure of the data set of an SDMX-ML generic data message
+type SDMXGenericStructure struct {
+	StructureID            string                   `xml:"structureID,attr"`
+	DimensionAtObservation string                   `xml:"dimensionAtObservation,attr"`
+	Structure              *SDMXGenericStructureRef `xml:"common:Structure"`
+}
+
+// SDMXGenericStructureRef represents a reference to a structure, by its agency, id and version
+type SDMXGenericStructureRef struct {
+	Ref struct {
+		AgencyID string `xml:"agencyID,attr"`
+		ID       string `xml:"id,attr"`
+		Version  string `xml:"version,attr"`
+	} `xml:"Ref"`
+}
+
+// SDMXGenericSet represents the data set of an SDMX-ML generic data message
+type SDMXGenericSet struct {
+	StructureRef string               `xml:"structureRef,attr"`
+	Series       []*SDMXGenericSeries `xml:"generic:Series"`
+}
+
+// SDMXGenericSeries represents a series of an SDMX-ML generic data set
+type SDMXGenericSeries struct {
+	SeriesKey    []*SDMXGenericValue `xml:"generic:SeriesKey>generic:Value"`
+	Observations []*SDMXGenericObs   `xml:"generic:Obs"`
+}
+
+// SDMXGenericObs represents an observation of an SDMX-ML generic series
+type SDMXGenericObs struct {
+	ObsDimension *SDMXGenericValue      `xml:"generic:ObsDimension"`
+	ObsValue     *SDMXGenericValue      `xml:"generic:ObsValue"`
+	Attributes   *SDMXGenericAttributes `xml:"generic:Attributes,omitempty"`
+}
+
+// SDMXGenericAttributes represents the attribute values of an SDMX-ML generic observation
+type SDMXGenericAttributes struct {
+	Values []*SDMXGenericValue `xml:"generic:Value"`
+}
+
+// SDMXGenericValue represents a value of an SDMX-ML generic component, identified by the component id when needed
+type SDMXGenericValue struct {
+	ID    string `xml:"id,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+// sdmxData groups a page of observations into series, as required by the SDMX data messages. The dimension at
+// observation is the time dimension if the version has one, otherwise the last dimension of the version.
+type sdmxData struct {
+	structureID            string
+	seriesDimensions       []*sdmxDimension
+	observationDimension   *sdmxDimension
+	attributes             []string
+	attributeValues        map[string]*dimensionCodes
+	series                 []*sdmxSeries
+	selectedOptions        map[string]Option
+	selfURL                string
+	datasetTitle           string
+	datasetDescription     string
+	dimensionAtObservation string
+}
+
+type sdmxDimension struct {
+	name  string
+	label string
+	codes *dimensionCodes
+}
+
+type sdmxSeries struct {
+	key          []string
+	observations []*Observation
+}
+
+func newSDMXData(doc *ObservationsDoc, versionDoc *dataset.Version, datasetDetails *dataset.DatasetDetails, observations []Observation) *sdmxData {
+	data := &sdmxData{
+		structureID:        versionDoc.Links.Dataset.ID,
+		attributeValues:    make(map[string]*dimensionCodes),
+		selectedOptions:    doc.Dimensions,
+		selfURL:            doc.Links.Self.URL,
+		datasetTitle:       datasetDetails.Title,
+		datasetDescription: datasetDetails.Description,
+	}
+
+	var dimensions []*sdmxDimension
+	for i := range versionDoc.Dimensions {
+		versionDimension := &versionDoc.Dimensions[i]
+
+		label := versionDimension.Label
+		if label == "" {
+			label = versionDimension.Name
+		}
+
+		dimensions = append(dimensions, &sdmxDimension{
+			name:  versionDimension.Name,
+			label: label,
+			codes: getDimensionCodes(versionDimension.Name, doc.Dimensions, observations),
+		})
+	}
+
+	if len(dimensions) == 0 {
+		return data
+	}
+
+	observationIndex := len(dimensions) - 1
+	for i, dimension := range dimensions {
+		if dimension.name == sdmxTimeDimension {
+			observationIndex = i
+		}
+	}
+
+	data.observationDimension = dimensions[observationIndex]
+	data.seriesDimensions = append(dimensions[:observationIndex:observationIndex], dimensions[observationIndex+1:]...)
+	data.dimensionAtObservation = data.observationDimension.name
+
+	// the attributes are the metadata columns of the observations
+	for i := range observations {
+		for attribute := range observations[i].Metadata {
+			if _, found := data.attributeValues[attribute]; !found {
+				data.attributes = append(data.attributes, attribute)
+				data.attributeValues[attribute] = &dimensionCodes{labels: make(map[string]string), indexes: make(map[string]int)}
+			}
+		}
+	}
+	sort.Strings(data.attributes)
+
+	seriesByKey := make(map[string]*sdmxSeries)
+	for i := range observations {
+		observation := &observations[i]
+		observationCodes := getObservationCodes(observation, data.selectedOptions)
+
+		key := make([]string, len(data.seriesDimensions))
+		for j, dimension := range data.seriesDimensions {
+			key[j] = observationCodes[dimension.name]
+		}
+
+		seriesKey := strings.Join(key, ":")
+		series, found := seriesByKey[seriesKey]
+		if !found {
+			series = &sdmxSeries{key: key}
+			seriesByKey[seriesKey] = series
+			data.series = append(data.series, series)
+		}
+		series.observations = append(series.observations, observation)
+
+		for attribute, value := range observation.Metadata {
+			if value != "" {
+				data.attributeValues[attribute].add(value, "")
+			}
+		}
+	}
+
+	return data
+}
+
+// observationCode returns the code of the dimension at observation for the provided observation
+func (data *sdmxData) observationCode(observation *Observation) string {
+	return getObservationCodes(observation, data.selectedOptions)[data.observationDimension.name]
+}
+
+// CreateSDMXJSONMessage creates an SDMX-JSON data message from the observations document and its page of observations.
+// The dimensions of the version are the series dimensions, apart from the time dimension which is the dimension at
+// observation, and the metadata columns of the observations are observation level attributes. Values that are not
+// numeric, such as sparsity markers, are null.
+func CreateSDMXJSONMessage(doc *ObservationsDoc, versionDoc *dataset.Version, datasetDetails *dataset.DatasetDetails, observations []Observation, prepared time.Time) *SDMXJSONMessage {
+	data := newSDMXData(doc, versionDoc, datasetDetails, observations)
+
+	message := &SDMXJSONMessage{
+		Header: &SDMXJSONHeader{
+			ID:       sdmxMessageID(versionDoc),
+			Prepared: prepared.UTC().Format(time.RFC3339),
+			Sender:   &SDMXJSONSender{ID: sdmxSenderID},
+			Links:    []*SDMXJSONLink{{Href: data.selfURL, Rel: "request"}},
+		},
+		Structure: &SDMXJSONStructure{
+			Name:        data.datasetTitle,
+			Description: data.datasetDescription,
+			Dimensions: &SDMXJSONComponentLevels{
+				DataSet:     []*SDMXJSONComponent{},
+				Series:      []*SDMXJSONComponent{},
+				Observation: []*SDMXJSONComponent{},
+			},
+			Attributes: &SDMXJSONComponentLevels{
+				DataSet:     []*SDMXJSONComponent{},
+				Series:      []*SDMXJSONComponent{},
+				Observation: []*SDMXJSONComponent{},
+			},
+		},
+	}
+
+	if doc.Links.Version != nil {
+		message.Structure.Links = []*SDMXJSONLink{{Href: doc.Links.Version.URL, Rel: "dataflow"}}
+	}
+
+	dataSet := &SDMXJSONDataSet{
+		Action: sdmxActionInformation,
+		Series: make(map[string]*SDMXJSONSeries),
+	}
+	message.DataSets = []*SDMXJSONDataSet{dataSet}
+
+	if data.observationDimension == nil {
+		return message
+	}
+
+	for i, dimension := range data.seriesDimensions {
+		keyPosition := i
+		message.Structure.Dimensions.Series = append(message.Structure.Dimensions.Series, &SDMXJSONComponent{
+			ID:          dimension.name,
+			Name:        dimension.label,
+			KeyPosition: &keyPosition,
+			Values:      sdmxJSONComponentValues(dimension.codes),
+		})
+	}
+
+	observationKeyPosition := len(data.seriesDimensions)
+	observationDimension := &SDMXJSONComponent{
+		ID:          data.observationDimension.name,
+		Name:        data.observationDimension.label,
+		KeyPosition: &observationKeyPosition,
+		Values:      sdmxJSONComponentValues(data.observationDimension.codes),
+	}
+	if data.observationDimension.name == sdmxTimeDimension {
+		observationDimension.Role = "time"
+	}
+	message.Structure.Dimensions.Observation = append(message.Structure.Dimensions.Observation, observationDimension)
+
+	for _, attribute := range data.attributes {
+		message.Structure.Attributes.Observation = append(message.Structure.Attributes.Observation, &SDMXJSONComponent{
+			ID:     attribute,
+			Name:   attribute,
+			Values: sdmxJSONComponentValues(data.attributeValues[attribute]),
+		})
+	}
+
+	for _, series := range data.series {
+		key := make([]string, len(series.key))
+		for i, code := range series.key {
+			key[i] = strconv.Itoa(data.seriesDimensions[i].codes.indexes[code])
+		}
+
+		sdmxSeries := &SDMXJSONSeries{Observations: make(map[string][]interface{})}
+		for _, observation := range series.observations {
+			var value interface{}
+			if v, err := strconv.ParseFloat(observation.Observation, 64); err == nil {
+				value = v
+			}
+
+			observationValue := []interface{}{value}
+			for _, attribute := range data.attributes {
+				var attributeValue interface{}
+				if v := observation.Metadata[attribute]; v != "" {
+					attributeValue = data.attributeValues[attribute].indexes[v]
+				}
+				observationValue = append(observationValue, attributeValue)
+			}
+
+			observationKey := strconv.Itoa(data.observationDimension.codes.indexes[data.observationCode(observation)])
+			sdmxSeries.Observations[observationKey] = observationValue
+		}
+
+		dataSet.Series[strings.Join(key, ":")] = sdmxSeries
+	}
+
+	return message
+}
+
+// CreateSDMXGenericData creates an SDMX-ML 2.1 generic data message from the observations document and its page of
+// observations, with the same series, dimension at observation and attributes as the SDMX-JSON data message
+func CreateSDMXGenericData(doc *ObservationsDoc, versionDoc *dataset.Version, datasetDetails *dataset.DatasetDetails, observations []Observation, prepared time.Time) *SDMXGenericData {
+	data := newSDMXData(doc, versionDoc, datasetDetails, observations)
+
+	structureRef := &SDMXGenericStructureRef{}
+	structureRef.Ref.AgencyID = sdmxSenderID
+	structureRef.Ref.ID = data.structureID
+	structureRef.Ref.Version = versionDoc.Links.Version.ID
+
+	message := &SDMXGenericData{
+		MessageNamespace: sdmxMessageNamespace,
+		GenericNamespace: sdmxGenericNamespace,
+		CommonNamespace:  sdmxCommonNamespace,
+		Header: &SDMXGenericHeader{
+			ID:       sdmxMessageID(versionDoc),
+			Prepared: prepared.UTC().Format(time.RFC3339),
+			Sender:   &SDMXGenericSender{ID: sdmxSenderID},
+			Structure: &SDMXGenericStructure{
+				StructureID:            data.structureID,
+				DimensionAtObservation: data.dimensionAtObservation,
+				Structure:              structureRef,
+			},
+		},
+		DataSet: &SDMXGenericSet{
+			StructureRef: data.structureID,
+		},
+	}
+
+	for _, series := range data.series {
+		genericSeries := &SDMXGenericSeries{}
+		for i, code := range series.key {
+			genericSeries.SeriesKey = append(genericSeries.SeriesKey, &SDMXGenericValue{
+				ID:    data.seriesDimensions[i].name,
+				Value: code,
+			})
+		}
+
+		for _, observation := range series.observations {
+			obs := &SDMXGenericObs{
+				ObsDimension: &SDMXGenericValue{Value: data.observationCode(observation)},
+				ObsValue:     &SDMXGenericValue{Value: observation.Observation},
+			}
+
+			for _, attribute := range data.attributes {
+				if v := observation.Metadata[attribute]; v != "" {
+					if obs.Attributes == nil {
+						obs.Attributes = &SDMXGenericAttributes{}
+					}
+					obs.Attributes.Values = append(obs.Attributes.Values, &SDMXGenericValue{ID: attribute, Value: v})
+				}
+			}
+
+			genericSeries.Observations = append(genericSeries.Observations, obs)
+		}
+
+		message.DataSet.Series = append(message.DataSet.Series, genericSeries)
+	}
+
+	return message
+}
+
+func sdmxJSONComponentValues(codes *dimensionCodes) []*SDMXJSONComponentValue {
+	values := make([]*SDMXJSONComponentValue, 0, len(codes.codes))
+	for _, code := range codes.codes {
+		name := codes.labels[code]
+		if name == "" {
+			name = code
+		}
+		values = append(values, &SDMXJSONComponentValue{ID: code, Name: name})
+	}
+	return values
+}
+
+func sdmxMessageID(versionDoc *dataset.Version) string {
+	return strings.Join([]string{versionDoc.Links.Dataset.ID, versionDoc.Links.Edition.ID, versionDoc.Links.Version.ID}, "-")
+}
@@ -9,13 +9,15 @@ import (
 	"github.com/ONSdigital/dp-graph/v2/graph"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	dpHTTP "github.com/ONSdigital/dp-net/http"
-	"github.com/ONSdigital/dp-observation-api/api"
 	"github.com/ONSdigital/dp-observation-api/config"
+	"github.com/ONSdigital/dp-observation-api/store"
 )
 
 // ExternalServiceList holds the initialiser and initialisation state of external services.
 type ExternalServiceList struct {
 	Graph       bool
+	MemoryStore bool
+	SQLStore    bool
 	HealthCheck bool
 	HTTPServer  bool
 	Init        Initialiser
@@ -25,6 +27,8 @@ type ExternalServiceList struct {
 func NewServiceList(initialiser Initialiser) *ExternalServiceList {
 	return &ExternalServiceList{
 		Graph:       false,
+		MemoryStore: false,
+		SQLStore:    false,
 		HealthCheck: false,
 		Init:        initialiser,
 	}
@@ -40,14 +44,97 @@ func (e *ExternalServiceList) GetHTTPServer(bindAddr string, httpWriteTimeout ti
 	return s
 }
 
-// GetGraphDB creates a graphDB client and sets the Graph flag to true
-func (e *ExternalServiceList) GetGraphDB(ctx context.Context) (api.IGraph, Closer, error) {
-	graphDB, graphDBErrorConsumer, err := e.Init.DoGetGraphDB(ctx)
+// GetObservationStores creates the observation store of the default backend, along with those of the backends that the
+// observations of datasets are routed to by their ID or type, setting the flag of each backend that is created to true.
+// The consumer of the errors of the graph database is returned along with the stores if the graph database is one of them.
+func (e *ExternalServiceList) GetObservationStores(ctx context.Context, cfg *config.Config) (*store.Registry, Closer, error) {
+	defaultBackend, err := getDefaultBackend(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
-	e.Graph = true
-	return graphDB, graphDBErrorConsumer, nil
+
+	backends := []string{defaultBackend}
+	for _, routes := range []map[string]string{cfg.ObservationStoreByDataset, cfg.ObservationStoreByDatasetType} {
+		for _, backend := range routes {
+			backends = append(backends, backend)
+		}
+	}
+
+	stores := make(map[string]store.ObservationStore)
+	var graphErrorConsumer Closer
+	for _, backend := range backends {
+		if _, found := stores[backend]; found || backend == store.CantabularBackend {
+			continue
+		}
+
+		observationStore, errorConsumer, err := e.getObservationStore(ctx, cfg, backend)
+		if err != nil {
+			return nil, nil, err
+		}
+		stores[backend] = observationStore
+		if errorConsumer != nil {
+			graphErrorConsumer = errorConsumer
+		}
+	}
+
+	registry, err := store.NewRegistry(defaultBackend, stores, cfg.ObservationStoreByDataset, cfg.ObservationStoreByDatasetType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry, graphErrorConsumer, nil
+}
+
+// getDefaultBackend returns the backend of the datasets whose observations are not routed to another backend, which is
+// the SQL database of the configured driver, or an in-memory store if a directory of V4 files is configured or in dev
+// mode, or the graph database otherwise
+func getDefaultBackend(cfg *config.Config) (string, error) {
+	switch cfg.ObservationStoreDriver {
+	case "":
+	case store.SQLiteDriver, store.PostgresDriver:
+		return store.SQLBackend, nil
+	default:
+		return "", store.ErrUnsupportedSQLDriver
+	}
+
+	if cfg.ObservationStoreDir != "" || cfg.EnableDevMode {
+		return store.MemoryBackend, nil
+	}
+	return store.GraphBackend, nil
+}
+
+// getObservationStore creates the observation store of the backend: the SQL database of the configured driver, setting
+// the SQLStore flag to true, or an in-memory store loaded from the V4 files of the configured directory, or an empty one
+// if no directory is configured, setting the MemoryStore flag to true, or the graph database, setting the Graph flag to
+// true, along with the consumer of its errors
+func (e *ExternalServiceList) getObservationStore(ctx context.Context, cfg *config.Config, backend string) (store.ObservationStore, Closer, error) {
+	switch backend {
+	case store.SQLBackend:
+		if cfg.ObservationStoreDriver != store.SQLiteDriver && cfg.ObservationStoreDriver != store.PostgresDriver {
+			return nil, nil, store.ErrUnsupportedSQLDriver
+		}
+		sqlStore, err := e.Init.DoGetSQLStore(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.SQLStore = true
+		return sqlStore, nil, nil
+	case store.MemoryBackend:
+		memoryStore, err := e.Init.DoGetMemoryStore(cfg.ObservationStoreDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.MemoryStore = true
+		return memoryStore, nil, nil
+	case store.GraphBackend:
+		graphDB, graphDBErrorConsumer, err := e.Init.DoGetGraphDB(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.Graph = true
+		return store.NewGraphStore(graphDB), graphDBErrorConsumer, nil
+	default:
+		return nil, nil, store.ErrUnknownBackend
+	}
 }
 
 // GetHealthCheck creates a healthcheck with versionInfo and sets teh HealthCheck flag to true
@@ -69,7 +156,7 @@ func (e *Init) DoGetHTTPServer(bindAddr string, httpWriteTimeout time.Duration,
 }
 
 // DoGetGraphDB returns a graphDB
-func (e *Init) DoGetGraphDB(ctx context.Context) (api.IGraph, Closer, error) {
+func (e *Init) DoGetGraphDB(ctx context.Context) (store.Graph, Closer, error) {
 	graphDB, err := graph.New(ctx, graph.Subsets{Observation: true, Instance: true})
 	if err != nil {
 		return nil, nil, err
@@ -80,6 +167,33 @@ func (e *Init) DoGetGraphDB(ctx context.Context) (api.IGraph, Closer, error) {
 	return graphDB, graphErrorConsumer, nil
 }
 
+// DoGetMemoryStore returns an in-memory observation store loaded from the V4 files of the directory, or an empty one if
+// no directory is provided
+func (e *Init) DoGetMemoryStore(dir string) (store.ObservationStore, error) {
+	if dir == "" {
+		return store.NewMemoryStore(), nil
+	}
+	return store.LoadMemoryStore(dir)
+}
+
+// DoGetSQLStore returns the SQL observation store of the configured driver and data source name, into which the V4 files
+// of the configured directory are imported, if one is configured
+func (e *Init) DoGetSQLStore(ctx context.Context, cfg *config.Config) (store.ObservationStore, error) {
+	sqlStore, err := store.NewSQLStore(ctx, cfg.ObservationStoreDriver, cfg.ObservationStoreDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ObservationStoreDir != "" {
+		if err := sqlStore.ImportDir(ctx, cfg.ObservationStoreDir); err != nil {
+			sqlStore.Close(ctx)
+			return nil, err
+		}
+	}
+
+	return sqlStore, nil
+}
+
 // DoGetHealthCheck creates a healthcheck with versionInfo
 func (e *Init) DoGetHealthCheck(cfg *config.Config, buildTime, gitCommit, version string) (IHealthCheck, error) {
 	versionInfo, err := healthcheck.NewVersionInfo(buildTime, gitCommit, version)
@@ -5,9 +5,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/ONSdigital/dp-api-clients-go/v2/cantabular"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
-	"github.com/ONSdigital/dp-observation-api/api"
 	"github.com/ONSdigital/dp-observation-api/config"
+	"github.com/ONSdigital/dp-observation-api/store"
 )
 
 //go:generate moq -out mock/initialiser.go -pkg mock . Initialiser
@@ -18,7 +19,9 @@ import (
 // Initialiser defines the methods to initialise external services
 type Initialiser interface {
 	DoGetHTTPServer(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) IServer
-	DoGetGraphDB(ctx context.Context) (api.IGraph, Closer, error)
+	DoGetGraphDB(ctx context.Context) (store.Graph, Closer, error)
+	DoGetMemoryStore(dir string) (store.ObservationStore, error)
+	DoGetSQLStore(ctx context.Context, cfg *config.Config) (store.ObservationStore, error)
 	DoGetHealthCheck(cfg *config.Config, buildTime, gitCommit, version string) (IHealthCheck, error)
 }
 
@@ -42,4 +45,5 @@ type Closer interface {
 
 type CantabularClient interface {
 	Checker(context.Context, *healthcheck.CheckState) error
+	StaticDatasetQuery(ctx context.Context, req cantabular.StaticDatasetQueryRequest) (*cantabular.StaticDatasetQuery, error)
 }
@@ -5,9 +5,9 @@ package mock
 
 import (
 	"context"
-	"github.com/ONSdigital/dp-observation-api/api"
 	"github.com/ONSdigital/dp-observation-api/config"
 	"github.com/ONSdigital/dp-observation-api/service"
+	"github.com/ONSdigital/dp-observation-api/store"
 	"net/http"
 	"sync"
 	"time"
@@ -23,7 +23,7 @@ var _ service.Initialiser = &InitialiserMock{}
 //
 // 		// make and configure a mocked service.Initialiser
 // 		mockedInitialiser := &InitialiserMock{
-// 			DoGetGraphDBFunc: func(ctx context.Context) (api.IGraph, service.Closer, error) {
+// 			DoGetGraphDBFunc: func(ctx context.Context) (store.Graph, service.Closer, error) {
 // 				panic("mock out the DoGetGraphDB method")
 // 			},
 // 			DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
@@ -32,6 +32,12 @@ var _ service.Initialiser = &InitialiserMock{}
 // 			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
 // 				panic("mock out the DoGetHealthCheck method")
 // 			},
+// 			DoGetMemoryStoreFunc: func(dir string) (store.ObservationStore, error) {
+// 				panic("mock out the DoGetMemoryStore method")
+// 			},
+// 			DoGetSQLStoreFunc: func(ctx context.Context, cfg *config.Config) (store.ObservationStore, error) {
+// 				panic("mock out the DoGetSQLStore method")
+// 			},
 // 		}
 //
 // 		// use mockedInitialiser in code that requires service.Initialiser
@@ -40,7 +46,7 @@ var _ service.Initialiser = &InitialiserMock{}
 // 	}
 type InitialiserMock struct {
 	// DoGetGraphDBFunc mocks the DoGetGraphDB method.
-	DoGetGraphDBFunc func(ctx context.Context) (api.IGraph, service.Closer, error)
+	DoGetGraphDBFunc func(ctx context.Context) (store.Graph, service.Closer, error)
 
 	// DoGetHTTPServerFunc mocks the DoGetHTTPServer method.
 	DoGetHTTPServerFunc func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer
@@ -48,6 +54,12 @@ type InitialiserMock struct {
 	// DoGetHealthCheckFunc mocks the DoGetHealthCheck method.
 	DoGetHealthCheckFunc func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error)
 
+	// DoGetMemoryStoreFunc mocks the DoGetMemoryStore method.
+	DoGetMemoryStoreFunc func(dir string) (store.ObservationStore, error)
+
+	// DoGetSQLStoreFunc mocks the DoGetSQLStore method.
+	DoGetSQLStoreFunc func(ctx context.Context, cfg *config.Config) (store.ObservationStore, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// DoGetGraphDB holds details about calls to the DoGetGraphDB method.
@@ -75,14 +87,28 @@ type InitialiserMock struct {
 			// Version is the version argument value.
 			Version string
 		}
+		// DoGetMemoryStore holds details about calls to the DoGetMemoryStore method.
+		DoGetMemoryStore []struct {
+			// Dir is the dir argument value.
+			Dir string
+		}
+		// DoGetSQLStore holds details about calls to the DoGetSQLStore method.
+		DoGetSQLStore []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Cfg is the cfg argument value.
+			Cfg *config.Config
+		}
 	}
 	lockDoGetGraphDB     sync.RWMutex
 	lockDoGetHTTPServer  sync.RWMutex
 	lockDoGetHealthCheck sync.RWMutex
+	lockDoGetMemoryStore sync.RWMutex
+	lockDoGetSQLStore    sync.RWMutex
 }
 
 // DoGetGraphDB calls DoGetGraphDBFunc.
-func (mock *InitialiserMock) DoGetGraphDB(ctx context.Context) (api.IGraph, service.Closer, error) {
+func (mock *InitialiserMock) DoGetGraphDB(ctx context.Context) (store.Graph, service.Closer, error) {
 	if mock.DoGetGraphDBFunc == nil {
 		panic("InitialiserMock.DoGetGraphDBFunc: method is nil but Initialiser.DoGetGraphDB was just called")
 	}
@@ -193,3 +219,69 @@ func (mock *InitialiserMock) DoGetHealthCheckCalls() []struct {
 	mock.lockDoGetHealthCheck.RUnlock()
 	return calls
 }
+
+// DoGetMemoryStore calls DoGetMemoryStoreFunc.
+func (mock *InitialiserMock) DoGetMemoryStore(dir string) (store.ObservationStore, error) {
+	if mock.DoGetMemoryStoreFunc == nil {
+		panic("InitialiserMock.DoGetMemoryStoreFunc: method is nil but Initialiser.DoGetMemoryStore was just called")
+	}
+	callInfo := struct {
+		Dir string
+	}{
+		Dir: dir,
+	}
+	mock.lockDoGetMemoryStore.Lock()
+	mock.calls.DoGetMemoryStore = append(mock.calls.DoGetMemoryStore, callInfo)
+	mock.lockDoGetMemoryStore.Unlock()
+	return mock.DoGetMemoryStoreFunc(dir)
+}
+
+// DoGetMemoryStoreCalls gets all the calls that were made to DoGetMemoryStore.
+// Check the length with:
+//     len(mockedInitialiser.DoGetMemoryStoreCalls())
+func (mock *InitialiserMock) DoGetMemoryStoreCalls() []struct {
+	Dir string
+} {
+	var calls []struct {
+		Dir string
+	}
+	mock.lockDoGetMemoryStore.RLock()
+	calls = mock.calls.DoGetMemoryStore
+	mock.lockDoGetMemoryStore.RUnlock()
+	return calls
+}
+
+// DoGetSQLStore calls DoGetSQLStoreFunc.
+func (mock *InitialiserMock) DoGetSQLStore(ctx context.Context, cfg *config.Config) (store.ObservationStore, error) {
+	if mock.DoGetSQLStoreFunc == nil {
+		panic("InitialiserMock.DoGetSQLStoreFunc: method is nil but Initialiser.DoGetSQLStore was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Cfg *config.Config
+	}{
+		Ctx: ctx,
+		Cfg: cfg,
+	}
+	mock.lockDoGetSQLStore.Lock()
+	mock.calls.DoGetSQLStore = append(mock.calls.DoGetSQLStore, callInfo)
+	mock.lockDoGetSQLStore.Unlock()
+	return mock.DoGetSQLStoreFunc(ctx, cfg)
+}
+
+// DoGetSQLStoreCalls gets all the calls that were made to DoGetSQLStore.
+// Check the length with:
+//     len(mockedInitialiser.DoGetSQLStoreCalls())
+func (mock *InitialiserMock) DoGetSQLStoreCalls() []struct {
+	Ctx context.Context
+	Cfg *config.Config
+} {
+	var calls []struct {
+		Ctx context.Context
+		Cfg *config.Config
+	}
+	mock.lockDoGetSQLStore.RLock()
+	calls = mock.calls.DoGetSQLStore
+	mock.lockDoGetSQLStore.RUnlock()
+	return calls
+}
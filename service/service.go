@@ -6,12 +6,14 @@ import (
 	"net/url"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/v2/hierarchy"
 	"github.com/ONSdigital/dp-api-clients-go/v2/zebedee"
 	"github.com/ONSdigital/dp-authorisation/auth"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	rchttp "github.com/ONSdigital/dp-net/http"
 	"github.com/ONSdigital/dp-observation-api/api"
 	"github.com/ONSdigital/dp-observation-api/config"
+	"github.com/ONSdigital/dp-observation-api/store"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -25,7 +27,7 @@ type Service struct {
 	api                *api.API
 	serviceList        *ExternalServiceList
 	healthCheck        IHealthCheck
-	graphDB            api.IGraph
+	observationStores  *store.Registry
 	graphErrorConsumer Closer
 	cantabularClient   CantabularClient
 }
@@ -40,10 +42,11 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 	r := mux.NewRouter()
 	s := serviceList.GetHTTPServer(cfg.BindAddr, cfg.HTTPWriteTimeout, r)
 
-	// Get graphDB connection for observation store
-	graphDB, graphErrorConsumer, err := serviceList.GetGraphDB(ctx)
+	// Get observation stores, the default one being the graph database unless a SQL database or a directory of V4 files
+	// is configured, or the service is in dev mode, along with those that the observations of datasets are routed to
+	observationStores, graphErrorConsumer, err := serviceList.GetObservationStores(ctx, cfg)
 	if err != nil {
-		log.Fatal(ctx, "failed to initialise graph driver", err)
+		log.Fatal(ctx, "failed to initialise observation stores", err)
 		return nil, err
 	}
 
@@ -53,10 +56,14 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 	// Get dataset API client
 	datasetAPICli := dataset.NewAPIClient(cfg.DatasetAPIURL)
 
+	// Get hierarchy API client
+	hierarchyAPICli := hierarchy.New(cfg.HierarchyAPIURL)
+
 	cantabularClient := serviceList.GetCantabularClient(ctx, cfg)
 
 	// Get permissions for private endpoints
 	permissions := getAuthorisationHandler(ctx, *cfg)
+	instancePermissions := getInstanceAuthorisationHandler(ctx, *cfg)
 
 	// Get EnableURLRewriting feature flag
 	enableURLRewriting := cfg.EnableURLRewriting
@@ -88,7 +95,7 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 		log.Fatal(ctx, "could not instantiate healthcheck", err)
 		return nil, err
 	}
-	if err := registerCheckers(ctx, cfg, hc, graphDB, zebedeeCli, datasetAPICli, cantabularClient, cfg.EnablePrivateEndpoints); err != nil {
+	if err := registerCheckers(ctx, cfg, hc, observationStores, zebedeeCli, datasetAPICli, hierarchyAPICli, cantabularClient, cfg.EnablePrivateEndpoints); err != nil {
 		return nil, errors.Wrap(err, "unable to register checkers")
 	}
 
@@ -96,7 +103,7 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 	hc.Start(ctx)
 
 	// Setup the API
-	a := api.Setup(ctx, r, cfg, graphDB, datasetAPICli, cantabularClient, permissions, enableURLRewriting, codeListAPIURL, datasetAPIURL, observationAPIURL)
+	a := api.Setup(ctx, r, cfg, observationStores, datasetAPICli, cantabularClient, hierarchyAPICli, permissions, instancePermissions, enableURLRewriting, codeListAPIURL, datasetAPIURL, observationAPIURL)
 
 	// Run the http server in a new go-routine
 	go func() {
@@ -112,7 +119,7 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 		healthCheck:        hc,
 		server:             s,
 		serviceList:        serviceList,
-		graphDB:            graphDB,
+		observationStores:  observationStores,
 		graphErrorConsumer: graphErrorConsumer,
 		cantabularClient:   cantabularClient,
 	}, nil
@@ -148,13 +155,19 @@ func (svc *Service) Close(ctx context.Context) error {
 			log.Error(ctx, "error closing API", err)
 		}
 
-		// close graph database
-		if svc.serviceList.Graph {
-			if err := svc.graphDB.Close(ctx); err != nil {
-				log.Error(ctx, "failed to close graph db", err)
-				hasShutdownError = true
+		// close observation stores
+		if svc.serviceList.Graph || svc.serviceList.MemoryStore || svc.serviceList.SQLStore {
+			for _, backend := range svc.observationStores.Backends() {
+				observationStore, _ := svc.observationStores.Store(backend)
+				if err := observationStore.Close(ctx); err != nil {
+					log.Error(ctx, "failed to close observation store", err, log.Data{"backend": backend})
+					hasShutdownError = true
+				}
 			}
+		}
 
+		// close consumer of graph database errors
+		if svc.serviceList.Graph {
 			if err := svc.graphErrorConsumer.Close(ctx); err != nil {
 				log.Error(ctx, "failed to close graph db error consumer", err)
 				hasShutdownError = true
@@ -197,13 +210,36 @@ func getAuthorisationHandler(ctx context.Context, cfg config.Config) api.IAuthHa
 	)
 }
 
+// observationStoreChecks are the names of the checks of the observation stores of the backends
+var observationStoreChecks = map[string]string{
+	store.GraphBackend:  "Graph DB",
+	store.MemoryBackend: "In-memory observation store",
+	store.SQLBackend:    "SQL observation store",
+}
+
+// getInstanceAuthorisationHandler retrieves auth handler to authorise requests about instances rather than datasets,
+// which are authorised by the permissions of the caller, without a dataset ID
+func getInstanceAuthorisationHandler(ctx context.Context, cfg config.Config) api.IAuthHandler {
+	if !cfg.EnablePrivateEndpoints {
+		log.Info(ctx, "feature flag to not enable private endpoints, nop instance auth impl", log.Data{"feature": "ENABLE_PRIVATE_ENDPOINTS"})
+		return &auth.NopHandler{}
+	}
+
+	return auth.NewHandler(
+		auth.NewPermissionsRequestBuilder(cfg.ZebedeeURL),
+		auth.NewPermissionsClient(rchttp.NewClient()),
+		auth.DefaultPermissionsVerifier(),
+	)
+}
+
 // registerCheckers adds the Checkers to the healthcheck client, for the provided dependencies
 func registerCheckers(ctx context.Context,
 	cfg *config.Config,
 	hc IHealthCheck,
-	graphDB api.IGraph,
+	observationStores *store.Registry,
 	zebedeeCli *zebedee.Client,
 	datasetAPICli api.IDatasetClient,
+	hierarchyAPICli api.IHierarchyClient,
 	cantabularClient CantabularClient,
 	enablePrivateEndpoints bool) (err error) {
 	hasErrors := false
@@ -215,9 +251,12 @@ func registerCheckers(ctx context.Context,
 		}
 	}
 
-	if err = hc.AddCheck("Graph DB", graphDB.Checker); err != nil {
-		hasErrors = true
-		log.Error(ctx, "error adding check for graph db", err)
+	for _, backend := range observationStores.Backends() {
+		observationStore, _ := observationStores.Store(backend)
+		if err = hc.AddCheck(observationStoreChecks[backend], observationStore.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for observation store", err, log.Data{"backend": backend})
+		}
 	}
 
 	if err = hc.AddCheck("Dataset API", datasetAPICli.Checker); err != nil {
@@ -225,6 +264,11 @@ func registerCheckers(ctx context.Context,
 		log.Error(ctx, "error adding check for dataset api", err)
 	}
 
+	if err = hc.AddCheck("Hierarchy API", hierarchyAPICli.Checker); err != nil {
+		hasErrors = true
+		log.Error(ctx, "error adding check for hierarchy api", err)
+	}
+
 	cantabularChecker := cantabularClient.Checker
 	if !cfg.CantabularHealthcheckEnabled {
 		cantabularChecker = func(ctx context.Context, state *healthcheck.CheckState) error {
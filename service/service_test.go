@@ -9,11 +9,11 @@ import (
 	"time"
 
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
-	"github.com/ONSdigital/dp-observation-api/api"
-	apiMock "github.com/ONSdigital/dp-observation-api/api/mock"
 	"github.com/ONSdigital/dp-observation-api/config"
 	"github.com/ONSdigital/dp-observation-api/service"
 	serviceMock "github.com/ONSdigital/dp-observation-api/service/mock"
+	"github.com/ONSdigital/dp-observation-api/store"
+	storeMock "github.com/ONSdigital/dp-observation-api/store/mock"
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -30,7 +30,7 @@ var (
 	errHealthcheck = errors.New("healthCheck error")
 )
 
-var funcDoGetGraphDBErr = func(ctx context.Context) (api.IGraph, service.Closer, error) {
+var funcDoGetGraphDBErr = func(ctx context.Context) (store.Graph, service.Closer, error) {
 	return nil, nil, errGraph
 }
 
@@ -48,7 +48,7 @@ func TestRunPrivate(t *testing.T) {
 		So(err, ShouldBeNil)
 		cfg.EnablePrivateEndpoints = true
 
-		graphDBMock := &apiMock.IGraphMock{
+		graphDBMock := &storeMock.GraphMock{
 			CheckerFunc:   func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
 			ErrorChanFunc: func() chan error { return nil },
 		}
@@ -70,7 +70,7 @@ func TestRunPrivate(t *testing.T) {
 			},
 		}
 
-		funcDoGetGraphDBOk := func(ctx context.Context) (api.IGraph, service.Closer, error) {
+		funcDoGetGraphDBOk := func(ctx context.Context) (store.Graph, service.Closer, error) {
 			return graphDBMock, graphErrorConsumerMock, nil
 		}
 
@@ -136,10 +136,11 @@ func TestRunPrivate(t *testing.T) {
 			})
 
 			Convey("The checkers are registered and the healthcheck and http server started", func() {
-				So(len(hcMock.AddCheckCalls()), ShouldEqual, 4)
+				So(len(hcMock.AddCheckCalls()), ShouldEqual, 5)
 				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "Zebedee")
 				So(hcMock.AddCheckCalls()[1].Name, ShouldResemble, "Graph DB")
 				So(hcMock.AddCheckCalls()[2].Name, ShouldResemble, "Dataset API")
+				So(hcMock.AddCheckCalls()[3].Name, ShouldResemble, "Hierarchy API")
 				So(len(initMock.DoGetHTTPServerCalls()), ShouldEqual, 1)
 				So(initMock.DoGetHTTPServerCalls()[0].BindAddr, ShouldEqual, ":24500")
 				So(len(hcMock.StartCalls()), ShouldEqual, 1)
@@ -172,11 +173,12 @@ func TestRunPrivate(t *testing.T) {
 				So(err.Error(), ShouldResemble, fmt.Sprintf("unable to register checkers: %s", errAddheckFail.Error()))
 				So(svcList.Graph, ShouldBeTrue)
 				So(svcList.HealthCheck, ShouldBeTrue)
-				So(len(hcMockAddFail.AddCheckCalls()), ShouldEqual, 4)
+				So(len(hcMockAddFail.AddCheckCalls()), ShouldEqual, 5)
 				So(hcMockAddFail.AddCheckCalls()[0].Name, ShouldResemble, "Zebedee")
 				So(hcMockAddFail.AddCheckCalls()[1].Name, ShouldResemble, "Graph DB")
 				So(hcMockAddFail.AddCheckCalls()[2].Name, ShouldResemble, "Dataset API")
-				So(hcMockAddFail.AddCheckCalls()[3].Name, ShouldResemble, "cantabular client")
+				So(hcMockAddFail.AddCheckCalls()[3].Name, ShouldResemble, "Hierarchy API")
+				So(hcMockAddFail.AddCheckCalls()[4].Name, ShouldResemble, "cantabular client")
 			})
 		})
 	})
@@ -188,7 +190,7 @@ func TestRunPublic(t *testing.T) {
 		So(err, ShouldBeNil)
 		cfg.EnablePrivateEndpoints = false
 
-		graphDBMock := &apiMock.IGraphMock{
+		graphDBMock := &storeMock.GraphMock{
 			CheckerFunc:   func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
 			ErrorChanFunc: func() chan error { return nil },
 		}
@@ -210,7 +212,7 @@ func TestRunPublic(t *testing.T) {
 			},
 		}
 
-		funcDoGetGraphDBOk := func(ctx context.Context) (api.IGraph, service.Closer, error) {
+		funcDoGetGraphDBOk := func(ctx context.Context) (store.Graph, service.Closer, error) {
 			return graphDBMock, graphErrorConsumerMock, nil
 		}
 
@@ -276,9 +278,10 @@ func TestRunPublic(t *testing.T) {
 			})
 
 			Convey("The checkers are registered and the healthcheck and http server started", func() {
-				So(len(hcMock.AddCheckCalls()), ShouldEqual, 3)
+				So(len(hcMock.AddCheckCalls()), ShouldEqual, 4)
 				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "Graph DB")
 				So(hcMock.AddCheckCalls()[1].Name, ShouldResemble, "Dataset API")
+				So(hcMock.AddCheckCalls()[2].Name, ShouldResemble, "Hierarchy API")
 				So(len(initMock.DoGetHTTPServerCalls()), ShouldEqual, 1)
 				So(initMock.DoGetHTTPServerCalls()[0].BindAddr, ShouldEqual, ":24500")
 				So(len(hcMock.StartCalls()), ShouldEqual, 1)
@@ -311,9 +314,304 @@ func TestRunPublic(t *testing.T) {
 				So(err.Error(), ShouldResemble, fmt.Sprintf("unable to register checkers: %s", errAddheckFail.Error()))
 				So(svcList.Graph, ShouldBeTrue)
 				So(svcList.HealthCheck, ShouldBeTrue)
-				So(len(hcMockAddFail.AddCheckCalls()), ShouldEqual, 3)
+				So(len(hcMockAddFail.AddCheckCalls()), ShouldEqual, 4)
 				So(hcMockAddFail.AddCheckCalls()[0].Name, ShouldResemble, "Graph DB")
 				So(hcMockAddFail.AddCheckCalls()[1].Name, ShouldResemble, "Dataset API")
+				So(hcMockAddFail.AddCheckCalls()[2].Name, ShouldResemble, "Hierarchy API")
+			})
+		})
+	})
+}
+
+func TestRunWithMemoryStore(t *testing.T) {
+	Convey("Having a set of mocked dependencies and a configured observation store directory", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.ObservationStoreDir = "testdata"
+
+		hcMock := &serviceMock.IHealthCheckMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
+			StartFunc:    func(ctx context.Context) {},
+		}
+
+		serverWg := &sync.WaitGroup{}
+		serverMock := &serviceMock.IServerMock{
+			ListenAndServeFunc: func() error {
+				serverWg.Done()
+				return nil
+			},
+		}
+
+		initMock := &serviceMock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
+				return serverMock
+			},
+			DoGetMemoryStoreFunc: func(dir string) (store.ObservationStore, error) {
+				return store.NewMemoryStore(), nil
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
+				return hcMock, nil
+			},
+		}
+
+		Convey("When the service is run", func() {
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			serverWg.Add(1)
+			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+			serverWg.Wait() // Wait for HTTP server go-routine to finish
+
+			Convey("Then the in-memory store is loaded from the directory instead of connecting to the graph database", func() {
+				So(len(initMock.DoGetMemoryStoreCalls()), ShouldEqual, 1)
+				So(initMock.DoGetMemoryStoreCalls()[0].Dir, ShouldEqual, "testdata")
+				So(svcList.MemoryStore, ShouldBeTrue)
+				So(svcList.Graph, ShouldBeFalse)
+			})
+
+			Convey("Then the checker of the in-memory store is registered", func() {
+				So(len(hcMock.AddCheckCalls()), ShouldEqual, 4)
+				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "In-memory observation store")
+			})
+
+			Convey("Then the service can be closed without a graph database error consumer", func() {
+				hcMock.StopFunc = func() {}
+				serverMock.ShutdownFunc = func(ctx context.Context) error { return nil }
+				So(svc.Close(context.Background()), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestRunInDevMode(t *testing.T) {
+	Convey("Having a set of mocked dependencies and dev mode enabled without a configured observation store", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		cfg.EnableDevMode = true
+
+		hcMock := &serviceMock.IHealthCheckMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
+			StartFunc:    func(ctx context.Context) {},
+		}
+
+		serverWg := &sync.WaitGroup{}
+		serverMock := &serviceMock.IServerMock{
+			ListenAndServeFunc: func() error {
+				serverWg.Done()
+				return nil
+			},
+		}
+
+		initMock := &serviceMock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
+				return serverMock
+			},
+			DoGetMemoryStoreFunc: func(dir string) (store.ObservationStore, error) {
+				return store.NewMemoryStore(), nil
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
+				return hcMock, nil
+			},
+		}
+
+		Convey("When the service is run", func() {
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			serverWg.Add(1)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+			serverWg.Wait() // Wait for HTTP server go-routine to finish
+
+			Convey("Then an empty in-memory store is created instead of connecting to the graph database", func() {
+				So(len(initMock.DoGetMemoryStoreCalls()), ShouldEqual, 1)
+				So(initMock.DoGetMemoryStoreCalls()[0].Dir, ShouldEqual, "")
+				So(svcList.MemoryStore, ShouldBeTrue)
+				So(svcList.Graph, ShouldBeFalse)
+			})
+
+			Convey("Then the checker of the in-memory store is registered", func() {
+				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "In-memory observation store")
+			})
+		})
+	})
+}
+
+func TestRunWithSQLStore(t *testing.T) {
+	Convey("Having a set of mocked dependencies", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+
+		hcMock := &serviceMock.IHealthCheckMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
+			StartFunc:    func(ctx context.Context) {},
+		}
+
+		serverWg := &sync.WaitGroup{}
+		serverMock := &serviceMock.IServerMock{
+			ListenAndServeFunc: func() error {
+				serverWg.Done()
+				return nil
+			},
+		}
+
+		initMock := &serviceMock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
+				return serverMock
+			},
+			DoGetSQLStoreFunc: func(ctx context.Context, cfg *config.Config) (store.ObservationStore, error) {
+				return store.NewMemoryStore(), nil
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
+				return hcMock, nil
+			},
+		}
+
+		Convey("Given that a SQL observation store driver is configured", func() {
+			cfg.ObservationStoreDriver = store.SQLiteDriver
+			cfg.ObservationStoreDSN = "observations.db"
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			serverWg.Add(1)
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+			serverWg.Wait() // Wait for HTTP server go-routine to finish
+
+			Convey("Then the SQL store is opened instead of connecting to the graph database", func() {
+				So(len(initMock.DoGetSQLStoreCalls()), ShouldEqual, 1)
+				So(initMock.DoGetSQLStoreCalls()[0].Cfg.ObservationStoreDSN, ShouldEqual, "observations.db")
+				So(svcList.SQLStore, ShouldBeTrue)
+				So(svcList.Graph, ShouldBeFalse)
+				So(svcList.MemoryStore, ShouldBeFalse)
+			})
+
+			Convey("Then the checker of the SQL store is registered", func() {
+				So(len(hcMock.AddCheckCalls()), ShouldEqual, 4)
+				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "SQL observation store")
+			})
+		})
+
+		Convey("Given that an unsupported observation store driver is configured", func() {
+			cfg.ObservationStoreDriver = "mysql"
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails without opening any observation store", func() {
+				So(err, ShouldEqual, store.ErrUnsupportedSQLDriver)
+				So(initMock.DoGetSQLStoreCalls(), ShouldBeEmpty)
+				So(svcList.SQLStore, ShouldBeFalse)
+				So(svcList.Graph, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestRunWithRoutedObservationStores(t *testing.T) {
+	Convey("Having a set of mocked dependencies", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+
+		graphDBMock := &storeMock.GraphMock{
+			CloseFunc: func(ctx context.Context) error { return nil },
+		}
+
+		graphErrorConsumerMock := &serviceMock.CloserMock{
+			CloseFunc: func(ctx context.Context) error { return nil },
+		}
+
+		hcMock := &serviceMock.IHealthCheckMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
+			StartFunc:    func(ctx context.Context) {},
+		}
+
+		serverWg := &sync.WaitGroup{}
+		serverMock := &serviceMock.IServerMock{
+			ListenAndServeFunc: func() error {
+				serverWg.Done()
+				return nil
+			},
+		}
+
+		initMock := &serviceMock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
+				return serverMock
+			},
+			DoGetGraphDBFunc: func(ctx context.Context) (store.Graph, service.Closer, error) {
+				return graphDBMock, graphErrorConsumerMock, nil
+			},
+			DoGetMemoryStoreFunc: func(dir string) (store.ObservationStore, error) {
+				return store.NewMemoryStore(), nil
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
+				return hcMock, nil
+			},
+		}
+
+		Convey("Given that the observations of datasets are routed to other backends by their ID and type", func() {
+			cfg.ObservationStoreByDataset = map[string]string{"cpih01": store.MemoryBackend}
+			cfg.ObservationStoreByDatasetType = map[string]string{"filterable": store.MemoryBackend, "cantabular_table": store.CantabularBackend}
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			serverWg.Add(1)
+			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+			serverWg.Wait() // Wait for HTTP server go-routine to finish
+
+			Convey("Then the store of the default backend and each routed backend is created once", func() {
+				So(initMock.DoGetGraphDBCalls(), ShouldHaveLength, 1)
+				So(initMock.DoGetMemoryStoreCalls(), ShouldHaveLength, 1)
+				So(svcList.Graph, ShouldBeTrue)
+				So(svcList.MemoryStore, ShouldBeTrue)
+				So(svcList.SQLStore, ShouldBeFalse)
+			})
+
+			Convey("Then the checker of each observation store is registered", func() {
+				So(len(hcMock.AddCheckCalls()), ShouldEqual, 5)
+				So(hcMock.AddCheckCalls()[0].Name, ShouldResemble, "Graph DB")
+				So(hcMock.AddCheckCalls()[1].Name, ShouldResemble, "In-memory observation store")
+			})
+
+			Convey("Then every observation store is closed when the service is closed", func() {
+				hcMock.StopFunc = func() {}
+				serverMock.ShutdownFunc = func(ctx context.Context) error { return nil }
+				So(svc.Close(context.Background()), ShouldBeNil)
+				So(graphDBMock.CloseCalls(), ShouldHaveLength, 1)
+				So(graphErrorConsumerMock.CloseCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("Given that the observations of a dataset type are routed to the SQL backend without a configured driver", func() {
+			cfg.ObservationStoreByDatasetType = map[string]string{"filterable": store.SQLBackend}
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails without opening the SQL store", func() {
+				So(err, ShouldEqual, store.ErrUnsupportedSQLDriver)
+				So(initMock.DoGetSQLStoreCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("Given that the observations of a dataset type are routed to an unknown backend", func() {
+			cfg.ObservationStoreByDatasetType = map[string]string{"filterable": "mongodb"}
+
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+
+			_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+
+			Convey("Then service Run fails", func() {
+				So(err, ShouldEqual, store.ErrUnknownBackend)
 			})
 		})
 	})
@@ -328,7 +626,7 @@ func TestClose(t *testing.T) {
 		serverStopped := false
 
 		// graphDB Close will fail if healthcheck and http server
-		graphDBMock := &apiMock.IGraphMock{
+		graphDBMock := &storeMock.GraphMock{
 			CheckerFunc:   func(ctx context.Context, state *healthcheck.CheckState) error { return nil },
 			ErrorChanFunc: func() chan error { return nil },
 			CloseFunc: func(ctx context.Context) error {
@@ -367,7 +665,7 @@ func TestClose(t *testing.T) {
 				DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
 					return serverMock
 				},
-				DoGetGraphDBFunc: func(ctx context.Context) (api.IGraph, service.Closer, error) {
+				DoGetGraphDBFunc: func(ctx context.Context) (store.Graph, service.Closer, error) {
 					return graphDBMock, graphErrorConsumerMock, nil
 				},
 				DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
@@ -400,7 +698,7 @@ func TestClose(t *testing.T) {
 				DoGetHTTPServerFunc: func(bindAddr string, httpWriteTimeout time.Duration, router http.Handler) service.IServer {
 					return failingserverMock
 				},
-				DoGetGraphDBFunc: func(ctx context.Context) (api.IGraph, service.Closer, error) {
+				DoGetGraphDBFunc: func(ctx context.Context) (store.Graph, service.Closer, error) {
 					return graphDBMock, graphErrorConsumerMock, nil
 				},
 				DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.IHealthCheck, error) {
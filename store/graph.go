@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/ONSdigital/dp-graph/v2/graph/driver"
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// graphNoResults is the message of the error returned by the graph database when the filters match no observations
+const graphNoResults = "the filter options created no results"
+
+// Graph defines the methods of the graph database that the observations are streamed from, as rows of V4 files
+type Graph interface {
+	driver.Driver
+	StreamCSVRows(ctx context.Context, instanceID, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error)
+	ErrorChan() chan error
+}
+
+// GraphStore is the observation store of the observations held by the graph database, such as Neo4j or Neptune
+type GraphStore struct {
+	graph Graph
+}
+
+// NewGraphStore returns the observation store of the observations held by the graph database
+func NewGraphStore(graph Graph) *GraphStore {
+	return &GraphStore{graph: graph}
+}
+
+// StreamObservations streams the observations of the instance that match the filters from the graph database. The
+// header row of the V4 file streamed by the graph database is read first, so that the reader can describe the rows.
+func (s *GraphStore) StreamObservations(ctx context.Context, instanceID string, filters *observation.DimensionFilters, limit *int) (RowReader, error) {
+	reader, err := s.graph.StreamCSVRows(ctx, instanceID, "", filters, limit)
+	if err != nil {
+		return nil, graphError(err)
+	}
+
+	line, err := reader.Read()
+	if err != nil {
+		reader.Close(ctx)
+		return nil, graphError(err)
+	}
+
+	columns, err := splitV4Line(line)
+	if err != nil {
+		reader.Close(ctx)
+		return nil, err
+	}
+
+	header, err := ParseV4Header(columns)
+	if err != nil {
+		reader.Close(ctx)
+		return nil, err
+	}
+
+	return &graphRowReader{reader: reader, header: header}, nil
+}
+
+// Checker checks the health of the graph database
+func (s *GraphStore) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	return s.graph.Checker(ctx, state)
+}
+
+// Close closes the connection to the graph database
+func (s *GraphStore) Close(ctx context.Context) error {
+	return s.graph.Close(ctx)
+}
+
+// graphRowReader reads the rows of the V4 file streamed by the graph database after its header row
+type graphRowReader struct {
+	reader observation.StreamRowReader
+	header Header
+}
+
+// Header returns the header of the rows
+func (r *graphRowReader) Header() Header {
+	return r.header
+}
+
+// Read returns the next row, or io.EOF when there are no more rows
+func (r *graphRowReader) Read() (*Row, error) {
+	line, err := r.reader.Read()
+	if err != nil {
+		return nil, graphError(err)
+	}
+
+	columns, err := splitV4Line(line)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseV4Row(r.header, columns)
+}
+
+// Close closes the stream of rows from the graph database
+func (r *graphRowReader) Close(ctx context.Context) error {
+	return r.reader.Close(ctx)
+}
+
+// graphError returns ErrNotFound for the error the graph database returns when the filters match no observations,
+// which can only be told apart by its message, or the provided error otherwise
+func graphError(err error) error {
+	if err != io.EOF && strings.Contains(err.Error(), graphNoResults) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-graph/v2/observation/observationtest"
+	"github.com/ONSdigital/dp-observation-api/store"
+	storeMock "github.com/ONSdigital/dp-observation-api/store/mock"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var ctx = context.Background()
+
+// newStreamRowReader returns a reader of the lines of a V4 file streamed by the graph database, which returns the
+// provided error once every line has been read
+func newStreamRowReader(err error, lines ...string) *observationtest.StreamRowReaderMock {
+	return &observationtest.StreamRowReaderMock{
+		ReadFunc: func() (string, error) {
+			if len(lines) == 0 {
+				return "", err
+			}
+			line := lines[0]
+			lines = lines[1:]
+			return line, nil
+		},
+		CloseFunc: func(context.Context) error {
+			return nil
+		},
+	}
+}
+
+func TestGraphStore(t *testing.T) {
+	Convey("Given a graph database streaming a V4 file", t, func() {
+		rowReader := newStreamRowReader(io.EOF,
+			"V4_1,data_marking,time,time,uk-only,geography",
+			"146.3,p,Month,Aug-16,K02000001,United Kingdom",
+			`112.1,,Month,Sep-16,K02000001,"United Kingdom, incl. NI"`,
+		)
+
+		graphMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				return rowReader, nil
+			},
+		}
+
+		filters := &observation.DimensionFilters{
+			Dimensions: []*observation.Dimension{{Name: "geography", Options: []string{"K02000001"}}},
+		}
+		limit := 10
+
+		Convey("When the observations are streamed from the graph store", func() {
+			reader, err := store.NewGraphStore(graphMock).StreamObservations(ctx, "instance-1", filters, &limit)
+			So(err, ShouldBeNil)
+
+			Convey("Then the query is passed to the graph database", func() {
+				So(graphMock.StreamCSVRowsCalls(), ShouldHaveLength, 1)
+				So(graphMock.StreamCSVRowsCalls()[0].InstanceID, ShouldEqual, "instance-1")
+				So(graphMock.StreamCSVRowsCalls()[0].Filters, ShouldEqual, filters)
+				So(*graphMock.StreamCSVRowsCalls()[0].Limit, ShouldEqual, 10)
+			})
+
+			Convey("Then the header describes the metadata and dimensions of the V4 file", func() {
+				So(reader.Header(), ShouldResemble, store.Header{
+					Metadata: []string{"data_marking"},
+					Dimensions: []store.Dimension{
+						{CodeList: "time", Name: "time"},
+						{CodeList: "uk-only", Name: "geography"},
+					},
+				})
+				So(reader.Header().V4Columns(), ShouldResemble, []string{"v4_1", "data_marking", "time", "time", "uk-only", "geography"})
+			})
+
+			Convey("Then the rows are read as typed rows until the end of the stream", func() {
+				row, err := reader.Read()
+				So(err, ShouldBeNil)
+				So(row, ShouldResemble, &store.Row{
+					Observation: "146.3",
+					Metadata:    []string{"p"},
+					Options:     []store.Option{{Code: "Month", Label: "Aug-16"}, {Code: "K02000001", Label: "United Kingdom"}},
+				})
+
+				row, err = reader.Read()
+				So(err, ShouldBeNil)
+				So(row.V4Columns(), ShouldResemble, []string{"112.1", "", "Month", "Sep-16", "K02000001", "United Kingdom, incl. NI"})
+
+				_, err = reader.Read()
+				So(err, ShouldEqual, io.EOF)
+
+				So(reader.Close(ctx), ShouldBeNil)
+				So(rowReader.CloseCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+
+	Convey("Given a graph database whose filters created no results", t, func() {
+		errNoResults := errors.New("the filter options created no results")
+
+		Convey("When the error is returned as the header row is read", func() {
+			graphMock := &storeMock.GraphMock{
+				StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+					return newStreamRowReader(errNoResults), nil
+				},
+			}
+
+			_, err := store.NewGraphStore(graphMock).StreamObservations(ctx, "instance-1", nil, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When the error is returned as the rows are read", func() {
+			graphMock := &storeMock.GraphMock{
+				StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+					return newStreamRowReader(errNoResults, "v4_0,time,time"), nil
+				},
+			}
+
+			reader, err := store.NewGraphStore(graphMock).StreamObservations(ctx, "instance-1", nil, nil)
+			So(err, ShouldBeNil)
+
+			_, err = reader.Read()
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+	})
+
+	Convey("Given a graph database streaming a file that is not a V4 file", t, func() {
+		rowReader := newStreamRowReader(io.EOF, "observation,time")
+		graphMock := &storeMock.GraphMock{
+			StreamCSVRowsFunc: func(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+				return rowReader, nil
+			},
+		}
+
+		Convey("When the observations are streamed from the graph store", func() {
+			_, err := store.NewGraphStore(graphMock).StreamObservations(ctx, "instance-1", nil, nil)
+
+			Convey("Then an invalid header error is returned and the stream is closed", func() {
+				So(err, ShouldEqual, store.ErrInvalidV4Header)
+				So(rowReader.CloseCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// MemoryStore is an observation store holding the observations of instances in memory, as loaded from V4 files. It
+// needs no graph database, so that the service can be run locally and in component tests.
+type MemoryStore struct {
+	mutex     sync.RWMutex
+	instances map[string]*v4File
+}
+
+// v4File is the header and rows of a V4 file
+type v4File struct {
+	header Header
+	rows   []*Row
+}
+
+// NewMemoryStore returns an empty in-memory observation store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{instances: make(map[string]*v4File)}
+}
+
+// LoadMemoryStore returns an in-memory observation store holding the observations of the V4 files in the directory.
+// The observations of each file are those of the instance whose ID is the name of the file, without its extension.
+func LoadMemoryStore(dir string) (*MemoryStore, error) {
+	s := NewMemoryStore()
+	if err := readV4Files(dir, s.Load); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load reads the V4 file of the instance, replacing any observations the store held for it
+func (s *MemoryStore) Load(instanceID string, r io.Reader) error {
+	reader, err := newV4Reader(r)
+	if err != nil {
+		return err
+	}
+
+	file := &v4File{header: reader.header}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		file.rows = append(file.rows, row)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.instances[instanceID] = file
+
+	return nil
+}
+
+// Import reads the V4 file of the instance into the store, as Load does
+func (s *MemoryStore) Import(_ context.Context, instanceID string, r io.Reader) error {
+	return s.Load(instanceID, r)
+}
+
+// StreamObservations returns a reader of the observations of the instance that match the filters, in the order of its
+// V4 file. The rows match the filters if they have one of the options of each filtered dimension, where the options of
+// a dimension are matched by their codes and a dimension without options is not filtered.
+func (s *MemoryStore) StreamObservations(_ context.Context, instanceID string, filters *observation.DimensionFilters, limit *int) (RowReader, error) {
+	s.mutex.RLock()
+	file, found := s.instances[instanceID]
+	s.mutex.RUnlock()
+
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	matches, err := file.matcher(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*Row
+	for _, row := range file.rows {
+		if limit != nil && len(rows) >= *limit {
+			break
+		}
+		if matches(row) {
+			rows = append(rows, row)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &memoryRowReader{header: file.header, rows: rows}, nil
+}
+
+// matcher returns a function reporting whether a row matches the filters. ErrNotFound is returned if a filtered
+// dimension is not a dimension of the file, in which case no row can match.
+func (f *v4File) matcher(filters *observation.DimensionFilters) (func(*Row) bool, error) {
+	options := make(map[int]map[string]struct{})
+	if filters != nil {
+		for _, filter := range filters.Dimensions {
+			if filter == nil || len(filter.Options) == 0 {
+				continue
+			}
+
+			column := f.header.DimensionIndex(filter.Name)
+			if column < 0 {
+				return nil, ErrNotFound
+			}
+
+			codes := make(map[string]struct{}, len(filter.Options))
+			for _, option := range filter.Options {
+				codes[option] = struct{}{}
+			}
+			options[column] = codes
+		}
+	}
+
+	return func(row *Row) bool {
+		for column, codes := range options {
+			if _, found := codes[row.Options[column].Code]; !found {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// Checker reports the in-memory store as healthy, as it has no dependency
+func (s *MemoryStore) Checker(_ context.Context, state *healthcheck.CheckState) error {
+	s.mutex.RLock()
+	instances := len(s.instances)
+	s.mutex.RUnlock()
+
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("in-memory observation store holding %d instances", instances), http.StatusOK)
+}
+
+// Close does nothing, as the in-memory store holds no connection
+func (s *MemoryStore) Close(context.Context) error {
+	return nil
+}
+
+// memoryRowReader reads the rows of observations that matched a query to the in-memory store
+type memoryRowReader struct {
+	header Header
+	rows   []*Row
+}
+
+// Header returns the header of the rows
+func (r *memoryRowReader) Header() Header {
+	return r.header
+}
+
+// Read returns the next row, or io.EOF when there are no more rows
+func (r *memoryRowReader) Read() (*Row, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+// Close does nothing, as the rows are held in memory
+func (r *memoryRowReader) Close(context.Context) error {
+	return nil
+}
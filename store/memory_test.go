@@ -0,0 +1,170 @@
+package store_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-observation-api/store"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testV4File = `v4_1,data_marking,time,time,uk-only,geography,cpih1dim1aggid,aggregate
+146.3,,Month,Aug-16,K02000001,United Kingdom,cpih1dim1A0,CPIH (overall index)
+112.1,,Month,Aug-16,K02000001,United Kingdom,cpih1dim1G10100,01.1 Food
+,x,Month,Aug-16,E92000001,England,cpih1dim1A0,CPIH (overall index)
+147.0,,Month,Sep-16,K02000001,United Kingdom,cpih1dim1A0,CPIH (overall index)
+`
+
+// readRows reads every row from the reader
+func readRows(reader store.RowReader) []*store.Row {
+	var rows []*store.Row
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows
+		}
+		So(err, ShouldBeNil)
+		rows = append(rows, row)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	Convey("Given an in-memory store loaded with the V4 file of an instance", t, func() {
+		memoryStore := store.NewMemoryStore()
+		So(memoryStore.Load("instance-1", strings.NewReader(testV4File)), ShouldBeNil)
+
+		Convey("When every observation of the instance is streamed", func() {
+			reader, err := memoryStore.StreamObservations(ctx, "instance-1", &observation.DimensionFilters{}, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the header describes the metadata and dimensions of the V4 file", func() {
+				So(reader.Header().V4Columns(), ShouldResemble, strings.Split(strings.SplitN(testV4File, "\n", 2)[0], ","))
+			})
+
+			Convey("Then every row is returned in the order of the V4 file", func() {
+				rows := readRows(reader)
+				So(rows, ShouldHaveLength, 4)
+				So(rows[2], ShouldResemble, &store.Row{
+					Observation: "",
+					Metadata:    []string{"x"},
+					Options: []store.Option{
+						{Code: "Month", Label: "Aug-16"},
+						{Code: "E92000001", Label: "England"},
+						{Code: "cpih1dim1A0", Label: "CPIH (overall index)"},
+					},
+				})
+			})
+		})
+
+		Convey("When the observations are filtered by the codes of the options of some dimensions", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{
+					{Name: "Geography", Options: []string{"K02000001"}},
+					{Name: "aggregate", Options: []string{"cpih1dim1A0", "cpih1dim1G10100"}},
+					{Name: "time"},
+				},
+			}
+
+			reader, err := memoryStore.StreamObservations(ctx, "instance-1", filters, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then only the rows with one of the options of each filtered dimension are returned", func() {
+				rows := readRows(reader)
+				So(rows, ShouldHaveLength, 3)
+				So(rows[0].Observation, ShouldEqual, "146.3")
+				So(rows[1].Observation, ShouldEqual, "112.1")
+				So(rows[2].Observation, ShouldEqual, "147.0")
+			})
+		})
+
+		Convey("When the observations are streamed with a limit", func() {
+			limit := 2
+			reader, err := memoryStore.StreamObservations(ctx, "instance-1", nil, &limit)
+			So(err, ShouldBeNil)
+
+			Convey("Then no more rows than the limit are returned", func() {
+				So(readRows(reader), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When the filters match no observations", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{{Name: "geography", Options: []string{"W92000004"}}},
+			}
+			_, err := memoryStore.StreamObservations(ctx, "instance-1", filters, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When a dimension that the instance does not have is filtered", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{{Name: "age", Options: []string{"30"}}},
+			}
+			_, err := memoryStore.StreamObservations(ctx, "instance-1", filters, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When the observations of an instance that was not loaded are streamed", func() {
+			_, err := memoryStore.StreamObservations(ctx, "instance-2", nil, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When the health of the store is checked", func() {
+			state := healthcheck.NewCheckState("In-memory observation store")
+			So(memoryStore.Checker(ctx, state), ShouldBeNil)
+
+			Convey("Then the store is healthy", func() {
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.StatusCode(), ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+
+	Convey("Given a file that is not a V4 file", t, func() {
+		err := store.NewMemoryStore().Load("instance-1", strings.NewReader("observation,time\n146.3,Aug-16\n"))
+
+		Convey("Then it cannot be loaded", func() {
+			So(err, ShouldEqual, store.ErrInvalidV4Header)
+		})
+	})
+
+	Convey("Given a V4 file with a row missing some of its columns", t, func() {
+		err := store.NewMemoryStore().Load("instance-1", strings.NewReader("v4_0,time,time\n146.3,Month\n"))
+
+		Convey("Then it cannot be loaded", func() {
+			So(err, ShouldEqual, store.ErrInvalidV4Row)
+		})
+	})
+
+	Convey("Given a directory of V4 files named by the IDs of their instances", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "instance-1.csv"), []byte(testV4File), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a V4 file"), 0o600), ShouldBeNil)
+
+		Convey("When the in-memory store is loaded from the directory", func() {
+			memoryStore, err := store.LoadMemoryStore(dir)
+			So(err, ShouldBeNil)
+
+			Convey("Then the observations of each instance can be streamed", func() {
+				reader, err := memoryStore.StreamObservations(ctx, "instance-1", nil, nil)
+				So(err, ShouldBeNil)
+				So(readRows(reader), ShouldHaveLength, 4)
+			})
+		})
+	})
+}
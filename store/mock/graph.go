@@ -7,20 +7,20 @@ import (
 	"context"
 	"github.com/ONSdigital/dp-graph/v2/observation"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
-	"github.com/ONSdigital/dp-observation-api/api"
+	"github.com/ONSdigital/dp-observation-api/store"
 	"sync"
 )
 
-// Ensure, that IGraphMock does implement api.IGraph.
+// Ensure, that GraphMock does implement store.Graph.
 // If this is not the case, regenerate this file with moq.
-var _ api.IGraph = &IGraphMock{}
+var _ store.Graph = &GraphMock{}
 
-// IGraphMock is a mock implementation of api.IGraph.
+// GraphMock is a mock implementation of store.Graph.
 //
-// 	func TestSomethingThatUsesIGraph(t *testing.T) {
+// 	func TestSomethingThatUsesGraph(t *testing.T) {
 //
-// 		// make and configure a mocked api.IGraph
-// 		mockedIGraph := &IGraphMock{
+// 		// make and configure a mocked store.Graph
+// 		mockedGraph := &GraphMock{
 // 			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
 // 				panic("mock out the Checker method")
 // 			},
@@ -38,11 +38,11 @@ var _ api.IGraph = &IGraphMock{}
 // 			},
 // 		}
 //
-// 		// use mockedIGraph in code that requires api.IGraph
+// 		// use mockedGraph in code that requires store.Graph
 // 		// and then make assertions.
 //
 // 	}
-type IGraphMock struct {
+type GraphMock struct {
 	// CheckerFunc mocks the Checker method.
 	CheckerFunc func(ctx context.Context, state *healthcheck.CheckState) error
 
@@ -100,9 +100,9 @@ type IGraphMock struct {
 }
 
 // Checker calls CheckerFunc.
-func (mock *IGraphMock) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+func (mock *GraphMock) Checker(ctx context.Context, state *healthcheck.CheckState) error {
 	if mock.CheckerFunc == nil {
-		panic("IGraphMock.CheckerFunc: method is nil but IGraph.Checker was just called")
+		panic("GraphMock.CheckerFunc: method is nil but Graph.Checker was just called")
 	}
 	callInfo := struct {
 		Ctx   context.Context
@@ -119,8 +119,8 @@ func (mock *IGraphMock) Checker(ctx context.Context, state *healthcheck.CheckSta
 
 // CheckerCalls gets all the calls that were made to Checker.
 // Check the length with:
-//     len(mockedIGraph.CheckerCalls())
-func (mock *IGraphMock) CheckerCalls() []struct {
+//     len(mockedGraph.CheckerCalls())
+func (mock *GraphMock) CheckerCalls() []struct {
 	Ctx   context.Context
 	State *healthcheck.CheckState
 } {
@@ -135,9 +135,9 @@ func (mock *IGraphMock) CheckerCalls() []struct {
 }
 
 // Close calls CloseFunc.
-func (mock *IGraphMock) Close(ctx context.Context) error {
+func (mock *GraphMock) Close(ctx context.Context) error {
 	if mock.CloseFunc == nil {
-		panic("IGraphMock.CloseFunc: method is nil but IGraph.Close was just called")
+		panic("GraphMock.CloseFunc: method is nil but Graph.Close was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
@@ -152,8 +152,8 @@ func (mock *IGraphMock) Close(ctx context.Context) error {
 
 // CloseCalls gets all the calls that were made to Close.
 // Check the length with:
-//     len(mockedIGraph.CloseCalls())
-func (mock *IGraphMock) CloseCalls() []struct {
+//     len(mockedGraph.CloseCalls())
+func (mock *GraphMock) CloseCalls() []struct {
 	Ctx context.Context
 } {
 	var calls []struct {
@@ -166,9 +166,9 @@ func (mock *IGraphMock) CloseCalls() []struct {
 }
 
 // ErrorChan calls ErrorChanFunc.
-func (mock *IGraphMock) ErrorChan() chan error {
+func (mock *GraphMock) ErrorChan() chan error {
 	if mock.ErrorChanFunc == nil {
-		panic("IGraphMock.ErrorChanFunc: method is nil but IGraph.ErrorChan was just called")
+		panic("GraphMock.ErrorChanFunc: method is nil but Graph.ErrorChan was just called")
 	}
 	callInfo := struct {
 	}{}
@@ -180,8 +180,8 @@ func (mock *IGraphMock) ErrorChan() chan error {
 
 // ErrorChanCalls gets all the calls that were made to ErrorChan.
 // Check the length with:
-//     len(mockedIGraph.ErrorChanCalls())
-func (mock *IGraphMock) ErrorChanCalls() []struct {
+//     len(mockedGraph.ErrorChanCalls())
+func (mock *GraphMock) ErrorChanCalls() []struct {
 } {
 	var calls []struct {
 	}
@@ -192,9 +192,9 @@ func (mock *IGraphMock) ErrorChanCalls() []struct {
 }
 
 // Healthcheck calls HealthcheckFunc.
-func (mock *IGraphMock) Healthcheck() (string, error) {
+func (mock *GraphMock) Healthcheck() (string, error) {
 	if mock.HealthcheckFunc == nil {
-		panic("IGraphMock.HealthcheckFunc: method is nil but IGraph.Healthcheck was just called")
+		panic("GraphMock.HealthcheckFunc: method is nil but Graph.Healthcheck was just called")
 	}
 	callInfo := struct {
 	}{}
@@ -206,8 +206,8 @@ func (mock *IGraphMock) Healthcheck() (string, error) {
 
 // HealthcheckCalls gets all the calls that were made to Healthcheck.
 // Check the length with:
-//     len(mockedIGraph.HealthcheckCalls())
-func (mock *IGraphMock) HealthcheckCalls() []struct {
+//     len(mockedGraph.HealthcheckCalls())
+func (mock *GraphMock) HealthcheckCalls() []struct {
 } {
 	var calls []struct {
 	}
@@ -218,9 +218,9 @@ func (mock *IGraphMock) HealthcheckCalls() []struct {
 }
 
 // StreamCSVRows calls StreamCSVRowsFunc.
-func (mock *IGraphMock) StreamCSVRows(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
+func (mock *GraphMock) StreamCSVRows(ctx context.Context, instanceID string, filterID string, filters *observation.DimensionFilters, limit *int) (observation.StreamRowReader, error) {
 	if mock.StreamCSVRowsFunc == nil {
-		panic("IGraphMock.StreamCSVRowsFunc: method is nil but IGraph.StreamCSVRows was just called")
+		panic("GraphMock.StreamCSVRowsFunc: method is nil but Graph.StreamCSVRows was just called")
 	}
 	callInfo := struct {
 		Ctx        context.Context
@@ -243,8 +243,8 @@ func (mock *IGraphMock) StreamCSVRows(ctx context.Context, instanceID string, fi
 
 // StreamCSVRowsCalls gets all the calls that were made to StreamCSVRows.
 // Check the length with:
-//     len(mockedIGraph.StreamCSVRowsCalls())
-func (mock *IGraphMock) StreamCSVRowsCalls() []struct {
+//     len(mockedGraph.StreamCSVRowsCalls())
+func (mock *GraphMock) StreamCSVRowsCalls() []struct {
 	Ctx        context.Context
 	InstanceID string
 	FilterID   string
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"sort"
+)
+
+// The names of the backends that the observations of a dataset can be routed to
+const (
+	GraphBackend      = "graph"
+	MemoryBackend     = "memory"
+	SQLBackend        = "sql"
+	CantabularBackend = "cantabular"
+)
+
+// ErrUnknownBackend is returned when the observations of datasets are routed to a backend that is not registered
+var ErrUnknownBackend = errors.New("unknown observation store backend")
+
+// Registry holds the observation stores of the backends that the observations of datasets are routed to, either by the
+// ID of the dataset or by its type, or to the default backend otherwise. Cantabular can also be routed to, but its
+// observations are queried by the API rather than streamed from a store, so it has no observation store.
+type Registry struct {
+	defaultBackend  string
+	stores          map[string]ObservationStore
+	datasetBackends map[string]string
+	typeBackends    map[string]string
+}
+
+// NewRegistry returns a registry of the observation stores of the backends, routing the observations of datasets by
+// their ID or type to the backends of the provided routes. ErrUnknownBackend is returned if the default backend or a
+// routed backend has no observation store and is not Cantabular.
+func NewRegistry(defaultBackend string, stores map[string]ObservationStore, datasetBackends, typeBackends map[string]string) (*Registry, error) {
+	if _, found := stores[defaultBackend]; !found {
+		return nil, ErrUnknownBackend
+	}
+
+	for _, routes := range []map[string]string{datasetBackends, typeBackends} {
+		for _, backend := range routes {
+			if _, found := stores[backend]; !found && backend != CantabularBackend {
+				return nil, ErrUnknownBackend
+			}
+		}
+	}
+
+	return &Registry{
+		defaultBackend:  defaultBackend,
+		stores:          stores,
+		datasetBackends: datasetBackends,
+		typeBackends:    typeBackends,
+	}, nil
+}
+
+// NewSingleStoreRegistry returns a registry routing the observations of every dataset to the observation store
+func NewSingleStoreRegistry(backend string, observationStore ObservationStore) *Registry {
+	return &Registry{
+		defaultBackend: backend,
+		stores:         map[string]ObservationStore{backend: observationStore},
+	}
+}
+
+// Route returns the backend that the observations of the dataset are routed to by its ID, or else by its type, and
+// whether the dataset is routed at all
+func (r *Registry) Route(datasetID, datasetType string) (string, bool) {
+	if backend, found := r.datasetBackends[datasetID]; found {
+		return backend, true
+	}
+	if backend, found := r.typeBackends[datasetType]; found {
+		return backend, true
+	}
+	return "", false
+}
+
+// Default returns the backend of the datasets that are not routed, along with its observation store
+func (r *Registry) Default() (string, ObservationStore) {
+	return r.defaultBackend, r.stores[r.defaultBackend]
+}
+
+// Store returns the observation store of the backend, and whether it has one
+func (r *Registry) Store(backend string) (ObservationStore, bool) {
+	observationStore, found := r.stores[backend]
+	return observationStore, found
+}
+
+// Backends returns the backends that have an observation store, starting with the default backend followed by the
+// others in the order of their names
+func (r *Registry) Backends() []string {
+	backends := make([]string, 0, len(r.stores))
+	for backend := range r.stores {
+		if backend != r.defaultBackend {
+			backends = append(backends, backend)
+		}
+	}
+	sort.Strings(backends)
+
+	return append([]string{r.defaultBackend}, backends...)
+}
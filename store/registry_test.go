@@ -0,0 +1,83 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-observation-api/store"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry routing the observations of datasets by their ID and type", t, func() {
+		graphStore := store.NewGraphStore(nil)
+		memoryStore := store.NewMemoryStore()
+
+		registry, err := store.NewRegistry(store.GraphBackend,
+			map[string]store.ObservationStore{store.GraphBackend: graphStore, store.MemoryBackend: memoryStore},
+			map[string]string{"cpih01": store.GraphBackend},
+			map[string]string{"filterable": store.MemoryBackend, "cantabular_table": store.CantabularBackend},
+		)
+		So(err, ShouldBeNil)
+
+		Convey("Then a dataset is routed by its ID before its type", func() {
+			backend, found := registry.Route("cpih01", "filterable")
+			So(found, ShouldBeTrue)
+			So(backend, ShouldEqual, store.GraphBackend)
+		})
+
+		Convey("Then a dataset without a route by its ID is routed by its type", func() {
+			backend, found := registry.Route("mid-year-pop-est", "filterable")
+			So(found, ShouldBeTrue)
+			So(backend, ShouldEqual, store.MemoryBackend)
+
+			backend, found = registry.Route("TS008", "cantabular_table")
+			So(found, ShouldBeTrue)
+			So(backend, ShouldEqual, store.CantabularBackend)
+		})
+
+		Convey("Then a dataset without a route by its ID or type is not routed", func() {
+			_, found := registry.Route("TS009", "cantabular_flexible_table")
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Then the default backend is returned along with its observation store", func() {
+			backend, observationStore := registry.Default()
+			So(backend, ShouldEqual, store.GraphBackend)
+			So(observationStore, ShouldEqual, graphStore)
+		})
+
+		Convey("Then the observation store of each backend that has one is returned", func() {
+			observationStore, found := registry.Store(store.MemoryBackend)
+			So(found, ShouldBeTrue)
+			So(observationStore, ShouldEqual, memoryStore)
+
+			_, found = registry.Store(store.CantabularBackend)
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Then the backends with an observation store start with the default backend", func() {
+			So(registry.Backends(), ShouldResemble, []string{store.GraphBackend, store.MemoryBackend})
+		})
+	})
+
+	Convey("Given a route to a backend without an observation store", t, func() {
+		_, err := store.NewRegistry(store.MemoryBackend,
+			map[string]store.ObservationStore{store.MemoryBackend: store.NewMemoryStore()},
+			nil,
+			map[string]string{"filterable": store.SQLBackend},
+		)
+
+		Convey("Then the registry cannot be created", func() {
+			So(err, ShouldEqual, store.ErrUnknownBackend)
+		})
+	})
+
+	Convey("Given a default backend without an observation store", t, func() {
+		_, err := store.NewRegistry(store.GraphBackend, map[string]store.ObservationStore{}, nil, nil)
+
+		Convey("Then the registry cannot be created", func() {
+			So(err, ShouldEqual, store.ErrUnknownBackend)
+		})
+	})
+}
@@ -0,0 +1,476 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+
+	// drivers of the databases supported by the SQL store
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// The drivers of the databases that the SQL store can hold observations in
+const (
+	SQLiteDriver   = "sqlite"
+	PostgresDriver = "postgres"
+)
+
+// The kinds of the columns of the header of an instance
+const (
+	metadataColumn  = "metadata"
+	dimensionColumn = "dimension"
+)
+
+// ErrUnsupportedSQLDriver is returned when the SQL store is opened with a driver other than SQLiteDriver or PostgresDriver
+var ErrUnsupportedSQLDriver = errors.New("unsupported SQL observation store driver")
+
+// sqlSchema creates the tables of the observations, which are normalised into the header columns of each instance, the
+// options of its dimensions, its facts, which are its observations along with their metadata, and the option of each
+// dimension of each fact. The metadata of a fact, such as its data marking, is held as a line of CSV, as it is never
+// filtered on. The options of a fact are indexed by option, so that dimension filters are answered from the index.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS observation_columns (
+		instance_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		column_index INTEGER NOT NULL,
+		code_list TEXT NOT NULL,
+		name TEXT NOT NULL,
+		PRIMARY KEY (instance_id, kind, column_index)
+	)`,
+	`CREATE TABLE IF NOT EXISTS observation_options (
+		instance_id TEXT NOT NULL,
+		dimension INTEGER NOT NULL,
+		option_id INTEGER NOT NULL,
+		code TEXT NOT NULL,
+		label TEXT NOT NULL,
+		PRIMARY KEY (instance_id, dimension, option_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS observation_options_code ON observation_options (instance_id, dimension, code)`,
+	`CREATE TABLE IF NOT EXISTS observation_facts (
+		instance_id TEXT NOT NULL,
+		row_index INTEGER NOT NULL,
+		observation TEXT NOT NULL,
+		metadata TEXT NOT NULL,
+		PRIMARY KEY (instance_id, row_index)
+	)`,
+	`CREATE TABLE IF NOT EXISTS observation_fact_options (
+		instance_id TEXT NOT NULL,
+		row_index INTEGER NOT NULL,
+		dimension INTEGER NOT NULL,
+		option_id INTEGER NOT NULL,
+		PRIMARY KEY (instance_id, row_index, dimension)
+	)`,
+	`CREATE INDEX IF NOT EXISTS observation_fact_options_option ON observation_fact_options (instance_id, dimension, option_id, row_index)`,
+}
+
+// sqlTables are the tables of the observations, from which the observations of an instance are deleted before import
+var sqlTables = []string{"observation_columns", "observation_options", "observation_facts", "observation_fact_options"}
+
+// SQLStore is an observation store holding the observations of instances in a relational database, such as Postgres
+// or SQLite, into which they are imported from V4 files
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSQLStore opens the database of the driver with the data source name, creating the tables of the observations if
+// the database does not have them yet
+func NewSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	if driver != SQLiteDriver && driver != PostgresDriver {
+		return nil, ErrUnsupportedSQLDriver
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLStore{db: db, driver: driver}
+	for _, statement := range sqlSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// ImportDir imports the observations of the V4 files in the directory. The observations of each file are those of the
+// instance whose ID is the name of the file, without its extension.
+func (s *SQLStore) ImportDir(ctx context.Context, dir string) error {
+	return readV4Files(dir, func(instanceID string, r io.Reader) error {
+		return s.Import(ctx, instanceID, r)
+	})
+}
+
+// Import reads the V4 file of the instance into the database, replacing any observations it held for the instance. The
+// file is imported in a single transaction, so the observations of the instance are only replaced if it is valid.
+func (s *SQLStore) Import(ctx context.Context, instanceID string, r io.Reader) error {
+	reader, err := newV4Reader(r)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range sqlTables {
+		if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM "+table+" WHERE instance_id = ?"), instanceID); err != nil {
+			return err
+		}
+	}
+
+	insertColumn := s.rebind("INSERT INTO observation_columns (instance_id, kind, column_index, code_list, name) VALUES (?, ?, ?, ?, ?)")
+	for i, name := range reader.header.Metadata {
+		if _, err := tx.ExecContext(ctx, insertColumn, instanceID, metadataColumn, i, "", name); err != nil {
+			return err
+		}
+	}
+	for i, dimension := range reader.header.Dimensions {
+		if _, err := tx.ExecContext(ctx, insertColumn, instanceID, dimensionColumn, i, dimension.CodeList, dimension.Name); err != nil {
+			return err
+		}
+	}
+
+	insertOption, err := tx.PrepareContext(ctx, s.rebind("INSERT INTO observation_options (instance_id, dimension, option_id, code, label) VALUES (?, ?, ?, ?, ?)"))
+	if err != nil {
+		return err
+	}
+	defer insertOption.Close()
+
+	insertFact, err := tx.PrepareContext(ctx, s.rebind("INSERT INTO observation_facts (instance_id, row_index, observation, metadata) VALUES (?, ?, ?, ?)"))
+	if err != nil {
+		return err
+	}
+	defer insertFact.Close()
+
+	insertFactOption, err := tx.PrepareContext(ctx, s.rebind("INSERT INTO observation_fact_options (instance_id, row_index, dimension, option_id) VALUES (?, ?, ?, ?)"))
+	if err != nil {
+		return err
+	}
+	defer insertFactOption.Close()
+
+	// the options of each dimension are identified by their position in the order they are first found in the file
+	optionIDs := make([]map[Option]int, len(reader.header.Dimensions))
+	for i := range optionIDs {
+		optionIDs[i] = make(map[Option]int)
+	}
+
+	for rowIndex := 0; ; rowIndex++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		metadata, err := joinCSV(row.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if _, err := insertFact.ExecContext(ctx, instanceID, rowIndex, row.Observation, metadata); err != nil {
+			return err
+		}
+
+		for dimension, option := range row.Options {
+			optionID, found := optionIDs[dimension][option]
+			if !found {
+				optionID = len(optionIDs[dimension])
+				optionIDs[dimension][option] = optionID
+				if _, err := insertOption.ExecContext(ctx, instanceID, dimension, optionID, option.Code, option.Label); err != nil {
+					return err
+				}
+			}
+
+			if _, err := insertFactOption.ExecContext(ctx, instanceID, rowIndex, dimension, optionID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// StreamObservations streams the observations of the instance that match the filters from the database, in the order
+// of its V4 file. Each filtered dimension is translated into a condition that the fact has one of the options of the
+// dimension with the codes of the filter, which is answered from the index of the options of the facts.
+func (s *SQLStore) StreamObservations(ctx context.Context, instanceID string, filters *observation.DimensionFilters, limit *int) (RowReader, error) {
+	header, err := s.header(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	args := []interface{}{instanceID, instanceID}
+
+	if filters != nil {
+		for _, filter := range filters.Dimensions {
+			if filter == nil || len(filter.Options) == 0 {
+				continue
+			}
+
+			dimension := header.DimensionIndex(filter.Name)
+			if dimension < 0 {
+				return nil, ErrNotFound
+			}
+
+			conditions = append(conditions, `EXISTS (
+				SELECT 1 FROM observation_fact_options fo
+				JOIN observation_options o ON o.instance_id = fo.instance_id AND o.dimension = fo.dimension AND o.option_id = fo.option_id
+				WHERE fo.instance_id = m.instance_id AND fo.row_index = m.row_index AND fo.dimension = ?
+				AND o.code IN (?`+strings.Repeat(", ?", len(filter.Options)-1)+`))`)
+			args = append(args, dimension)
+			for _, option := range filter.Options {
+				args = append(args, option)
+			}
+		}
+	}
+
+	facts := "SELECT m.row_index FROM observation_facts m WHERE m.instance_id = ?"
+	for _, condition := range conditions {
+		facts += " AND " + condition
+	}
+	facts += " ORDER BY m.row_index"
+	if limit != nil {
+		facts += " LIMIT ?"
+		args = append(args, *limit)
+	}
+
+	query := `SELECT f.row_index, f.observation, f.metadata, fo.dimension, o.code, o.label
+		FROM observation_facts f
+		JOIN observation_fact_options fo ON fo.instance_id = f.instance_id AND fo.row_index = f.row_index
+		JOIN observation_options o ON o.instance_id = fo.instance_id AND o.dimension = fo.dimension AND o.option_id = fo.option_id
+		WHERE f.instance_id = ? AND f.row_index IN (` + facts + `)
+		ORDER BY f.row_index, fo.dimension`
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &sqlRowReader{rows: rows, header: header}
+
+	// the first row is read in advance, so that a query matching no observations can be reported as not found
+	first, err := reader.Read()
+	if err != nil {
+		rows.Close()
+		if err == io.EOF {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	reader.first = first
+
+	return reader, nil
+}
+
+// header returns the header of the instance, or ErrNotFound if the database holds no observations for the instance
+func (s *SQLStore) header(ctx context.Context, instanceID string) (Header, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT kind, code_list, name FROM observation_columns WHERE instance_id = ? ORDER BY kind, column_index"), instanceID)
+	if err != nil {
+		return Header{}, err
+	}
+	defer rows.Close()
+
+	var header Header
+	for rows.Next() {
+		var kind, codeList, name string
+		if err := rows.Scan(&kind, &codeList, &name); err != nil {
+			return Header{}, err
+		}
+
+		if kind == metadataColumn {
+			header.Metadata = append(header.Metadata, name)
+		} else {
+			header.Dimensions = append(header.Dimensions, Dimension{CodeList: codeList, Name: name})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Header{}, err
+	}
+
+	if len(header.Dimensions) == 0 {
+		return Header{}, ErrNotFound
+	}
+
+	return header, nil
+}
+
+// rebind replaces the '?' placeholders of the query with the numbered placeholders of Postgres
+func (s *SQLStore) rebind(query string) string {
+	if s.driver != PostgresDriver {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Checker checks the connection to the database
+func (s *SQLStore) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return state.Update(healthcheck.StatusCritical, fmt.Sprintf("%s observation store unavailable: %s", s.driver, err), 0)
+	}
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("%s observation store is ok", s.driver), http.StatusOK)
+}
+
+// Close closes the connections to the database
+func (s *SQLStore) Close(context.Context) error {
+	return s.db.Close()
+}
+
+// sqlRowReader reads the rows of observations that matched a query to the database. The options of each fact are
+// returned by the query as consecutive lines, in the order of the dimensions, which are collected into a single row.
+type sqlRowReader struct {
+	rows   *sql.Rows
+	header Header
+	first  *Row
+	next   *sqlLine
+	done   bool
+}
+
+// sqlLine is a line returned by a query of observations, holding a fact along with the option of one of its dimensions
+type sqlLine struct {
+	rowIndex    int
+	observation string
+	metadata    string
+	dimension   int
+	option      Option
+}
+
+// Header returns the header of the rows
+func (r *sqlRowReader) Header() Header {
+	return r.header
+}
+
+// Read returns the next row, or io.EOF when there are no more rows
+func (r *sqlRowReader) Read() (*Row, error) {
+	if r.first != nil {
+		row := r.first
+		r.first = nil
+		return row, nil
+	}
+
+	line := r.next
+	if line == nil {
+		var err error
+		if line, err = r.scan(); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := splitCSV(line.metadata, len(r.header.Metadata))
+	if err != nil {
+		return nil, err
+	}
+
+	row := &Row{
+		Observation: line.observation,
+		Metadata:    metadata,
+		Options:     make([]Option, len(r.header.Dimensions)),
+	}
+
+	for {
+		if line.dimension < 0 || line.dimension >= len(row.Options) {
+			return nil, ErrInvalidV4Row
+		}
+		row.Options[line.dimension] = line.option
+
+		next, err := r.scan()
+		if err == io.EOF {
+			r.next = nil
+			return row, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if next.rowIndex != line.rowIndex {
+			r.next = next
+			return row, nil
+		}
+		line = next
+	}
+}
+
+// scan returns the next line returned by the query, or io.EOF when there are no more lines
+func (r *sqlRowReader) scan() (*sqlLine, error) {
+	if r.done || !r.rows.Next() {
+		r.done = true
+		if err := r.rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	var line sqlLine
+	if err := r.rows.Scan(&line.rowIndex, &line.observation, &line.metadata, &line.dimension, &line.option.Code, &line.option.Label); err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// Close closes the rows returned by the query
+func (r *sqlRowReader) Close(context.Context) error {
+	return r.rows.Close()
+}
+
+// joinCSV encodes the columns as a line of CSV, without its line break
+func joinCSV(columns []string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// splitCSV decodes the line of CSV into its columns, of which there are expected to be count
+func splitCSV(line string, count int) ([]string, error) {
+	switch {
+	case count == 0:
+		return []string{}, nil
+	case count == 1 && line == "":
+		// a single empty column is encoded as an empty line, which has no record
+		return []string{""}, nil
+	}
+
+	columns, err := splitV4Line(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != count {
+		return nil, ErrInvalidV4Row
+	}
+	return columns, nil
+}
@@ -0,0 +1,193 @@
+package store_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-observation-api/store"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newSQLiteStore returns a SQL store held in a SQLite database in a temporary directory of the test
+func newSQLiteStore(t *testing.T) *store.SQLStore {
+	sqlStore, err := store.NewSQLStore(ctx, store.SQLiteDriver, filepath.Join(t.TempDir(), "observations.db"))
+	So(err, ShouldBeNil)
+	return sqlStore
+}
+
+func TestSQLStore(t *testing.T) {
+	Convey("Given a SQL store into which the V4 file of an instance has been imported", t, func() {
+		sqlStore := newSQLiteStore(t)
+		defer sqlStore.Close(ctx)
+		So(sqlStore.Import(ctx, "instance-1", strings.NewReader(testV4File)), ShouldBeNil)
+
+		Convey("When every observation of the instance is streamed", func() {
+			reader, err := sqlStore.StreamObservations(ctx, "instance-1", &observation.DimensionFilters{}, nil)
+			So(err, ShouldBeNil)
+			defer reader.Close(ctx)
+
+			Convey("Then the header describes the metadata and dimensions of the V4 file", func() {
+				So(reader.Header().V4Columns(), ShouldResemble, strings.Split(strings.SplitN(testV4File, "\n", 2)[0], ","))
+			})
+
+			Convey("Then every row is returned in the order of the V4 file", func() {
+				rows := readRows(reader)
+				So(rows, ShouldHaveLength, 4)
+				So(rows[0], ShouldResemble, &store.Row{
+					Observation: "146.3",
+					Metadata:    []string{""},
+					Options: []store.Option{
+						{Code: "Month", Label: "Aug-16"},
+						{Code: "K02000001", Label: "United Kingdom"},
+						{Code: "cpih1dim1A0", Label: "CPIH (overall index)"},
+					},
+				})
+				So(rows[2], ShouldResemble, &store.Row{
+					Observation: "",
+					Metadata:    []string{"x"},
+					Options: []store.Option{
+						{Code: "Month", Label: "Aug-16"},
+						{Code: "E92000001", Label: "England"},
+						{Code: "cpih1dim1A0", Label: "CPIH (overall index)"},
+					},
+				})
+				So(rows[3].Options[0], ShouldResemble, store.Option{Code: "Month", Label: "Sep-16"})
+			})
+		})
+
+		Convey("When the observations are filtered by the codes of the options of some dimensions", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{
+					{Name: "Geography", Options: []string{"K02000001"}},
+					{Name: "aggregate", Options: []string{"cpih1dim1A0", "cpih1dim1G10100"}},
+					{Name: "time"},
+				},
+			}
+
+			reader, err := sqlStore.StreamObservations(ctx, "instance-1", filters, nil)
+			So(err, ShouldBeNil)
+			defer reader.Close(ctx)
+
+			Convey("Then only the rows with one of the options of each filtered dimension are returned", func() {
+				rows := readRows(reader)
+				So(rows, ShouldHaveLength, 3)
+				So(rows[0].Observation, ShouldEqual, "146.3")
+				So(rows[1].Observation, ShouldEqual, "112.1")
+				So(rows[2].Observation, ShouldEqual, "147.0")
+			})
+		})
+
+		Convey("When the observations are streamed with a limit", func() {
+			limit := 2
+			reader, err := sqlStore.StreamObservations(ctx, "instance-1", nil, &limit)
+			So(err, ShouldBeNil)
+			defer reader.Close(ctx)
+
+			Convey("Then no more rows than the limit are returned", func() {
+				So(readRows(reader), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When the filters match no observations", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{{Name: "geography", Options: []string{"W92000004"}}},
+			}
+			_, err := sqlStore.StreamObservations(ctx, "instance-1", filters, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When a dimension that the instance does not have is filtered", func() {
+			filters := &observation.DimensionFilters{
+				Dimensions: []*observation.Dimension{{Name: "age", Options: []string{"30"}}},
+			}
+			_, err := sqlStore.StreamObservations(ctx, "instance-1", filters, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When the observations of an instance that was not imported are streamed", func() {
+			_, err := sqlStore.StreamObservations(ctx, "instance-2", nil, nil)
+
+			Convey("Then the typed not found error is returned", func() {
+				So(err, ShouldEqual, store.ErrNotFound)
+			})
+		})
+
+		Convey("When another V4 file of the instance is imported", func() {
+			So(sqlStore.Import(ctx, "instance-1", strings.NewReader("v4_0,time,time\n10,Month,Oct-16\n")), ShouldBeNil)
+
+			Convey("Then it replaces the observations of the instance", func() {
+				reader, err := sqlStore.StreamObservations(ctx, "instance-1", nil, nil)
+				So(err, ShouldBeNil)
+				defer reader.Close(ctx)
+
+				So(reader.Header().V4Columns(), ShouldResemble, []string{"v4_0", "time", "time"})
+				So(readRows(reader), ShouldResemble, []*store.Row{{
+					Observation: "10",
+					Metadata:    []string{},
+					Options:     []store.Option{{Code: "Month", Label: "Oct-16"}},
+				}})
+			})
+		})
+
+		Convey("When an invalid V4 file of the instance is imported", func() {
+			err := sqlStore.Import(ctx, "instance-1", strings.NewReader("v4_0,time,time\n146.3,Month\n"))
+
+			Convey("Then it is rejected and the observations of the instance are kept", func() {
+				So(err, ShouldEqual, store.ErrInvalidV4Row)
+
+				reader, err := sqlStore.StreamObservations(ctx, "instance-1", nil, nil)
+				So(err, ShouldBeNil)
+				defer reader.Close(ctx)
+				So(readRows(reader), ShouldHaveLength, 4)
+			})
+		})
+
+		Convey("When the health of the store is checked", func() {
+			state := healthcheck.NewCheckState("SQL observation store")
+			So(sqlStore.Checker(ctx, state), ShouldBeNil)
+
+			Convey("Then the store is healthy", func() {
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.StatusCode(), ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+
+	Convey("Given a directory of V4 files named by the IDs of their instances", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "instance-1.csv"), []byte(testV4File), 0o600), ShouldBeNil)
+
+		Convey("When the files are imported into the SQL store", func() {
+			sqlStore := newSQLiteStore(t)
+			defer sqlStore.Close(ctx)
+			So(sqlStore.ImportDir(ctx, dir), ShouldBeNil)
+
+			Convey("Then the observations of each instance can be streamed", func() {
+				reader, err := sqlStore.StreamObservations(ctx, "instance-1", nil, nil)
+				So(err, ShouldBeNil)
+				defer reader.Close(ctx)
+				So(readRows(reader), ShouldHaveLength, 4)
+			})
+		})
+	})
+
+	Convey("Given a driver that the SQL store does not support", t, func() {
+		_, err := store.NewSQLStore(ctx, "mysql", "observations")
+
+		Convey("Then the SQL store cannot be opened", func() {
+			So(err, ShouldEqual, store.ErrUnsupportedSQLDriver)
+		})
+	})
+}
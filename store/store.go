@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/dp-graph/v2/observation"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+//go:generate moq -out mock/graph.go -pkg mock . Graph
+
+// ErrNotFound is returned when no observations match the filters of a query, including when the store holds no
+// observations for the instance
+var ErrNotFound = errors.New("no observations match the filters")
+
+// ObservationStore defines the methods of the stores that hold the observations of instances
+type ObservationStore interface {
+	// StreamObservations returns a reader of the observations of the instance that match the filters, reading at most
+	// limit observations if a limit is provided. ErrNotFound is returned if no observations match the filters.
+	StreamObservations(ctx context.Context, instanceID string, filters *observation.DimensionFilters, limit *int) (RowReader, error)
+	Checker(ctx context.Context, state *healthcheck.CheckState) error
+	Close(ctx context.Context) error
+}
+
+// Importer defines the method of the observation stores that the observations of an instance can be imported into
+type Importer interface {
+	// Import reads the V4 file of the instance into the store, replacing any observations it held for the instance.
+	// ErrInvalidV4Header or ErrInvalidV4Row is returned if the file is not a valid V4 file.
+	Import(ctx context.Context, instanceID string, r io.Reader) error
+}
+
+// RowReader reads the rows of observations streamed from a store, which are described by its header
+type RowReader interface {
+	Header() Header
+	// Read returns the next row, or io.EOF when there are no more rows. ErrNotFound is returned if no observations
+	// matched the filters and the store could only tell once the rows were read.
+	Read() (*Row, error)
+	Close(ctx context.Context) error
+}
+
+// Header describes the metadata and dimensions of every row of observations
+type Header struct {
+	Metadata   []string
+	Dimensions []Dimension
+}
+
+// Dimension describes a dimension of the rows of observations: the name of the dimension, along with the header of the
+// column holding the codes of its options, which is usually the code list of the dimension in a V4 file
+type Dimension struct {
+	CodeList string
+	Name     string
+}
+
+// Row is an observation along with its metadata, such as its data marking, and the option of each dimension of the header
+type Row struct {
+	Observation string
+	Metadata    []string
+	Options     []Option
+}
+
+// Option is the option of a dimension of a row
+type Option struct {
+	Code  string
+	Label string
+}
+
+// V4Columns returns the columns of the header row of a V4 file with the header: the number of metadata columns in the
+// form 'v4_n', the metadata columns, then the code list and name of each dimension
+func (h Header) V4Columns() []string {
+	columns := make([]string, 0, 1+len(h.Metadata)+2*len(h.Dimensions))
+	columns = append(columns, v4Prefix+strconv.Itoa(len(h.Metadata)))
+	columns = append(columns, h.Metadata...)
+	for _, dimension := range h.Dimensions {
+		columns = append(columns, dimension.CodeList, dimension.Name)
+	}
+	return columns
+}
+
+// DimensionIndex returns the index of the dimension with the name among the dimensions of the header, or -1 if there is
+// no such dimension. Dimensions are matched regardless of case, like version dimensions are.
+func (h Header) DimensionIndex(name string) int {
+	for i, dimension := range h.Dimensions {
+		if strings.EqualFold(dimension.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// V4Columns returns the columns of the row in a V4 file, in the order of the header columns
+func (r *Row) V4Columns() []string {
+	columns := make([]string, 0, 1+len(r.Metadata)+2*len(r.Options))
+	columns = append(columns, r.Observation)
+	columns = append(columns, r.Metadata...)
+	for _, option := range r.Options {
+		columns = append(columns, option.Code, option.Label)
+	}
+	return columns
+}
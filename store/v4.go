@@ -0,0 +1,137 @@
+package store
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// v4Prefix is the prefix of the first column of the header row of a V4 file, which is followed by the number of
+// metadata columns that come after the observation column
+const v4Prefix = "v4_"
+
+// v4FileExtension is the extension of the V4 files read from a directory, whose names are the IDs of their instances
+const v4FileExtension = ".csv"
+
+// ErrInvalidV4Header is returned when the header row of a V4 file does not describe its columns
+var ErrInvalidV4Header = errors.New("invalid V4 header row")
+
+// ErrInvalidV4Row is returned when a row of a V4 file does not have the columns described by its header
+var ErrInvalidV4Row = errors.New("invalid V4 row")
+
+// ParseV4Header returns the header described by the columns of the header row of a V4 file
+func ParseV4Header(columns []string) (Header, error) {
+	if len(columns) == 0 || !strings.HasPrefix(strings.ToLower(columns[0]), v4Prefix) {
+		return Header{}, ErrInvalidV4Header
+	}
+
+	metadataCount, err := strconv.Atoi(columns[0][len(v4Prefix):])
+	if err != nil || metadataCount < 0 || len(columns) < 1+metadataCount || (len(columns)-1-metadataCount)%2 != 0 {
+		return Header{}, ErrInvalidV4Header
+	}
+
+	header := Header{
+		Metadata: append([]string{}, columns[1:1+metadataCount]...),
+	}
+	for i := 1 + metadataCount; i < len(columns); i += 2 {
+		header.Dimensions = append(header.Dimensions, Dimension{CodeList: columns[i], Name: columns[i+1]})
+	}
+
+	return header, nil
+}
+
+// parseV4Row returns the row of a V4 file with the provided header from its columns
+func parseV4Row(header Header, columns []string) (*Row, error) {
+	metadataCount := len(header.Metadata)
+	if len(columns) != 1+metadataCount+2*len(header.Dimensions) {
+		return nil, ErrInvalidV4Row
+	}
+
+	row := &Row{
+		Observation: columns[0],
+		Metadata:    columns[1 : 1+metadataCount],
+		Options:     make([]Option, 0, len(header.Dimensions)),
+	}
+	for i := 1 + metadataCount; i < len(columns); i += 2 {
+		row.Options = append(row.Options, Option{Code: columns[i], Label: columns[i+1]})
+	}
+
+	return row, nil
+}
+
+// v4Reader reads the rows of a V4 file, after its header row
+type v4Reader struct {
+	reader *csv.Reader
+	header Header
+}
+
+// newV4Reader reads the header row of the V4 file, returning a reader of the rest of its rows
+func newV4Reader(r io.Reader) (*v4Reader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	columns, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidV4Header
+		}
+		return nil, err
+	}
+
+	header, err := ParseV4Header(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v4Reader{reader: reader, header: header}, nil
+}
+
+// Read returns the next row of the V4 file, or io.EOF when there are no more rows
+func (r *v4Reader) Read() (*Row, error) {
+	columns, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return parseV4Row(r.header, columns)
+}
+
+// splitV4Line splits a line of a V4 file into its columns
+func splitV4Line(line string) ([]string, error) {
+	return csv.NewReader(strings.NewReader(line)).Read()
+}
+
+// readV4Files reads each V4 file of the directory with the provided function, along with the ID of its instance, which
+// is the name of the file without its extension
+func readV4Files(dir string, read func(instanceID string, r io.Reader) error) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+v4FileExtension))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := readV4File(path, read); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readV4File(path string, read func(instanceID string, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	instanceID := strings.TrimSuffix(filepath.Base(path), v4FileExtension)
+	if err := read(instanceID, f); err != nil {
+		return fmt.Errorf("failed to read V4 file %s: %w", path, err)
+	}
+	return nil
+}